@@ -4,13 +4,11 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"os/exec"
 	"strconv"
 	"time"
 
 	_ "github.com/lib/pq"
-	"github.com/mattes/migrate"
-	mpgsql "github.com/mattes/migrate/database/postgres"
-	_ "github.com/mattes/migrate/source/file"
 
 	"github.mpi-internal.com/Yapo/yams-dav-sync/pkg/domain"
 	"github.mpi-internal.com/Yapo/yams-dav-sync/pkg/infrastructure"
@@ -28,7 +26,8 @@ func elapsed(process string) func() {
 }
 
 func main() { // nolint: gocyclo
-	defer elapsed("exec")()
+	elapsedExec := elapsed("exec")
+	defer elapsedExec()
 
 	shutdownSequence := infrastructure.NewShutdownSequence()
 
@@ -51,6 +50,11 @@ func main() { // nolint: gocyclo
 	totalStr := flag.String("total", "0", "images qty. total to upload to yams")
 
 	object := flag.String("object", "", "image name to be deleted in yams")
+	format := flag.String("format", "text", "output format of history command: text or json")
+	summaryFile := flag.String("summary-file", "", "json file to write the summary of the run")
+	pageStr := flag.String("page", "1", "page of error marks to list")
+	minCounterStr := flag.String("min-counter", "0", "minimum error counter of error marks to list")
+	waitForLock := flag.Duration("wait-for-lock", 0, "time to wait for the run lock held by another run")
 	flag.Parse()
 
 	threads, e := strconv.Atoi(*threadsStr)
@@ -65,11 +69,34 @@ func main() { // nolint: gocyclo
 	if e != nil {
 		logger.Error("Error: %+v. total set as %+v", e, total)
 	}
+	page, e := strconv.Atoi(*pageStr)
+	if e != nil {
+		logger.Error("Error: %+v. page set as %+v", e, page)
+	}
+	minCounter, e := strconv.Atoi(*minCounterStr)
+	if e != nil {
+		logger.Error("Error: %+v. min-counter set as %+v", e, minCounter)
+	}
 	// Setting up insfrastructure
 
-	dialer, err := infrastructure.NewProxyDialerHandler(
-		conf.BandwidthProxyConf.ConnType,
-		conf.BandwidthProxyConf.Host,
+	// Bandwidth limiter proxy is optional, built-in rate limit may be used
+	dialer := infrastructure.NewDirectDialerHandler()
+	if conf.BandwidthProxyConf.Enabled {
+		dialer, err = infrastructure.NewProxyDialerHandler(
+			conf.BandwidthProxyConf.ConnType,
+			conf.BandwidthProxyConf.Host,
+		)
+		if err != nil {
+			logger.Error("%s\n", err)
+			os.Exit(2)
+		}
+	}
+
+	rateLimitConf := conf.RateLimit.Get(*opt)
+	rateLimiter, err := infrastructure.NewRateLimiter(
+		rateLimitConf.BytesPerSec,
+		rateLimitConf.RequestsPerSec,
+		rateLimitConf.Schedule,
 	)
 	if err != nil {
 		logger.Error("%s\n", err)
@@ -77,33 +104,112 @@ func main() { // nolint: gocyclo
 	}
 
 	// Metrics exporter
-	prometheus := infrastructure.NewPrometheusExporter(conf.MetricsConf.Port)
+	prometheus := infrastructure.NewPrometheusExporter(conf.MetricsConf.Port, logger)
 
 	// Set the first metric: Total of images to send using the syncher
 	prometheus.SetGauge(domain.TotalImages, float64(total))
 
-	circuitBreaker := infrastructure.NewCircuitBreaker(
+	circuitBreakers, err := infrastructure.NewCircuitBreakers(
 		conf.CircuitBreakerConf.Name,
 		conf.CircuitBreakerConf.ConsecutiveFailure,
 		conf.CircuitBreakerConf.FailureRatio,
 		conf.CircuitBreakerConf.Timeout,
 		conf.CircuitBreakerConf.Interval,
+		conf.CircuitBreakerConf.FailureCodes,
+		prometheus,
 		logger,
 	)
+	if err != nil {
+		logger.Error("%s\n", err)
+		os.Exit(2)
+	}
 
-	HTTPHandler := infrastructure.NewHTTPHandler(dialer, circuitBreaker, logger)
+	HTTPHandler := infrastructure.NewHTTPHandler(dialer, circuitBreakers, rateLimiter, prometheus, logger)
 
-	signer := infrastructure.NewJWTSigner(conf.YamsConf.PrivateKeyFile, logger)
+	signer, err := infrastructure.NewJWTSigner(conf.YamsConf.PrivateKeyFile, logger)
+	if err != nil {
+		logger.Error("%s\n", err)
+		os.Exit(2)
+	}
 
-	dbHandler, err := infrastructure.NewPgsqlHandler(conf.Database, logger)
+	// Secondary key is optional, it is used when yams rejects the primary key
+	var secondarySigner repository.Signer
+	if conf.YamsConf.SecondaryAccessKeyID != "" && conf.YamsConf.SecondaryPrivateKeyFile != "" {
+		secondarySigner, err = infrastructure.NewJWTSigner(conf.YamsConf.SecondaryPrivateKeyFile, logger)
+		if err != nil {
+			logger.Error("%s\n", err)
+			os.Exit(2)
+		}
+	}
+	logger.Info("Using access key %s", conf.YamsConf.AccessKeyID)
+	prometheus.SetGauge(domain.ActiveAccessKey, domain.PrimaryAccessKey)
+
+	// The state is kept in postgres, or in a local SQLite file on single hosts
+	var dbHandler repository.DbHandler
+	switch conf.Database.Driver {
+	case "postgres":
+		dbHandler, err = infrastructure.NewPgsqlHandler(conf.Database, logger)
+	case "sqlite3":
+		dbHandler, err = infrastructure.NewSqliteHandler(conf.Database, logger)
+	default:
+		err = fmt.Errorf("Unknown database driver %s", conf.Database.Driver)
+	}
 	if err != nil {
 		logger.Error("%s\n", err)
 		os.Exit(2)
 	}
 
 	shutdownSequence.Push(dbHandler)
+	// prometheus is closed after the commands, pushing their final metrics,
+	// and before the database, needed to get the last sync mark
+	shutdownSequence.Push(prometheus)
+	// exit closes the pushed components before exiting, so the database is
+	// closed and the final metrics pushed with the exit code
+	exit := func(code int) {
+		prometheus.SetExitStatus(code)
+		shutdownSequence.Done()
+		shutdownSequence.Wait()
+		os.Exit(code)
+	}
 
-	setUpMigrations(conf, dbHandler, logger)
+	// Migrations are only applied by the migrate command, the other commands
+	// refuse to run while the schema is behind or dirty
+	migrations, err := infrastructure.NewMigrations(conf.Database, dbHandler, logger)
+	if err != nil {
+		logger.Error("%s\n", err)
+		exit(2)
+	}
+	if *opt == "migrate" {
+		exitCode := 0
+		if err := migrations.Run(flag.Args()); err != nil {
+			logger.Error("Error migrating: %+v", err)
+			exitCode = 2
+		}
+		exit(exitCode)
+	}
+	if err := migrations.Check(); err != nil {
+		logger.Error("%s\n", err)
+		exit(2)
+	}
+
+	// Tracing is optional, spans are discarded unless it is enabled
+	var tracer interfaces.Tracer = interfaces.NoopTracer
+	if conf.Tracing.Enabled {
+		otelTracer, err := infrastructure.NewOpenTelemetryTracer(
+			conf.Tracing.Exporter,
+			conf.Tracing.Endpoint,
+			conf.Tracing.Insecure,
+			conf.Tracing.ServiceName,
+			conf.Tracing.SampleRatio,
+		)
+		if err != nil {
+			logger.Error("%s\n", err)
+			exit(2)
+		}
+		// the tracer is closed after the commands, flushing their last spans
+		shutdownSequence.Push(otelTracer)
+		tracer = otelTracer
+	}
 
 	localImageRepo := repository.NewLocalImageRepo(
 		conf.LocalStorageConf.Path,
@@ -124,7 +230,11 @@ func main() { // nolint: gocyclo
 		conf.YamsConf.ErrorControlHeader,
 		conf.YamsConf.ErrorControlValue,
 		conf.YamsConf.MaxConcurrentConns,
+		secondarySigner,
+		conf.YamsConf.SecondaryAccessKeyID,
+		prometheus,
 	)
+	yamsRepo.SetTracer(tracer)
 
 	defaultLastSyncDate, err := time.Parse(
 		conf.LastSync.DefaultLayout,
@@ -135,7 +245,7 @@ func main() { // nolint: gocyclo
 		fmt.Printf("Wrong date layout %+v for date %+v",
 			conf.LastSync.DefaultLayout,
 			conf.LastSync.DefaultDate)
-		os.Exit(3)
+		exit(3)
 	}
 
 	lastSyncRepo := repository.NewLastSyncRepo(
@@ -144,103 +254,317 @@ func main() { // nolint: gocyclo
 		defaultLastSyncDate,
 	)
 
+	if conf.MetricsConf.PushgatewayURL != "" {
+		prometheus.EnablePush(
+			conf.MetricsConf.PushgatewayURL,
+			conf.MetricsConf.PushgatewayJob,
+			map[string]string{"command": *opt, "profile": conf.MetricsConf.Profile},
+			lastSyncRepo.GetLastSynchronizationMark,
+		)
+	}
+
 	errorControlRepo := repository.NewErrorControlRepo(
 		dbHandler,
 		conf.ErrorControl.MaxResultsPerPage,
+		time.Duration(conf.ErrorControl.RetryBackoffBase)*time.Second,
+		time.Duration(conf.ErrorControl.RetryBackoffMax)*time.Second,
 	)
+	// Error marks writes are batched in transactions, pending writes are
+	// applied when each sync is closed, reporting the failed ones in its stats,
+	// and on shutdown before closing the database
+	if conf.ErrorControl.BatchSize > 1 {
+		errorControlBatch := repository.NewErrorControlBatch(
+			dbHandler,
+			conf.ErrorControl.MaxResultsPerPage,
+			time.Duration(conf.ErrorControl.RetryBackoffBase)*time.Second,
+			time.Duration(conf.ErrorControl.RetryBackoffMax)*time.Second,
+			conf.ErrorControl.BatchSize,
+			time.Duration(conf.ErrorControl.BatchInterval)*time.Millisecond,
+		)
+		shutdownSequence.Push(errorControlBatch)
+		errorControlRepo = errorControlBatch
+	}
 
-	cliYams := interfaces.NewCLIYams(
-		yamsRepo,
-		errorControlRepo,
-		lastSyncRepo,
-		localImageRepo,
-		loggers.MakeCLIYamsLogger(logger),
-		defaultLastSyncDate,
-		interfaces.NewStats(prometheus),
-		conf.LocalStorageConf.DefaultFilesDateLayout,
-	)
+	// Images are validated before upload only if validation is enabled
+	var imageValidator interfaces.ImageValidator
+	if conf.ImageValidation.Enabled {
+		imageValidator = repository.NewImageValidator(
+			infrastructure.NewLocalFileSystemView(logger),
+			conf.ImageValidation.MinWidth,
+			conf.ImageValidation.MinHeight,
+		)
+	}
+	quarantineRepo := repository.NewQuarantineRepo(dbHandler)
+	runHistoryRepo := repository.NewRunHistoryRepo(dbHandler)
+	deadLetterRepo := repository.NewDeadLetterRepo(dbHandler)
+
+	// Sync & deleteAll hold a run lock per profile, preventing concurrent runs.
+	// The advisory lock dies with the process, the table lock with postgres
+	// advisory locks unavailable or on sqlite is taken over once stale
+	hostname, err := os.Hostname()
+	if err != nil {
+		logger.Error("Error getting hostname: %+v", err)
+	}
+	var runLock interfaces.RunLock
+	if pgsqlHandler, ok := dbHandler.(*infrastructure.PgsqlHandler); ok && conf.RunLock.Mode == "advisory" {
+		runLock = infrastructure.NewPgsqlRunLock(
+			pgsqlHandler,
+			conf.MetricsConf.Profile,
+			hostname,
+			os.Getpid(),
+		)
+	} else {
+		runLock, err = repository.NewRunLockRepo(
+			dbHandler,
+			conf.MetricsConf.Profile,
+			hostname,
+			os.Getpid(),
+			time.Duration(conf.RunLock.StaleAfter)*time.Second,
+			time.Duration(conf.RunLock.Heartbeat)*time.Second,
+		)
+		if err != nil {
+			logger.Error("%s\n", err)
+			exit(2)
+		}
+	}
 
-	shutdownSequence.Push(cliYams)
+	// Sync is paused out of allowed windows only if they are configured
+	var syncSchedule interfaces.SyncSchedule
+	if conf.Schedule.SyncWindows != "" {
+		syncSchedule, err = infrastructure.NewTimeWindows(conf.Schedule.SyncWindows)
+		if err != nil {
+			logger.Error("%s\n", err)
+			exit(2)
+		}
+	}
+
+	// Uploads concurrency adapts to yams load only if it is enabled
+	var concurrencyLimiter interfaces.ConcurrencyLimiter
+	if conf.Concurrency.Enabled {
+		concurrencyLimiter = interfaces.NewAdaptiveLimiter(
+			threads,
+			conf.Concurrency.MinLimit,
+			conf.YamsConf.MaxConcurrentConns,
+			time.Duration(conf.Concurrency.LatencyThreshold)*time.Millisecond,
+			conf.Concurrency.BackoffRatio,
+			prometheus,
+		)
+	}
+
+	// newCLIYams creates a CLIYams with its optional stages, each daemon job
+	// needs its own instance
+	newCLIYams := func() *interfaces.CLIYams {
+		cli := interfaces.NewCLIYams(
+			yamsRepo,
+			errorControlRepo,
+			lastSyncRepo,
+			localImageRepo,
+			loggers.MakeCLIYamsLogger(logger),
+			defaultLastSyncDate,
+			interfaces.NewStats(prometheus),
+			conf.LocalStorageConf.DefaultFilesDateLayout,
+		)
+		cli.SetImageValidation(imageValidator, quarantineRepo)
+		cli.SetSyncSchedule(syncSchedule)
+		cli.SetConcurrencyLimiter(concurrencyLimiter)
+		cli.SetProgress(total, conf.Progress.LogInterval)
+		cli.SetTracer(tracer)
+		cli.SetRunHistory(runHistoryRepo)
+		cli.SetDeadLetter(deadLetterRepo)
+		cli.SetRunLock(runLock, *waitForLock)
+		return cli
+	}
 
 	maxErrorTolerance := conf.ErrorControl.MaxRetriesPerError
+
+	// Daemon mode keeps the process & metrics alive running syncs periodically
+	// and on demand through the daemon API
+	if *opt == "daemon" {
+		if conf.Daemon.APIToken == "" {
+			logger.Error("DAEMON_API_TOKEN is required to run the daemon\n")
+			exit(2)
+		}
+		var prepare func() error
+		if conf.Daemon.SortCommand != "" {
+			prepare = func() error {
+				cmd := exec.Command("sh", "-c", conf.Daemon.SortCommand) // nolint: gosec
+				cmd.Stdout = os.Stdout
+				cmd.Stderr = os.Stderr
+				return cmd.Run()
+			}
+		}
+		daemon := interfaces.NewDaemon(
+			newCLIYams,
+			prepare,
+			threads,
+			maxErrorTolerance,
+			*dumpFile,
+			time.Duration(conf.Daemon.SyncInterval)*time.Minute,
+			lastSyncRepo,
+			circuitBreakers,
+			loggers.MakeDaemonLogger(logger),
+		)
+		infrastructure.ExposeProgress(daemon.Progress)
+		shutdownSequence.Push(daemon)
+		shutdownSequence.Push(infrastructure.NewDaemonAPI(
+			conf.Daemon.APIPort,
+			conf.Daemon.APIToken,
+			daemon,
+			logger,
+		))
+		daemon.Start()
+		shutdownSequence.Wait()
+		return
+	}
+
+	cliYams := newCLIYams()
+	infrastructure.ExposeProgress(cliYams.Progress)
+	shutdownSequence.Push(cliYams)
+	// An interrupted run doesn't finish, it is pushed as partial
+	shutdownSequence.OnInterrupt(func() {
+		prometheus.SetExitStatus(interfaces.ExitPartial)
+	})
+
+	// Every run is recorded to get its outcome, only commands changing yams
+	// or the sync marks are stored in the history
+	switch *opt {
+	case "sync", "deleteAll", "delete", "reset":
+	default:
+		cliYams.SetRunHistory(nil)
+	}
+	if *summaryFile != "" {
+		cliYams.SetSummaryWriter(infrastructure.NewSummaryFile(*summaryFile))
+	}
+	flags := make(map[string]string)
+	flag.VisitAll(func(f *flag.Flag) {
+		flags[f.Name] = f.Value.String()
+	})
+	cliYams.StartRun(*opt, flags)
 	go func() {
+		var e error
 		switch *opt {
 		case "sync":
 			if *dumpFile != "" && threads > 0 {
-				if e := cliYams.Sync(threads, limit, maxErrorTolerance, *dumpFile); e != nil {
+				if e = cliYams.Sync(threads, limit, maxErrorTolerance, *dumpFile); e != nil {
 					logger.Error("Error with synchornization: %+v", e)
 				}
 			} else {
+				e = fmt.Errorf("missing params")
 				logger.Error("make start command=sync threads=[number] limit=[limit] dump-file=[path]")
 			}
 
 		case "list":
-			if e := cliYams.List(limit); e != nil {
+			if e = cliYams.List(limit); e != nil {
 				logger.Error("Error listing: %+v", e)
 			}
 
 		case "deleteAll":
 			if threads > 0 {
-				if e := cliYams.DeleteAll(threads, limit); e != nil {
+				if e = cliYams.DeleteAll(threads, limit); e != nil {
 					logger.Error("Error deleting: %+v ", e)
 				}
 			} else {
+				e = fmt.Errorf("missing params")
 				logger.Error("make start command=deleteAll threads=[number]")
 			}
 
 		case "delete":
-			if e := cliYams.Delete(*object); e != nil {
+			if e = cliYams.Delete(*object); e != nil {
 				logger.Error("Error deleting: %+v", e)
 			}
 
 		case "reset":
-			if e := cliYams.Reset(); e != nil {
+			if e = cliYams.Reset(); e != nil {
 				logger.Error("Error reseting: %+v", e)
 			}
 
 		case "marks":
-			if e := cliYams.GetMarks(); e != nil {
+			if e = cliYams.GetMarks(); e != nil {
 				logger.Error("Error getting sync marks: %+v", e)
 			}
 
+		case "quarantine":
+			if e = cliYams.GetQuarantine(); e != nil {
+				logger.Error("Error getting quarantined images: %+v", e)
+			}
+
+		case "errors":
+			if e = cliYams.ListErrors(page, minCounter); e != nil {
+				logger.Error("Error listing error marks: %+v", e)
+			}
+
+		case "error":
+			if e = cliYams.ShowError(*object); e != nil {
+				logger.Error("Error getting error mark of %s: %+v", *object, e)
+			}
+
+		case "resetErrors":
+			if e = cliYams.ResetErrors(*object); e != nil {
+				logger.Error("Error reseting error counters: %+v", e)
+			}
+
+		case "purgeErrors":
+			if e = cliYams.PurgeErrors(maxErrorTolerance); e != nil {
+				logger.Error("Error purging error marks: %+v", e)
+			}
+
+		case "exportErrors":
+			if e = cliYams.ExportErrors(maxErrorTolerance); e != nil {
+				logger.Error("Error exporting error marks: %+v", e)
+			}
+
+		case "moveDeadLetter":
+			if e = cliYams.MoveToDeadLetter(maxErrorTolerance); e != nil {
+				logger.Error("Error moving images to the dead letter: %+v", e)
+			}
+
+		case "deadLetter":
+			if e = cliYams.GetDeadLetter(); e != nil {
+				logger.Error("Error getting the dead letter: %+v", e)
+			}
+
+		case "exportDeadLetter":
+			if e = cliYams.ExportDeadLetter(); e != nil {
+				logger.Error("Error exporting the dead letter: %+v", e)
+			}
+
+		case "importDeadLetter":
+			if *dumpFile != "" {
+				if e = cliYams.ImportDeadLetter(*dumpFile); e != nil {
+					logger.Error("Error importing the dead letter: %+v", e)
+				}
+			} else {
+				e = fmt.Errorf("missing params")
+				logger.Error("make deadletterimport dumpfile=[path]")
+			}
+
+		case "lock":
+			if e = cliYams.GetRunLock(); e != nil {
+				logger.Error("Error getting the run lock: %+v", e)
+			}
+
+		case "breakLock":
+			if e = cliYams.BreakRunLock(); e != nil {
+				logger.Error("Error breaking the run lock: %+v", e)
+			}
+
+		case "history":
+			if e = cliYams.GetHistory(limit, *format == "json"); e != nil {
+				logger.Error("Error getting run history: %+v", e)
+			}
+
 		default:
+			e = fmt.Errorf("unknown command")
 			logger.Error("Make start command=[commmand]\nCommand list:\n- sync \n- list\n- deleteAll\n")
 		}
+		cliYams.SetRunResult(e)
+		prometheus.SetExitStatus(cliYams.ExitCode())
 		shutdownSequence.Done()
 	}()
 
 	shutdownSequence.Wait()
-}
-
-// Autoexecute database migrations
-func setUpMigrations(conf infrastructure.Config, dbHandler *infrastructure.PgsqlHandler, logger loggers.Logger) {
-	driver, err := mpgsql.WithInstance(dbHandler.Conn, &mpgsql.Config{})
-	if err != nil {
-		logger.Error("Error to instance migrations %v", err)
-		return
-	}
-	mig, err := migrate.NewWithDatabaseInstance(
-		"file://"+conf.Database.MgFolder,
-		conf.Database.MgDriver,
-		driver,
-	)
-	if err != nil {
-		logger.Error("Consume migrations sources err %#v", err)
-		return
-	}
-	version, _, e := mig.Version()
-	if e != nil {
-		logger.Error("Error getting current migration version: %#v", e)
-	}
-	logger.Info("Migrations Actual Version %d", version)
-	err = mig.Up()
-	if err != nil && err != migrate.ErrNoChange {
-		logger.Info("Migration message: %v", err)
-		return
-	}
-	version, _, e = mig.Version()
-	if e != nil {
-		logger.Error("Error getting current migration version: %#v", e)
-	}
-	logger.Info("Migrations upgraded to version %d", version)
+	// The exit code reflects the outcome of the run: success, partial or fatal
+	exitCode := cliYams.ExitCode()
+	elapsedExec()
+	os.Exit(exitCode)
 }
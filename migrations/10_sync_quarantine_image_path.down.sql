@@ -0,0 +1,3 @@
+-- quarantined paths longer than the old column can't be kept
+DELETE FROM sync_quarantine WHERE LENGTH(image_path) > 255;
+ALTER TABLE sync_quarantine ALTER COLUMN image_path TYPE VARCHAR(255);
@@ -0,0 +1 @@
+ALTER TABLE sync_quarantine ALTER COLUMN image_path TYPE VARCHAR(1024);
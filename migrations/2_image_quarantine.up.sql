@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS sync_quarantine (
+	sync_quarantine_id	SERIAL PRIMARY KEY,
+	image_path	VARCHAR(255) NOT NULL,
+	reason	VARCHAR(255) NOT NULL,
+	quarantined_at	TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+ALTER TABLE sync_quarantine ADD CONSTRAINT quarantine_image_path_unique UNIQUE (image_path);
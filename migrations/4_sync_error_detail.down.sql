@@ -0,0 +1,8 @@
+ALTER TABLE sync_error
+	DROP COLUMN IF EXISTS last_error_class,
+	DROP COLUMN IF EXISTS last_http_status,
+	DROP COLUMN IF EXISTS last_error_message,
+	DROP COLUMN IF EXISTS first_failure_at,
+	DROP COLUMN IF EXISTS last_failure_at,
+	DROP COLUMN IF EXISTS attempts,
+	DROP COLUMN IF EXISTS retryable;
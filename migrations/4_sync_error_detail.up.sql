@@ -0,0 +1,8 @@
+ALTER TABLE sync_error
+	ADD COLUMN last_error_class	VARCHAR(50) NOT NULL DEFAULT '',
+	ADD COLUMN last_http_status	INT NOT NULL DEFAULT 0,
+	ADD COLUMN last_error_message	TEXT NOT NULL DEFAULT '',
+	ADD COLUMN first_failure_at	TIMESTAMP NOT NULL DEFAULT NOW(),
+	ADD COLUMN last_failure_at	TIMESTAMP NOT NULL DEFAULT NOW(),
+	ADD COLUMN attempts	INT NOT NULL DEFAULT 0,
+	ADD COLUMN retryable	BOOLEAN NOT NULL DEFAULT TRUE;
@@ -0,0 +1,3 @@
+ALTER TABLE sync_error ADD COLUMN next_retry_at	TIMESTAMP NOT NULL DEFAULT NOW();
+
+CREATE INDEX sync_error_next_retry_at_idx ON sync_error (next_retry_at);
@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS sync_dead_letter (
+	sync_dead_letter_id	SERIAL PRIMARY KEY,
+	image_path	VARCHAR(255) NOT NULL,
+	reason	TEXT NOT NULL,
+	error_counter	INT NOT NULL DEFAULT 0,
+	last_error_class	VARCHAR(50) NOT NULL DEFAULT '',
+	last_http_status	INT NOT NULL DEFAULT 0,
+	last_failure_at	TIMESTAMP NOT NULL DEFAULT NOW(),
+	dead_at	TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+ALTER TABLE sync_dead_letter ADD CONSTRAINT dead_letter_image_path_unique UNIQUE (image_path);
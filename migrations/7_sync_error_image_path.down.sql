@@ -0,0 +1,13 @@
+-- dead letters of paths longer than the old column can't be kept
+DELETE FROM sync_dead_letter WHERE LENGTH(image_path) > 255;
+ALTER TABLE sync_dead_letter ALTER COLUMN image_path TYPE VARCHAR(255);
+
+-- error marks are keyed by image name again: one mark is kept by name and
+-- the marks of names longer than the old column are dropped
+DELETE FROM sync_error WHERE LENGTH(image_name) > 20 OR sync_error_id NOT IN (
+	SELECT MIN(sync_error_id) FROM sync_error GROUP BY image_name
+);
+UPDATE sync_error SET image_path = image_name;
+ALTER TABLE sync_error
+	DROP COLUMN IF EXISTS image_name,
+	ALTER COLUMN image_path TYPE VARCHAR(20);
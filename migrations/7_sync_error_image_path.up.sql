@@ -0,0 +1,7 @@
+ALTER TABLE sync_error
+	ALTER COLUMN image_path TYPE VARCHAR(1024),
+	ADD COLUMN image_name	VARCHAR(255) NOT NULL DEFAULT '';
+
+UPDATE sync_error SET image_name = image_path;
+
+ALTER TABLE sync_dead_letter ALTER COLUMN image_path TYPE VARCHAR(1024);
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS sync_lock (
+	profile	VARCHAR(255) PRIMARY KEY,
+	command	VARCHAR(50) NOT NULL,
+	host	VARCHAR(255) NOT NULL,
+	pid	INT NOT NULL,
+	acquired_at	TIMESTAMP NOT NULL DEFAULT NOW(),
+	heartbeat_at	TIMESTAMP NOT NULL DEFAULT NOW()
+);
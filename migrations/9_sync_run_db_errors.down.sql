@@ -0,0 +1 @@
+ALTER TABLE sync_run DROP COLUMN IF EXISTS db_errors;
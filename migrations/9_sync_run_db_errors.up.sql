@@ -0,0 +1 @@
+ALTER TABLE sync_run ADD COLUMN IF NOT EXISTS db_errors	INT NOT NULL DEFAULT 0;
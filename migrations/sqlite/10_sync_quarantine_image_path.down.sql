@@ -0,0 +1 @@
+-- sqlite does not enforce VARCHAR lengths, nothing to restore
@@ -0,0 +1 @@
+-- sqlite does not enforce VARCHAR lengths, the quarantine image_path is wide enough already
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS sync_error (
+	sync_error_id	INTEGER PRIMARY KEY AUTOINCREMENT,
+	image_path	VARCHAR(20) NOT NULL,
+	error_counter	INT NOT NULL,
+	CONSTRAINT image_path_unique UNIQUE (image_path)
+);
+
+CREATE TABLE IF NOT EXISTS  last_sync (
+	last_sync_id	INTEGER PRIMARY KEY AUTOINCREMENT,
+	last_sync_date	TIMESTAMP
+);
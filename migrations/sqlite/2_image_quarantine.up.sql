@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS sync_quarantine (
+	sync_quarantine_id	INTEGER PRIMARY KEY AUTOINCREMENT,
+	image_path	VARCHAR(255) NOT NULL,
+	reason	VARCHAR(255) NOT NULL,
+	quarantined_at	TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	CONSTRAINT quarantine_image_path_unique UNIQUE (image_path)
+);
@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS sync_run (
+	sync_run_id	INTEGER PRIMARY KEY AUTOINCREMENT,
+	command	VARCHAR(50) NOT NULL,
+	flags	TEXT NOT NULL,
+	started_at	TIMESTAMP NOT NULL,
+	finished_at	TIMESTAMP NOT NULL,
+	sent	INT NOT NULL DEFAULT 0,
+	errors	INT NOT NULL DEFAULT 0,
+	duplicated	INT NOT NULL DEFAULT 0,
+	processed	INT NOT NULL DEFAULT 0,
+	skipped	INT NOT NULL DEFAULT 0,
+	not_found	INT NOT NULL DEFAULT 0,
+	recovered	INT NOT NULL DEFAULT 0,
+	quarantined	INT NOT NULL DEFAULT 0,
+	mark_before	TIMESTAMP,
+	mark_after	TIMESTAMP,
+	exit_reason	TEXT NOT NULL
+);
+
+CREATE INDEX sync_run_started_at_idx ON sync_run (started_at);
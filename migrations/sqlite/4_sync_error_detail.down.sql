@@ -0,0 +1,7 @@
+ALTER TABLE sync_error DROP COLUMN last_error_class;
+ALTER TABLE sync_error DROP COLUMN last_http_status;
+ALTER TABLE sync_error DROP COLUMN last_error_message;
+ALTER TABLE sync_error DROP COLUMN first_failure_at;
+ALTER TABLE sync_error DROP COLUMN last_failure_at;
+ALTER TABLE sync_error DROP COLUMN attempts;
+ALTER TABLE sync_error DROP COLUMN retryable;
@@ -0,0 +1,19 @@
+CREATE TABLE sync_error_detail (
+	sync_error_id	INTEGER PRIMARY KEY AUTOINCREMENT,
+	image_path	VARCHAR(20) NOT NULL,
+	error_counter	INT NOT NULL,
+	last_error_class	VARCHAR(50) NOT NULL DEFAULT '',
+	last_http_status	INT NOT NULL DEFAULT 0,
+	last_error_message	TEXT NOT NULL DEFAULT '',
+	first_failure_at	TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	last_failure_at	TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	attempts	INT NOT NULL DEFAULT 0,
+	retryable	BOOLEAN NOT NULL DEFAULT TRUE,
+	CONSTRAINT image_path_unique UNIQUE (image_path)
+);
+
+INSERT INTO sync_error_detail(sync_error_id, image_path, error_counter)
+	SELECT sync_error_id, image_path, error_counter FROM sync_error;
+
+DROP TABLE sync_error;
+ALTER TABLE sync_error_detail RENAME TO sync_error;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS sync_error_next_retry_at_idx;
+ALTER TABLE sync_error DROP COLUMN next_retry_at;
@@ -0,0 +1,6 @@
+-- error marks are keyed by image name again, one mark is kept by name
+DELETE FROM sync_error WHERE sync_error_id NOT IN (
+	SELECT MIN(sync_error_id) FROM sync_error GROUP BY image_name
+);
+UPDATE sync_error SET image_path = image_name;
+ALTER TABLE sync_error DROP COLUMN image_name;
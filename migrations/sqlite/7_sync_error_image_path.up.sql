@@ -0,0 +1,4 @@
+-- sqlite does not enforce VARCHAR lengths, image_path is wide enough already
+ALTER TABLE sync_error ADD COLUMN image_name	VARCHAR(255) NOT NULL DEFAULT '';
+
+UPDATE sync_error SET image_name = image_path;
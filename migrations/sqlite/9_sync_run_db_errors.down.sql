@@ -0,0 +1 @@
+ALTER TABLE sync_run DROP COLUMN db_errors;
@@ -0,0 +1 @@
+ALTER TABLE sync_run ADD COLUMN db_errors	INT NOT NULL DEFAULT 0;
@@ -14,6 +14,13 @@ const (
 	YAMSSoftRemoval = false
 )
 
+const (
+	// PrimaryAccessKey yams requests are signed with the primary access key
+	PrimaryAccessKey = iota
+	// SecondaryAccessKey yams requests are signed with the secondary access key
+	SecondaryAccessKey
+)
+
 // Metrics exposer constants
 const (
 	// SentImage represents sent images stat
@@ -34,4 +41,22 @@ const (
 	TotalImages
 	// ConflictiveImageName represents images with conflictive name
 	ConflictiveImageName
+	// QuarantinedImages represents images rejected by validation stat
+	QuarantinedImages
+	// ActiveAccessKey represents the access key used to sign yams requests
+	ActiveAccessKey
+	// CircuitBreakerState represents the state of each circuit breaker
+	CircuitBreakerState
+	// ConcurrencyLimit represents the adaptive limit of concurrent uploads
+	ConcurrencyLimit
+	// UploadErrors represents failed uploads labelled by error class
+	UploadErrors
+	// DeleteErrors represents failed deletions labelled by error class
+	DeleteErrors
+	// LocalImageErrors represents local images that could not be read
+	// labelled by error class
+	LocalImageErrors
+	// DBErrors represents failures storing the error marks labelled by
+	// operation
+	DBErrors
 )
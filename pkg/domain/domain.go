@@ -6,6 +6,9 @@ import "time"
 type Image struct {
 	Metadata ImageMetadata
 	FilePath string
+	// Path is the image path relative to the local storage, as listed in
+	// the dump file
+	Path string
 }
 
 // ImageMetadata is an image metadata respresentation
@@ -1,10 +1,18 @@
 package infrastructure
 
 import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/sony/gobreaker"
 
+	"github.mpi-internal.com/Yapo/yams-dav-sync/pkg/domain"
+	"github.mpi-internal.com/Yapo/yams-dav-sync/pkg/interfaces"
 	"github.mpi-internal.com/Yapo/yams-dav-sync/pkg/interfaces/loggers"
 )
 
@@ -27,6 +35,13 @@ var (
 
 	// ErrOpenState is returned when the CB state is open
 	ErrOpenState = gobreaker.ErrOpenState
+
+	// errFailureStatus is returned to the CB when the response status code is
+	// classified as failure
+	errFailureStatus = errors.New("response status classified as failure")
+
+	// bucketPath matches the bucket id in yams request paths
+	bucketPath = regexp.MustCompile(`/buckets/([^/?]+)`)
 )
 
 // NewCircuitBreaker initializes circuit breaker wrapper
@@ -36,7 +51,8 @@ var (
 // Interval is the cyclic period of the closed state for the CircuitBreaker to clear the internal Counts.
 // If Interval is 0, the CircuitBreaker doesn't clear internal Counts during the closed state.
 // Timeout is the period of the open state, after which the state of the CircuitBreaker becomes half-open.
-func NewCircuitBreaker(name string, consecutiveFailures uint32, failureRatioTolerance float64, timeout, interval int, logger loggers.Logger) CircuitBreaker {
+// Each state change is exposed in metrics, metrics may be nil
+func NewCircuitBreaker(name string, consecutiveFailures uint32, failureRatioTolerance float64, timeout, interval int, metrics interfaces.MetricsExposer, logger loggers.Logger) CircuitBreaker {
 	settings := gobreaker.Settings{
 		Name:     name,
 		Timeout:  time.Duration(timeout) * (time.Second),
@@ -48,15 +64,136 @@ func NewCircuitBreaker(name string, consecutiveFailures uint32, failureRatioTole
 			return errorRatio >= failureRatioTolerance || counts.ConsecutiveFailures > consecutiveFailures
 		},
 		OnStateChange: func(name string, from gobreaker.State, to gobreaker.State) {
-			logger.Error("CircuitBreaker: Changing status %+v to %+v", from.String(), to.String())
+			logger.Error("CircuitBreaker %s: Changing status %+v to %+v", name, from.String(), to.String())
 			if from == StateClosed {
-				logger.Error("CircuitBreaker: Waiting for open state...")
+				logger.Error("CircuitBreaker %s: Waiting for open state...", name)
+			}
+			if metrics != nil {
+				metrics.SetGaugeWithLabel(domain.CircuitBreakerState, name, float64(to))
 			}
 		},
 	}
+	if metrics != nil {
+		metrics.SetGaugeWithLabel(domain.CircuitBreakerState, name, float64(StateClosed))
+	}
 	return gobreaker.NewCircuitBreaker(settings)
 }
 
+// CircuitBreakers holds one circuit breaker for each operation & bucket, so
+// failures uploading images do not block listing or deleting them. Breakers
+// are created on demand sharing the same settings
+type CircuitBreakers struct {
+	mutex    sync.Mutex
+	breakers map[string]CircuitBreaker
+	// newBreaker creates a new circuit breaker with the given name
+	newBreaker func(name string) CircuitBreaker
+	// failureCodes classifies each response status code, true means failure
+	failureCodes func(code int) bool
+}
+
+// NewCircuitBreakers initializes circuit breakers for each endpoint, all of
+// them sharing the given settings. failureCodes is a comma separated list of
+// response status codes counted as failures by breakers, each code could be
+// an exact code (429), a class (5xx) or a range (500-504). Transport errors
+// are always counted as failures
+func NewCircuitBreakers(name string, consecutiveFailures uint32, failureRatioTolerance float64, timeout, interval int, failureCodes string, metrics interfaces.MetricsExposer, logger loggers.Logger) (*CircuitBreakers, error) {
+	classifier, err := parseFailureCodes(failureCodes)
+	if err != nil {
+		return nil, err
+	}
+	return &CircuitBreakers{
+		breakers: make(map[string]CircuitBreaker),
+		newBreaker: func(breakerName string) CircuitBreaker {
+			return NewCircuitBreaker(
+				name+"_"+breakerName,
+				consecutiveFailures,
+				failureRatioTolerance,
+				timeout,
+				interval,
+				metrics,
+				logger,
+			)
+		},
+		failureCodes: classifier,
+	}, nil
+}
+
+// Get returns the circuit breaker for the given method & path creating it if
+// it does not exist yet
+func (cbs *CircuitBreakers) Get(method, path string) CircuitBreaker {
+	key := method
+	if match := bucketPath.FindStringSubmatch(path); match != nil {
+		key += "_" + match[1]
+	}
+	cbs.mutex.Lock()
+	defer cbs.mutex.Unlock()
+	breaker, ok := cbs.breakers[key]
+	if !ok {
+		breaker = cbs.newBreaker(key)
+		cbs.breakers[key] = breaker
+	}
+	return breaker
+}
+
+// States returns the current state of each circuit breaker by name
+func (cbs *CircuitBreakers) States() map[string]string {
+	cbs.mutex.Lock()
+	defer cbs.mutex.Unlock()
+	states := make(map[string]string)
+	for _, breaker := range cbs.breakers {
+		states[breaker.Name()] = breaker.State().String()
+	}
+	return states
+}
+
+// IsFailure returns true if the response status code must be counted as
+// failure by circuit breakers
+func (cbs *CircuitBreakers) IsFailure(code int) bool {
+	return cbs.failureCodes(code)
+}
+
+// parseFailureCodes parses a comma separated list of status codes, classes
+// or ranges returning a function to classify status codes
+func parseFailureCodes(failureCodes string) (func(code int) bool, error) {
+	type codeRange struct{ from, to int }
+	ranges := []codeRange{}
+	for _, item := range strings.Split(failureCodes, ",") {
+		item = strings.ToLower(strings.TrimSpace(item))
+		switch {
+		case item == "":
+			continue
+		case len(item) == 3 && strings.HasSuffix(item, "xx"):
+			class, err := strconv.Atoi(item[:1])
+			if err != nil {
+				return nil, fmt.Errorf("Invalid circuit breaker failure code %q", item)
+			}
+			ranges = append(ranges, codeRange{class * 100, class*100 + 99})
+		case strings.Contains(item, "-"):
+			limits := strings.SplitN(item, "-", 2)
+			from, errFrom := strconv.Atoi(limits[0])
+			to, errTo := strconv.Atoi(limits[1])
+			if errFrom != nil || errTo != nil || from > to {
+				return nil, fmt.Errorf("Invalid circuit breaker failure code %q", item)
+			}
+			ranges = append(ranges, codeRange{from, to})
+		default:
+			code, err := strconv.Atoi(item)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid circuit breaker failure code %q", item)
+			}
+			ranges = append(ranges, codeRange{code, code})
+		}
+	}
+	return func(code int) bool {
+		for _, r := range ranges {
+			if code >= r.from && code <= r.to {
+				return true
+			}
+		}
+		return false
+	}, nil
+}
+
 // CircuitBreaker allows circuit breaker operations
 type CircuitBreaker interface {
 	// Execute wrapps a function. If the function returns too many errors, circuit breaker
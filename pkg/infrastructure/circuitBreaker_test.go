@@ -0,0 +1,66 @@
+package infrastructure
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/proxy"
+)
+
+func TestParseFailureCodes(t *testing.T) {
+	isFailure, err := parseFailureCodes("5xx, 408,420-429")
+	assert.NoError(t, err)
+	for code, expected := range map[int]bool{
+		200: false, 404: false, 408: true, 419: false, 420: true,
+		429: true, 430: false, 500: true, 503: true, 599: true,
+	} {
+		assert.Equal(t, expected, isFailure(code), "code %d", code)
+	}
+
+	for _, codes := range []string{"abc", "5xy", "429-420", "4xx,x"} {
+		_, err := parseFailureCodes(codes)
+		assert.Error(t, err, codes)
+	}
+}
+
+func TestCircuitBreakersPerEndpoint(t *testing.T) {
+	breakers, err := NewCircuitBreakers("TEST", 10, 0.5, 30, 30, "5xx", nil, testLogger{})
+	assert.NoError(t, err)
+
+	upload := breakers.Get("POST", "/tenants/t/domains/d/buckets/b1/objects")
+	assert.Equal(t, "TEST_POST_b1", upload.Name())
+	assert.Equal(t, upload, breakers.Get("POST", "/tenants/t/domains/d/buckets/b1/objects"))
+	assert.NotEqual(t, upload, breakers.Get("POST", "/tenants/t/domains/d/buckets/b2/objects"))
+	assert.NotEqual(t, upload, breakers.Get("DELETE", "/tenants/t/domains/d/buckets/b1/objects/img"))
+	assert.Equal(t, "TEST_GET", breakers.Get("GET", "/tenants/t/domains").Name())
+}
+
+func TestHTTPHandlerFailureClassification(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	// breakers open with the first failure
+	breakers, err := NewCircuitBreakers("TEST", 0, 2, 30, 30, "5xx", nil, testLogger{})
+	assert.NoError(t, err)
+	handler := NewHTTPHandler(proxy.Direct, breakers, nil, nil, testLogger{})
+	path := server.URL + "/buckets/b1/objects"
+
+	resp, err := handler.Send(handler.NewRequest().SetMethod("GET").SetPath(path))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, resp.Code)
+	assert.Equal(t, StateClosed, breakers.Get("GET", path).State())
+
+	resp, err = handler.Send(handler.NewRequest().SetMethod("POST").SetPath(path))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusServiceUnavailable, resp.Code)
+	assert.Equal(t, StateOpen, breakers.Get("POST", path).State())
+	assert.Equal(t, StateClosed, breakers.Get("GET", path).State())
+}
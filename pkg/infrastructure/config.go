@@ -34,6 +34,14 @@ type Config struct {
 	CircuitBreakerConf CircuitBreakerConf `env:"CIRCUIT_BREAKER_"`
 	BandwidthProxyConf BandwidthProxyConf `env:"BANDWIDTH_PROXY_"`
 	MetricsConf        MetricsConf        `env:"METRICS_"`
+	ImageValidation    ValidationConf     `env:"IMAGE_VALIDATION_"`
+	Concurrency        ConcurrencyConf    `env:"ADAPTIVE_CONCURRENCY_"`
+	RateLimit          RateLimitsConf     `env:"RATE_LIMIT_"`
+	Schedule           ScheduleConf       `env:"SCHEDULE_"`
+	Daemon             DaemonConf         `env:"DAEMON_"`
+	Progress           ProgressConf       `env:"PROGRESS_"`
+	Tracing            TracingConf        `env:"TRACING_"`
+	RunLock            RunLockConf        `env:"RUN_LOCK_"`
 }
 
 // LocalStorage hols all configuration for local storage
@@ -43,23 +51,34 @@ type LocalStorage struct {
 }
 
 // YamsConf holds all configuration for yams remote connection
+// Secondary access key & private key are optional, they are used to rotate
+// credentials without downtime
 type YamsConf struct {
-	MgmtURL            string `env:"MGMT_URL" envDefault:"https://mgmt-us-east-1-yams.schibsted.com/api/v1"`
-	AccessKeyID        string `env:"ACCESS_KEY_ID"`
-	TenantID           string `env:"TENTAND_ID"`
-	DomainID           string `env:"DOMAIN_ID"`
-	BucketID           string `env:"BUCKET_ID"`
-	PrivateKeyFile     string `env:"PRIVATE_KEY" envDefault:"writer-key.rsa"`
-	TimeOut            int    `env:"TiMEOUT" envDefault:"30"`
-	ErrorControlHeader string `env:"ERROR_CONTROL_HEADER" envDefault:"X-YAMS-ERROR"`
-	ErrorControlValue  string `env:"ERROR_CONTROL_VALUE" envDefault:"true"`
-	MaxConcurrentConns int    `env:"MAX_CONCURRENT_CONN" envDefault:"100"`
-}
-
-// ErrorControlConf holds all configurations for error control
+	MgmtURL                 string `env:"MGMT_URL" envDefault:"https://mgmt-us-east-1-yams.schibsted.com/api/v1"`
+	AccessKeyID             string `env:"ACCESS_KEY_ID"`
+	TenantID                string `env:"TENTAND_ID"`
+	DomainID                string `env:"DOMAIN_ID"`
+	BucketID                string `env:"BUCKET_ID"`
+	PrivateKeyFile          string `env:"PRIVATE_KEY" envDefault:"writer-key.rsa"`
+	SecondaryAccessKeyID    string `env:"SECONDARY_ACCESS_KEY_ID" envDefault:""`
+	SecondaryPrivateKeyFile string `env:"SECONDARY_PRIVATE_KEY" envDefault:""`
+	TimeOut                 int    `env:"TiMEOUT" envDefault:"30"`
+	ErrorControlHeader      string `env:"ERROR_CONTROL_HEADER" envDefault:"X-YAMS-ERROR"`
+	ErrorControlValue       string `env:"ERROR_CONTROL_VALUE" envDefault:"true"`
+	MaxConcurrentConns      int    `env:"MAX_CONCURRENT_CONN" envDefault:"100"`
+}
+
+// ErrorControlConf holds all configurations for error control.
+// Failed images are retried after RetryBackoffBase seconds, doubling on each
+// failure up to RetryBackoffMax seconds. Error marks writes are batched up to
+// BatchSize writes or BatchInterval milliseconds, BatchSize 1 disables it
 type ErrorControlConf struct {
 	MaxRetriesPerError int `env:"MAX_RETRIES_PER_ERROR" envDefault:"3"`
-	MaxResultsPerPage  int `env:"MAX_RESULTS_PER_PAGE" envDefault:"10"`
+	MaxResultsPerPage  int `env:"MAX_RESULTS_PER_PAGE" envDefault:"1000"`
+	RetryBackoffBase   int `env:"RETRY_BACKOFF_BASE" envDefault:"60"`
+	RetryBackoffMax    int `env:"RETRY_BACKOFF_MAX" envDefault:"86400"`
+	BatchSize          int `env:"BATCH_SIZE" envDefault:"100"`
+	BatchInterval      int `env:"BATCH_INTERVAL" envDefault:"1000"`
 }
 
 // LastSyncConf holds all configurations for last synchronization marks
@@ -68,39 +87,152 @@ type LastSyncConf struct {
 	DefaultLayout string `env:"DEFAULT_LAYOUT" envDefault:"02-01-2006"`
 }
 
-// DatabaseConf holds all configurations to connect with postgreSQL database
+// DatabaseConf holds all configurations to connect with the database.
+// Driver selects postgres or sqlite3, a single host can keep its state in
+// the local SQLite File instead of a postgreSQL server
 type DatabaseConf struct {
-	Host        string `env:"HOST" envDefault:"db"`
-	Port        int    `env:"PORT" envDefault:"5432"`
-	Dbname      string `env:"NAME" envDefault:"pgdb"`
-	DbUser      string `env:"USER" envDefault:"postgres"`
-	DbPasswd    string `env:"PASSWORD" envDefault:"postgres"`
-	Sslmode     string `env:"SSL_MODE" envDefault:"disable"`
-	MaxIdle     int    `env:"MAX_IDLE" envDefault:"10"`
-	MaxOpen     int    `env:"MAX_OPEN" envDefault:"100"`
-	MgFolder    string `env:"MIGRATIONS_FOLDER" envDefault:"migrations"`
-	MgDriver    string `env:"MIGRATIONS_DRIVER" envDefault:"postgres"`
-	ConnRetries int    `env:"CONN_RETRIES" envDefault:"3"`
-}
-
-// CircuitBreakerConf holds all configurations for circuit breaker
+	Driver         string `env:"DRIVER" envDefault:"postgres"`
+	Host           string `env:"HOST" envDefault:"db"`
+	Port           int    `env:"PORT" envDefault:"5432"`
+	Dbname         string `env:"NAME" envDefault:"pgdb"`
+	DbUser         string `env:"USER" envDefault:"postgres"`
+	DbPasswd       string `env:"PASSWORD" envDefault:"postgres"`
+	Sslmode        string `env:"SSL_MODE" envDefault:"disable"`
+	MaxIdle        int    `env:"MAX_IDLE" envDefault:"10"`
+	MaxOpen        int    `env:"MAX_OPEN" envDefault:"100"`
+	MgFolder       string `env:"MIGRATIONS_FOLDER" envDefault:"migrations"`
+	MgDriver       string `env:"MIGRATIONS_DRIVER" envDefault:"postgres"`
+	ConnRetries    int    `env:"CONN_RETRIES" envDefault:"3"`
+	File           string `env:"FILE" envDefault:"yams-dav-sync.db"`
+	BusyTimeout    int    `env:"BUSY_TIMEOUT" envDefault:"5000"`
+	SqliteMgFolder string `env:"SQLITE_MIGRATIONS_FOLDER" envDefault:"migrations/sqlite"`
+}
+
+// CircuitBreakerConf holds all configurations for circuit breakers, one
+// breaker is created for each operation & bucket. FailureCodes is a comma
+// separated list of status codes counted as failures, codes can be given as
+// exact values (429), classes (5xx) or ranges (500-504)
 type CircuitBreakerConf struct {
 	Name               string  `env:"NAME" envDefault:"HTTP_SEND"`
 	ConsecutiveFailure uint32  `env:"CONSECUTIVE_FAILURE" envDefault:"10"`
 	FailureRatio       float64 `env:"FAILURE_RATIO" envDefault:"0.5"`
 	Timeout            int     `env:"TIMEOUT" envDefault:"30"`
 	Interval           int     `env:"INTERVAL" envDefault:"30"`
+	FailureCodes       string  `env:"FAILURE_CODES" envDefault:"5xx,408,429"`
 }
 
 // BandwidthProxyConf holds all configurations to connect with bandwidth limiter proxy
+// the proxy is optional when the built-in rate limit is used
 type BandwidthProxyConf struct {
+	Enabled  bool   `env:"ENABLED" envDefault:"true"`
 	ConnType string `env:"CONN_TYPE" envDefault:"tcp"`
 	Host     string `env:"HOST" envDefault:"localhost:9999"`
 }
 
-// MetricsConf holds all configurations to export metrics using prometheus
+// MetricsConf holds all configurations to export metrics using prometheus.
+// If PushgatewayURL is set the final metrics of each run are pushed to it
+// grouped by command & Profile
 type MetricsConf struct {
-	Port string `env:"PORT" envDefault:"8877"`
+	Port           string `env:"PORT" envDefault:"8877"`
+	PushgatewayURL string `env:"PUSHGATEWAY_URL" envDefault:""`
+	PushgatewayJob string `env:"PUSHGATEWAY_JOB" envDefault:"yams-dav-sync"`
+	Profile        string `env:"PROFILE" envDefault:"default"`
+}
+
+// ValidationConf holds all configurations to validate images before upload
+type ValidationConf struct {
+	Enabled   bool `env:"ENABLED" envDefault:"false"`
+	MinWidth  int  `env:"MIN_WIDTH" envDefault:"1"`
+	MinHeight int  `env:"MIN_HEIGHT" envDefault:"1"`
+}
+
+// ConcurrencyConf holds all configurations to adapt the number of concurrent
+// uploads to yams load. The limit starts with the threads param, it grows
+// while yams is healthy up to YAMS_MAX_CONCURRENT_CONN and it is multiplied by
+// BackoffRatio on errors or when the latency is over LatencyThreshold (ms)
+type ConcurrencyConf struct {
+	Enabled          bool    `env:"ENABLED" envDefault:"false"`
+	MinLimit         int     `env:"MIN" envDefault:"1"`
+	LatencyThreshold int     `env:"LATENCY_THRESHOLD" envDefault:"5000"`
+	BackoffRatio     float64 `env:"BACKOFF_RATIO" envDefault:"0.5"`
+}
+
+// RateLimitsConf holds the built-in rate limit configuration for each command
+type RateLimitsConf struct {
+	Sync      RateLimitConf `env:"SYNC_"`
+	List      RateLimitConf `env:"LIST_"`
+	DeleteAll RateLimitConf `env:"DELETEALL_"`
+}
+
+// RateLimitConf holds the bandwidth (bytes per second) & request rate
+// (requests per second) limits, zero means no limit. Schedule overrides the
+// limits by time of the day, e.g. "08:00-20:00=2000000/50,20:00-08:00=0/0"
+type RateLimitConf struct {
+	BytesPerSec    int    `env:"BYTES_PER_SEC" envDefault:"0"`
+	RequestsPerSec int    `env:"REQUESTS_PER_SEC" envDefault:"0"`
+	Schedule       string `env:"SCHEDULE" envDefault:""`
+}
+
+// Get returns the rate limit configuration for the given command, commands
+// without configuration are not limited. The daemon runs syncs, so it uses
+// the sync limits
+func (conf RateLimitsConf) Get(command string) RateLimitConf {
+	switch command {
+	case "sync", "daemon":
+		return conf.Sync
+	case "list":
+		return conf.List
+	case "deleteAll":
+		return conf.DeleteAll
+	}
+	return RateLimitConf{}
+}
+
+// ScheduleConf holds all configurations to schedule the synchronization.
+// SyncWindows is a comma separated list of HH:MM-HH:MM windows when sync is
+// allowed, out of them the sync is paused. Empty means always allowed
+type ScheduleConf struct {
+	SyncWindows string `env:"SYNC_WINDOWS" envDefault:""`
+}
+
+// DaemonConf holds all configurations to run as daemon. SyncInterval is the
+// period in minutes between scheduled syncs, zero means only on demand syncs.
+// SortCommand is executed before each sync to generate the dump file
+type DaemonConf struct {
+	APIPort      string `env:"API_PORT" envDefault:"8878"`
+	APIToken     string `env:"API_TOKEN" envDefault:""`
+	SyncInterval int    `env:"SYNC_INTERVAL" envDefault:"60"`
+	SortCommand  string `env:"SORT_COMMAND" envDefault:""`
+}
+
+// ProgressConf holds all configurations to report the sync progress.
+// LogInterval is the period in seconds between progress logs, zero disables
+// them
+type ProgressConf struct {
+	LogInterval int `env:"LOG_INTERVAL" envDefault:"30"`
+}
+
+// TracingConf holds all configurations to trace the sync stages.
+// Exporter is either otlp, sending spans over http to Endpoint, or stdout.
+// SampleRatio is the fraction of sync operations traced
+type TracingConf struct {
+	Enabled     bool    `env:"ENABLED" envDefault:"false"`
+	Exporter    string  `env:"EXPORTER" envDefault:"otlp"`
+	Endpoint    string  `env:"ENDPOINT" envDefault:"localhost:4318"`
+	Insecure    bool    `env:"INSECURE" envDefault:"true"`
+	ServiceName string  `env:"SERVICE_NAME" envDefault:"yams-dav-sync"`
+	SampleRatio float64 `env:"SAMPLE_RATIO" envDefault:"1"`
+}
+
+// RunLockConf holds all configurations of the run lock taken by sync &
+// deleteAll for the metrics profile. Mode is either advisory, a postgres
+// advisory lock, or table, a row in sync_lock refreshed every Heartbeat
+// seconds and taken over when stale for StaleAfter seconds. Sqlite always
+// uses the table
+type RunLockConf struct {
+	Mode       string `env:"MODE" envDefault:"advisory"`
+	StaleAfter int    `env:"STALE_AFTER" envDefault:"300"`
+	Heartbeat  int    `env:"HEARTBEAT" envDefault:"30"`
 }
 
 // LoadFromEnv loads the config data from the environment variables
@@ -49,3 +49,16 @@ func TestConfigLoad(t *testing.T) {
 
 	assert.Equal(t, expected, conf)
 }
+
+func TestRateLimitsConfGet(t *testing.T) {
+	conf := RateLimitsConf{
+		Sync:      RateLimitConf{BytesPerSec: 1},
+		List:      RateLimitConf{BytesPerSec: 2},
+		DeleteAll: RateLimitConf{BytesPerSec: 3},
+	}
+	assert.Equal(t, conf.Sync, conf.Get("sync"))
+	assert.Equal(t, conf.Sync, conf.Get("daemon"))
+	assert.Equal(t, conf.List, conf.Get("list"))
+	assert.Equal(t, conf.DeleteAll, conf.Get("deleteAll"))
+	assert.Equal(t, RateLimitConf{}, conf.Get("delete"))
+}
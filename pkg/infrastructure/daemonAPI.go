@@ -0,0 +1,114 @@
+package infrastructure
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.mpi-internal.com/Yapo/yams-dav-sync/pkg/interfaces"
+	"github.mpi-internal.com/Yapo/yams-dav-sync/pkg/interfaces/loggers"
+)
+
+// DaemonController allows operations to control the daemon jobs
+type DaemonController interface {
+	Trigger() error
+	Pause()
+	Resume()
+	Cancel() error
+	Status() interfaces.DaemonStatus
+}
+
+// DaemonAPI exposes an HTTP API to control the daemon, every request must be
+// authenticated with the header "Authorization: Bearer <token>"
+type DaemonAPI struct {
+	daemon DaemonController
+	token  string
+	server *http.Server
+	logger loggers.Logger
+}
+
+// NewDaemonAPI creates a new instance of DaemonAPI listening in the given port
+// exposing the following endpoints:
+// GET /status returns the daemon status as json
+// POST /sync triggers a new sync
+// POST /pause pauses the current & next syncs
+// POST /resume resumes syncs
+// POST /cancel cancels the current sync
+func NewDaemonAPI(port, token string, daemon DaemonController, logger loggers.Logger) *DaemonAPI {
+	api := &DaemonAPI{
+		daemon: daemon,
+		token:  token,
+		logger: logger,
+	}
+	api.server = &http.Server{Addr: ":" + port, Handler: api.handler()}
+	go func() {
+		if err := api.server.ListenAndServe(); err != http.ErrServerClosed {
+			api.logger.Error("Daemon API: %s", err)
+		}
+	}()
+	return api
+}
+
+// handler returns the API routes
+func (api *DaemonAPI) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/status", api.authenticated(http.MethodGet, func(w http.ResponseWriter, r *http.Request) {
+		api.writeJSON(w, http.StatusOK, api.daemon.Status())
+	}))
+	mux.HandleFunc("/sync", api.authenticated(http.MethodPost, func(w http.ResponseWriter, r *http.Request) {
+		api.writeResult(w, http.StatusAccepted, api.daemon.Trigger())
+	}))
+	mux.HandleFunc("/pause", api.authenticated(http.MethodPost, func(w http.ResponseWriter, r *http.Request) {
+		api.daemon.Pause()
+		api.writeResult(w, http.StatusOK, nil)
+	}))
+	mux.HandleFunc("/resume", api.authenticated(http.MethodPost, func(w http.ResponseWriter, r *http.Request) {
+		api.daemon.Resume()
+		api.writeResult(w, http.StatusOK, nil)
+	}))
+	mux.HandleFunc("/cancel", api.authenticated(http.MethodPost, func(w http.ResponseWriter, r *http.Request) {
+		api.writeResult(w, http.StatusOK, api.daemon.Cancel())
+	}))
+	return mux
+}
+
+// authenticated wraps the handler checking the method & the bearer token
+func (api *DaemonAPI) authenticated(method string, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if api.token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(api.token)) != 1 {
+			api.writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "unauthorized"})
+			return
+		}
+		if r.Method != method {
+			api.writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+			return
+		}
+		handler(w, r)
+	}
+}
+
+// writeResult writes the result of a daemon operation, daemon errors are
+// conflicts with the daemon state
+func (api *DaemonAPI) writeResult(w http.ResponseWriter, status int, err error) {
+	if err != nil {
+		api.writeJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+		return
+	}
+	api.writeJSON(w, status, map[string]string{"status": "ok"})
+}
+
+// writeJSON writes the body as json with the given status code
+func (api *DaemonAPI) writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		api.logger.Error("Daemon API: error writing response: %+v", err)
+	}
+}
+
+// Close closes the API server
+func (api *DaemonAPI) Close() error {
+	return api.server.Close()
+}
@@ -0,0 +1,87 @@
+package infrastructure
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.mpi-internal.com/Yapo/yams-dav-sync/pkg/interfaces"
+)
+
+type mockDaemonController struct {
+	mock.Mock
+}
+
+func (m *mockDaemonController) Trigger() error {
+	args := m.Called()
+	return args.Error(0)
+}
+
+func (m *mockDaemonController) Pause() {
+	m.Called()
+}
+
+func (m *mockDaemonController) Resume() {
+	m.Called()
+}
+
+func (m *mockDaemonController) Cancel() error {
+	args := m.Called()
+	return args.Error(0)
+}
+
+func (m *mockDaemonController) Status() interfaces.DaemonStatus {
+	args := m.Called()
+	return args.Get(0).(interfaces.DaemonStatus)
+}
+
+func TestDaemonAPI(t *testing.T) {
+	mDaemon := &mockDaemonController{}
+	api := &DaemonAPI{daemon: mDaemon, token: "secret", logger: testLogger{}}
+	handler := api.handler()
+
+	mDaemon.On("Status").Return(interfaces.DaemonStatus{Running: true})
+	mDaemon.On("Trigger").Return(interfaces.ErrDaemonJobRunning)
+	mDaemon.On("Pause")
+	mDaemon.On("Resume")
+	mDaemon.On("Cancel").Return(nil)
+
+	testCases := []struct {
+		method string
+		path   string
+		token  string
+		code   int
+		body   string
+	}{
+		{"GET", "/status", "secret", http.StatusOK, `"running":true`},
+		{"GET", "/status", "", http.StatusUnauthorized, "unauthorized"},
+		{"GET", "/status", "wrong", http.StatusUnauthorized, "unauthorized"},
+		{"GET", "/sync", "secret", http.StatusMethodNotAllowed, "method not allowed"},
+		{"POST", "/sync", "secret", http.StatusConflict, interfaces.ErrDaemonJobRunning.Error()},
+		{"POST", "/pause", "secret", http.StatusOK, "ok"},
+		{"POST", "/resume", "secret", http.StatusOK, "ok"},
+		{"POST", "/cancel", "secret", http.StatusOK, "ok"},
+	}
+	for _, testCase := range testCases {
+		req := httptest.NewRequest(testCase.method, testCase.path, nil)
+		if testCase.token != "" {
+			req.Header.Set("Authorization", "Bearer "+testCase.token)
+		}
+		resp := httptest.NewRecorder()
+		handler.ServeHTTP(resp, req)
+		assert.Equal(t, testCase.code, resp.Code, testCase.path)
+		assert.Contains(t, resp.Body.String(), testCase.body, testCase.path)
+	}
+	mDaemon.AssertExpectations(t)
+}
+
+func TestDaemonAPIWithoutToken(t *testing.T) {
+	api := &DaemonAPI{daemon: &mockDaemonController{}, logger: testLogger{}}
+	req := httptest.NewRequest("GET", "/status", nil)
+	req.Header.Set("Authorization", "Bearer ")
+	resp := httptest.NewRecorder()
+	api.handler().ServeHTTP(resp, req)
+	assert.Equal(t, http.StatusUnauthorized, resp.Code)
+}
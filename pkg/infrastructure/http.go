@@ -21,19 +21,37 @@ var errorCodes = map[int]string{
 	http.StatusInternalServerError: "Internal server error",
 }
 
+// RequestObserver records the metrics of each request sent to yams.
+// operation is one of put, head, delete, list or get & code is zero when no
+// response was received
+type RequestObserver interface {
+	ObserveRequest(operation, method string, code int, duration time.Duration, requestBytes, responseBytes int64)
+}
+
 // HTTPHandler struct to implements http repository operations
 type HTTPHandler struct {
-	dialer         proxy.Dialer
-	circuitBreaker CircuitBreaker
-	logger         loggers.Logger
+	dialer          proxy.Dialer
+	circuitBreakers *CircuitBreakers
+	rateLimiter     *RateLimiter
+	observer        RequestObserver
+	logger          loggers.Logger
 }
 
 // NewHTTPHandler will create a new instance of a custom http request handler
-func NewHTTPHandler(dialer interface{}, circuitBreaker CircuitBreaker, logger loggers.Logger) repository.HTTPHandler {
+// if rateLimiter is not nil then requests, connections & image bodies are
+// limited by it. If observer is not nil then it records every request sent
+func NewHTTPHandler(dialer interface{}, circuitBreakers *CircuitBreakers, rateLimiter *RateLimiter,
+	observer RequestObserver, logger loggers.Logger) repository.HTTPHandler {
+	proxyDialer := dialer.(proxy.Dialer)
+	if rateLimiter != nil {
+		proxyDialer = rateLimiter.Dialer(proxyDialer)
+	}
 	return &HTTPHandler{
-		dialer:         dialer.(proxy.Dialer),
-		circuitBreaker: circuitBreaker,
-		logger:         logger,
+		dialer:          proxyDialer,
+		circuitBreakers: circuitBreakers,
+		rateLimiter:     rateLimiter,
+		observer:        observer,
+		logger:          logger,
 	}
 }
 
@@ -53,17 +71,38 @@ func (h *HTTPHandler) Send(req repository.HTTPRequest) (repository.HTTPResponse,
 		httpTransport.Dial = h.dialer.Dial // nolint
 	}
 	request := &req.(*request).innerRequest
+	sentBody := &countingReadCloser{}
+	if request.Body != nil {
+		sentBody.ReadCloser = request.Body
+		request.Body = sentBody
+	}
 
-	var response interface{}
-	var err error
-	// do-while: try once or retry until circuit breaker closes
-	for ok := true; ok; ok = (err == ErrOpenState || err == ErrTooManyRequests) {
-		response, err = h.circuitBreaker.Execute(func() (interface{}, error) {
-			return httpClient.Do(request)
-		})
+	if h.rateLimiter != nil {
+		h.rateLimiter.WaitRequest()
+	}
+	start := time.Now()
+	circuitBreaker := h.circuitBreakers.Get(req.GetMethod(), request.URL.Path)
+	response, err := circuitBreaker.Execute(func() (interface{}, error) {
+		resp, err := httpClient.Do(request)
+		if err == nil && h.circuitBreakers.IsFailure(resp.StatusCode) {
+			return resp, errFailureStatus
+		}
+		return resp, err
+	})
+	// responses classified as failure only count for the circuit breaker
+	if err == errFailureStatus {
+		err = nil
+	}
+	// the request is not sent while the circuit breaker rejects requests, the
+	// caller retries it later with a new request
+	if err == ErrOpenState || err == ErrTooManyRequests {
+		h.logger.Error("HTTP - %s - Request rejected by circuit breaker %s: %+v",
+			req.GetMethod(), circuitBreaker.Name(), err)
+		return repository.HTTPResponse{}, repository.ErrRequestRejected
 	}
 	if err != nil {
 		h.logger.Error("HTTP - %s - Error sending HTTP request: %+v", req.GetMethod(), err)
+		h.observe(request, 0, start, sentBody.n, 0)
 		return repository.HTTPResponse{
 				Code: http.StatusBadRequest,
 			},
@@ -74,6 +113,7 @@ func (h *HTTPHandler) Send(req repository.HTTPRequest) (repository.HTTPResponse,
 	request.Close = true
 
 	body, err := ioutil.ReadAll(resp.Body)
+	h.observe(request, resp.StatusCode, start, sentBody.n, int64(len(body)))
 	if val, ok := errorCodes[resp.StatusCode]; ok {
 		h.logger.Error("HTTP - %s - Received an error response: %+v", req.GetMethod(), val)
 		return repository.HTTPResponse{
@@ -96,11 +136,58 @@ func (h *HTTPHandler) Send(req repository.HTTPRequest) (repository.HTTPResponse,
 
 }
 
+// observe records the request metrics if the handler has an observer
+func (h *HTTPHandler) observe(request *http.Request, code int, start time.Time, requestBytes, responseBytes int64) {
+	if h.observer == nil {
+		return
+	}
+	h.observer.ObserveRequest(
+		requestOperation(request.Method, request.URL.Path),
+		request.Method,
+		code,
+		time.Since(start),
+		requestBytes,
+		responseBytes,
+	)
+}
+
+// requestOperation returns the yams operation of a request: images are
+// uploaded with POST & listed with GET on the objects collection
+func requestOperation(method, path string) string {
+	switch method {
+	case http.MethodPost, http.MethodPut:
+		return "put"
+	case http.MethodHead:
+		return "head"
+	case http.MethodDelete:
+		return "delete"
+	case http.MethodGet:
+		if strings.HasSuffix(path, "/objects") {
+			return "list"
+		}
+	}
+	return strings.ToLower(method)
+}
+
+// countingReadCloser counts the bytes read from a request body
+type countingReadCloser struct {
+	io.ReadCloser
+	n int64
+}
+
+// Read reads from the body counting the bytes read
+func (c *countingReadCloser) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
 // request is a custom golang http.Request
 type request struct {
 	innerRequest http.Request
 	body         interface{}
 	timeOut      time.Duration
+	rateLimiter  *RateLimiter
 	logger       loggers.Logger
 }
 
@@ -110,8 +197,9 @@ func (h *HTTPHandler) NewRequest() repository.HTTPRequest {
 		innerRequest: http.Request{
 			Header: make(http.Header),
 		},
-		timeOut: time.Duration(10),
-		logger:  h.logger,
+		timeOut:     time.Duration(10),
+		rateLimiter: h.rateLimiter,
+		logger:      h.logger,
 	}
 }
 
@@ -178,9 +266,14 @@ func (r *request) SetBody(body interface{}) repository.HTTPRequest {
 
 // SetImgBody will set a custom img body to the request.
 // this method will also set the custom header Content-type to images/jpg
+// the body bandwidth is limited by the handler rate limiter if any
 func (r *request) SetImgBody(body io.Reader) repository.HTTPRequest {
 	r.SetHeaders(map[string]string{"Content-type": "images/jpg"})
-	r.innerRequest.Body = ioutil.NopCloser(body)
+	if r.rateLimiter != nil {
+		r.innerRequest.Body = ioutil.NopCloser(r.rateLimiter.Reader(body))
+	} else {
+		r.innerRequest.Body = ioutil.NopCloser(body)
+	}
 	r.body = body
 	return r
 }
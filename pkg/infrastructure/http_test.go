@@ -0,0 +1,110 @@
+package infrastructure
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.mpi-internal.com/Yapo/yams-dav-sync/pkg/interfaces/repository"
+	"golang.org/x/net/proxy"
+)
+
+type observedRequest struct {
+	operation     string
+	method        string
+	code          int
+	requestBytes  int64
+	responseBytes int64
+}
+
+type fakeRequestObserver struct {
+	requests []observedRequest
+}
+
+func (o *fakeRequestObserver) ObserveRequest(operation, method string, code int, duration time.Duration,
+	requestBytes, responseBytes int64) {
+	o.requests = append(o.requests, observedRequest{operation, method, code, requestBytes, responseBytes})
+}
+
+func TestHTTPHandlerObserveRequests(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "HEAD" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte("ok")) // nolint: errcheck
+	}))
+	defer server.Close()
+
+	breakers, err := NewCircuitBreakers("TEST", 10, 1, 30, 30, "5xx", nil, testLogger{})
+	assert.NoError(t, err)
+	observer := &fakeRequestObserver{}
+	handler := NewHTTPHandler(proxy.Direct, breakers, nil, observer, testLogger{})
+	objects := server.URL + "/buckets/b1/objects"
+
+	_, err = handler.Send(handler.NewRequest().SetMethod("POST").SetPath(objects).
+		SetImgBody(strings.NewReader("image")))
+	assert.NoError(t, err)
+	_, err = handler.Send(handler.NewRequest().SetMethod("HEAD").SetPath(objects + "/foo.jpg"))
+	assert.NoError(t, err)
+	_, err = handler.Send(handler.NewRequest().SetMethod("GET").SetPath(objects))
+	assert.NoError(t, err)
+
+	assert.Equal(t, []observedRequest{
+		{"put", "POST", http.StatusOK, 5, 2},
+		{"head", "HEAD", http.StatusNotFound, 0, 0},
+		{"list", "GET", http.StatusOK, 0, 2},
+	}, observer.requests)
+}
+
+func TestHTTPHandlerObserveConnectionError(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	path := server.URL + "/buckets/b1/objects/foo.jpg"
+	server.Close()
+
+	breakers, err := NewCircuitBreakers("TEST", 10, 1, 30, 30, "5xx", nil, testLogger{})
+	assert.NoError(t, err)
+	observer := &fakeRequestObserver{}
+	handler := NewHTTPHandler(proxy.Direct, breakers, nil, observer, testLogger{})
+
+	_, err = handler.Send(handler.NewRequest().SetMethod("DELETE").SetPath(path))
+	assert.Error(t, err)
+	assert.Equal(t, []observedRequest{{"delete", "DELETE", 0, 0, 0}}, observer.requests)
+}
+
+func TestHTTPHandlerCircuitBreakerOpen(t *testing.T) {
+	sent := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sent++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	breakers, err := NewCircuitBreakers("TEST", 1, 1, 30, 30, "5xx", nil, testLogger{})
+	assert.NoError(t, err)
+	observer := &fakeRequestObserver{}
+	handler := NewHTTPHandler(proxy.Direct, breakers, nil, observer, testLogger{})
+	objects := server.URL + "/buckets/b1/objects"
+
+	resp, err := handler.Send(handler.NewRequest().SetMethod("POST").SetPath(objects).
+		SetImgBody(strings.NewReader("image")))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusServiceUnavailable, resp.Code)
+	// the breaker is open, the request is rejected without being sent
+	_, err = handler.Send(handler.NewRequest().SetMethod("POST").SetPath(objects).
+		SetImgBody(strings.NewReader("image")))
+	assert.Equal(t, repository.ErrRequestRejected, err)
+	assert.Equal(t, 1, sent)
+	assert.Len(t, observer.requests, 1)
+}
+
+func TestRequestOperation(t *testing.T) {
+	assert.Equal(t, "put", requestOperation("POST", "/buckets/b1/objects"))
+	assert.Equal(t, "head", requestOperation("HEAD", "/buckets/b1/objects/foo.jpg"))
+	assert.Equal(t, "delete", requestOperation("DELETE", "/buckets/b1/objects/foo.jpg"))
+	assert.Equal(t, "list", requestOperation("GET", "/buckets/b1/objects"))
+	assert.Equal(t, "get", requestOperation("GET", "/tenants/t1/domains"))
+}
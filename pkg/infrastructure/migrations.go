@@ -0,0 +1,179 @@
+package infrastructure
+
+import (
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strconv"
+
+	"github.com/mattes/migrate"
+	"github.com/mattes/migrate/database"
+	mpgsql "github.com/mattes/migrate/database/postgres"
+	msqlite "github.com/mattes/migrate/database/sqlite3"
+	_ "github.com/mattes/migrate/source/file" // nolint
+	"github.mpi-internal.com/Yapo/yams-dav-sync/pkg/interfaces/loggers"
+	"github.mpi-internal.com/Yapo/yams-dav-sync/pkg/interfaces/repository"
+)
+
+// schema is the migrations state of the database
+type schema interface {
+	Version() (version uint, dirty bool, err error)
+	Up() error
+	Steps(n int) error
+	Force(version int) error
+}
+
+// Migrations applies & rolls back the database migrations, the commands only
+// run when the schema is at the latest migration of the folder
+type Migrations struct {
+	schema schema
+	latest uint
+	logger loggers.Logger
+}
+
+// migrationFile matches the up migrations, the version prefixes the name
+var migrationFile = regexp.MustCompile(`^(\d+)_.*\.up\.sql$`)
+
+// NewMigrations creates the migrations of the database handled by dbHandler,
+// each driver has its own migrations folder
+func NewMigrations(conf DatabaseConf, dbHandler repository.DbHandler, logger loggers.Logger) (*Migrations, error) {
+	var driver database.Driver
+	var folder, driverName string
+	var err error
+	switch handler := dbHandler.(type) {
+	case *PgsqlHandler:
+		driver, err = mpgsql.WithInstance(handler.Conn, &mpgsql.Config{})
+		folder, driverName = conf.MgFolder, conf.MgDriver
+	case *SqliteHandler:
+		driver, err = msqlite.WithInstance(handler.Conn, &msqlite.Config{})
+		folder, driverName = conf.SqliteMgFolder, "sqlite3"
+	default:
+		err = fmt.Errorf("Migrations are not supported by %T", dbHandler)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Error to instance migrations: %+v", err)
+	}
+	latest, err := latestMigration(folder)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading migrations folder %s: %+v", folder, err)
+	}
+	mig, err := migrate.NewWithDatabaseInstance("file://"+folder, driverName, driver)
+	if err != nil {
+		return nil, fmt.Errorf("Consume migrations sources err: %+v", err)
+	}
+	return &Migrations{
+		schema: mig,
+		latest: latest,
+		logger: logger,
+	}, nil
+}
+
+// latestMigration gets the highest version of the up migrations in folder
+func latestMigration(folder string) (latest uint, err error) {
+	files, err := ioutil.ReadDir(folder)
+	if err != nil {
+		return 0, err
+	}
+	for _, file := range files {
+		match := migrationFile.FindStringSubmatch(file.Name())
+		if file.IsDir() || match == nil {
+			continue
+		}
+		version, err := strconv.ParseUint(match[1], 10, 32)
+		if err != nil {
+			return 0, err
+		}
+		if uint(version) > latest {
+			latest = uint(version)
+		}
+	}
+	return latest, nil
+}
+
+// Version gets the current version of the schema, whether the last migration
+// failed leaving it dirty & the latest version available
+func (m *Migrations) Version() (version uint, dirty bool, latest uint, err error) {
+	version, dirty, err = m.schema.Version()
+	if err == migrate.ErrNilVersion {
+		// no migration was applied yet
+		return 0, false, m.latest, nil
+	}
+	return version, dirty, m.latest, err
+}
+
+// Check returns an error if the schema is dirty or behind the latest version
+func (m *Migrations) Check() error {
+	version, dirty, latest, err := m.Version()
+	if err != nil {
+		return fmt.Errorf("Error getting current migration version: %+v", err)
+	}
+	if dirty {
+		return fmt.Errorf("Database schema is dirty at version %d, fix the failed "+
+			"migration & run: -command=migrate force %d", version, version)
+	}
+	if version < latest {
+		return fmt.Errorf("Database schema is at version %d, version %d is required, "+
+			"run: -command=migrate up", version, latest)
+	}
+	return nil
+}
+
+// Up applies every pending migration
+func (m *Migrations) Up() error {
+	err := m.schema.Up()
+	if err == migrate.ErrNoChange {
+		m.logger.Info("No pending migrations")
+		return nil
+	}
+	return err
+}
+
+// Down rolls back the last n migrations
+func (m *Migrations) Down(n int) error {
+	if n < 1 {
+		return fmt.Errorf("The number of migrations to roll back must be positive, got %d", n)
+	}
+	return m.schema.Steps(-n)
+}
+
+// Force sets the schema version without running any migration, cleaning the
+// dirty flag once a failed migration was fixed by hand
+func (m *Migrations) Force(version int) error {
+	return m.schema.Force(version)
+}
+
+// Run executes a migrate subcommand: up, down N, version or force V
+func (m *Migrations) Run(args []string) (err error) {
+	if len(args) == 0 {
+		return fmt.Errorf("missing subcommand: up, down N, version or force V")
+	}
+	switch args[0] {
+	case "up":
+		err = m.Up()
+	case "down", "force":
+		if len(args) < 2 {
+			return fmt.Errorf("missing version or number of migrations for %s", args[0])
+		}
+		n, e := strconv.Atoi(args[1])
+		if e != nil {
+			return fmt.Errorf("wrong number %s for %s: %+v", args[1], args[0], e)
+		}
+		if args[0] == "down" {
+			err = m.Down(n)
+		} else {
+			err = m.Force(n)
+		}
+	case "version":
+	default:
+		return fmt.Errorf("unknown migrate subcommand %s", args[0])
+	}
+	if err != nil {
+		return err
+	}
+	version, dirty, latest, err := m.Version()
+	if err != nil {
+		return err
+	}
+	m.logger.Info("Migrations version %d of %d, dirty: %t", version, latest, dirty)
+	return nil
+}
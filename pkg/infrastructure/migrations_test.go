@@ -0,0 +1,90 @@
+package infrastructure
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mattes/migrate"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeSchema struct {
+	version uint
+	dirty   bool
+	err     error
+	steps   []int
+	forced  []int
+}
+
+func (s *fakeSchema) Version() (uint, bool, error) { return s.version, s.dirty, s.err }
+func (s *fakeSchema) Up() error                    { return migrate.ErrNoChange }
+func (s *fakeSchema) Steps(n int) error            { s.steps = append(s.steps, n); return nil }
+func (s *fakeSchema) Force(version int) error      { s.forced = append(s.forced, version); return nil }
+
+func TestLatestMigration(t *testing.T) {
+	folder, err := ioutil.TempDir("", "migrations")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(folder) // nolint
+	for _, name := range []string{"1_a.up.sql", "1_a.down.sql", "12_b.up.sql", "12_b.down.sql", "3_c.up.sql", "notes.txt"} {
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(folder, name), []byte(""), 0644))
+	}
+	assert.NoError(t, os.Mkdir(filepath.Join(folder, "99_sqlite.up.sql"), 0755))
+
+	latest, err := latestMigration(folder)
+	assert.NoError(t, err)
+	assert.Equal(t, uint(12), latest)
+
+	_, err = latestMigration(filepath.Join(folder, "missing"))
+	assert.Error(t, err)
+}
+
+func TestMigrationsCheck(t *testing.T) {
+	testCases := []struct {
+		schema *fakeSchema
+		ok     bool
+	}{
+		{&fakeSchema{version: 7}, true},
+		{&fakeSchema{version: 8}, true},
+		{&fakeSchema{version: 6}, false},
+		{&fakeSchema{version: 7, dirty: true}, false},
+		{&fakeSchema{err: migrate.ErrNilVersion}, false},
+		{&fakeSchema{err: fmt.Errorf("err")}, false},
+	}
+	for _, testCase := range testCases {
+		migrations := &Migrations{schema: testCase.schema, latest: 7, logger: testLogger{}}
+		err := migrations.Check()
+		assert.Equal(t, testCase.ok, err == nil, "%+v: %+v", testCase.schema, err)
+	}
+}
+
+func TestMigrationsRun(t *testing.T) {
+	schema := &fakeSchema{version: 7}
+	migrations := &Migrations{schema: schema, latest: 7, logger: testLogger{}}
+
+	assert.NoError(t, migrations.Run([]string{"up"}))
+	assert.NoError(t, migrations.Run([]string{"version"}))
+	assert.NoError(t, migrations.Run([]string{"down", "2"}))
+	assert.NoError(t, migrations.Run([]string{"force", "5"}))
+	assert.Equal(t, []int{-2}, schema.steps)
+	assert.Equal(t, []int{5}, schema.forced)
+
+	assert.Error(t, migrations.Run(nil))
+	assert.Error(t, migrations.Run([]string{"down"}))
+	assert.Error(t, migrations.Run([]string{"down", "0"}))
+	assert.Error(t, migrations.Run([]string{"force", "x"}))
+	assert.Error(t, migrations.Run([]string{"sideways"}))
+}
+
+func TestMigrationsFoldersInSync(t *testing.T) {
+	postgres, err := latestMigration("../../migrations")
+	assert.NoError(t, err)
+	sqlite, err := latestMigration("../../migrations/sqlite")
+	assert.NoError(t, err)
+	// every migration is ported to sqlite with the same version
+	assert.Equal(t, postgres, sqlite)
+}
@@ -55,6 +55,60 @@ func (handler *PgsqlHandler) Query(statement string, params ...interface{}) (rep
 	}, nil
 }
 
+// Begin starts a new transaction
+func (handler *PgsqlHandler) Begin() (repository.DbTx, error) {
+	tx, err := handler.Conn.Begin()
+	if err != nil {
+		handler.Logger.Error("Begin transaction error: %+v", err)
+		return nil, err
+	}
+	return PgsqlTx{
+		Tx:     tx,
+		Logger: handler.Logger,
+	}, nil
+}
+
+// PgsqlTx is a transaction in progress
+type PgsqlTx struct {
+	Tx     *sql.Tx
+	Logger loggers.Logger
+}
+
+// Insert implements the incorporation of new data in the transaction
+func (tx PgsqlTx) Insert(statement string, params ...interface{}) error {
+	_, err := tx.Tx.Exec(statement, params...)
+	return err
+}
+
+// Update implements the actualization of registers in the transaction
+func (tx PgsqlTx) Update(statement string, params ...interface{}) error {
+	_, err := tx.Tx.Exec(statement, params...)
+	return err
+}
+
+// Query send statement to db in the transaction returning rows
+func (tx PgsqlTx) Query(statement string, params ...interface{}) (repository.DbResult, error) {
+	rows, err := tx.Tx.Query(statement, params...)
+	if err != nil {
+		tx.Logger.Error("Query error: %+v", err)
+		return new(PgsqlRow), err
+	}
+	return PgsqlRow{
+		Rows:   rows,
+		Logger: tx.Logger,
+	}, nil
+}
+
+// Commit applies every statement of the transaction
+func (tx PgsqlTx) Commit() error {
+	return tx.Tx.Commit()
+}
+
+// Rollback discards every statement of the transaction
+func (tx PgsqlTx) Rollback() error {
+	return tx.Tx.Rollback()
+}
+
 // PgsqlRow stores a group of DB registers
 type PgsqlRow struct {
 	Rows   *sql.Rows
@@ -0,0 +1,188 @@
+package infrastructure
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.mpi-internal.com/Yapo/yams-dav-sync/pkg/interfaces"
+)
+
+// lockApplicationPrefix prefixes the application name of the session holding
+// the lock, the name describes the holder
+const lockApplicationPrefix = "yams-dav-sync"
+
+// PgsqlRunLock is a run lock on a postgres advisory lock keyed by profile.
+// Postgres releases it when the session holding it ends, so a dead process
+// never keeps it. The holder is described in the application name of the
+// session
+type PgsqlRunLock struct {
+	db      *sql.DB
+	profile string
+	key     int64
+	host    string
+	pid     int
+	mutex   sync.Mutex
+	conn    *sql.Conn
+}
+
+// NewPgsqlRunLock creates a run lock for the profile on the postgres database,
+// identifying this process by host & pid
+func NewPgsqlRunLock(handler *PgsqlHandler, profile, host string, pid int) *PgsqlRunLock {
+	return &PgsqlRunLock{
+		db:      handler.Conn,
+		profile: profile,
+		key:     advisoryLockKey(profile),
+		host:    host,
+		pid:     pid,
+	}
+}
+
+// advisoryLockKey hashes the profile to the key of its advisory lock
+func advisoryLockKey(profile string) int64 {
+	hash := fnv.New64a()
+	hash.Write([]byte(lockApplicationPrefix + ":" + profile)) // nolint
+	return int64(hash.Sum64())
+}
+
+// lockApplicationName describes the holder in the application name of the
+// session, postgres keeps up to 63 bytes
+func lockApplicationName(command string, pid int, host string) string {
+	name := fmt.Sprintf("%s:%s:%d:%s", lockApplicationPrefix, command, pid, host)
+	if len(name) > 63 {
+		name = name[:63]
+	}
+	return name
+}
+
+// parseLockApplicationName gets the holder from the application name of the
+// session, false if it was not set by a run lock
+func parseLockApplicationName(name string) (holder interfaces.LockHolder, ok bool) {
+	parts := strings.SplitN(name, ":", 4)
+	if len(parts) != 4 || parts[0] != lockApplicationPrefix {
+		return holder, false
+	}
+	pid, err := strconv.Atoi(parts[2])
+	if err != nil {
+		return holder, false
+	}
+	holder.Command = parts[1]
+	holder.PID = pid
+	holder.Host = parts[3]
+	return holder, true
+}
+
+// TryAcquire takes the advisory lock in a session kept open until the lock is
+// released
+func (l *PgsqlRunLock) TryAcquire(command string) (bool, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.conn != nil {
+		// this process is running with the lock
+		return false, nil
+	}
+	ctx := context.Background()
+	conn, err := l.db.Conn(ctx)
+	if err != nil {
+		return false, err
+	}
+	var acquired bool
+	err = conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", l.key).Scan(&acquired)
+	if err != nil || !acquired {
+		conn.Close() // nolint
+		return false, err
+	}
+	_, err = conn.ExecContext(ctx, "SELECT set_config('application_name', $1, false)",
+		lockApplicationName(command, l.pid, l.host))
+	if err != nil {
+		conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", l.key) // nolint
+		conn.Close()                                                  // nolint
+		return false, err
+	}
+	l.conn = conn
+	return true, nil
+}
+
+// Release frees the advisory lock & returns the session to the pool
+func (l *PgsqlRunLock) Release() error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.conn == nil {
+		return nil
+	}
+	ctx := context.Background()
+	_, err := l.conn.ExecContext(ctx,
+		"SELECT pg_advisory_unlock($1), set_config('application_name', '', false)", l.key)
+	if e := l.conn.Close(); e != nil && err == nil {
+		err = e
+	}
+	l.conn = nil
+	return err
+}
+
+// holderQuery gets the sessions holding the advisory lock
+const holderQuery = `
+	SELECT a.pid, COALESCE(a.application_name, ''),
+		COALESCE(host(a.client_addr), ''), COALESCE(a.state_change, a.backend_start)
+	FROM pg_locks l
+	JOIN pg_stat_activity a ON a.pid = l.pid
+	WHERE l.locktype = 'advisory'
+		AND l.granted
+		AND l.classid::bigint = $1
+		AND l.objid::bigint = $2
+		AND l.objsubid = 1`
+
+// Holder gets the run holding the advisory lock, false if it is free. The
+// holder is never stale, a lock is held while its session lives
+func (l *PgsqlRunLock) Holder() (holder interfaces.LockHolder, held bool, err error) {
+	classID, objID := l.keyParts()
+	var backendPID int
+	var applicationName, clientAddr string
+	err = l.db.QueryRow(holderQuery, classID, objID).Scan(
+		&backendPID, &applicationName, &clientAddr, &holder.AcquiredAt,
+	)
+	if err == sql.ErrNoRows {
+		return holder, false, nil
+	}
+	if err != nil {
+		return holder, false, err
+	}
+	if parsed, ok := parseLockApplicationName(applicationName); ok {
+		parsed.AcquiredAt = holder.AcquiredAt
+		holder = parsed
+	} else {
+		holder.Command = applicationName
+		holder.PID = backendPID
+		holder.Host = clientAddr
+	}
+	holder.Profile = l.profile
+	holder.HeartbeatAt = holder.AcquiredAt
+	return holder, true, nil
+}
+
+// Break terminates the session holding the advisory lock, releasing it
+func (l *PgsqlRunLock) Break() (bool, error) {
+	classID, objID := l.keyParts()
+	var broken bool
+	err := l.db.QueryRow(`
+		SELECT COALESCE(bool_or(pg_terminate_backend(l.pid)), FALSE)
+		FROM pg_locks l
+		WHERE l.locktype = 'advisory'
+			AND l.granted
+			AND l.classid::bigint = $1
+			AND l.objid::bigint = $2
+			AND l.objsubid = 1`,
+		classID, objID,
+	).Scan(&broken)
+	return broken, err
+}
+
+// keyParts splits the key as pg_locks shows a bigint advisory lock: the high
+// & low 32 bits as unsigned numbers
+func (l *PgsqlRunLock) keyParts() (classID, objID int64) {
+	return int64(uint64(l.key) >> 32), int64(uint64(l.key) & 0xffffffff)
+}
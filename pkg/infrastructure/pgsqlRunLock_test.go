@@ -0,0 +1,36 @@
+package infrastructure
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdvisoryLockKey(t *testing.T) {
+	assert.Equal(t, advisoryLockKey("default"), advisoryLockKey("default"))
+	assert.NotEqual(t, advisoryLockKey("default"), advisoryLockKey("other"))
+}
+
+func TestPgsqlRunLockKeyParts(t *testing.T) {
+	lock := &PgsqlRunLock{key: -2}
+	classID, objID := lock.keyParts()
+	assert.Equal(t, int64(0xffffffff), classID)
+	assert.Equal(t, int64(0xfffffffe), objID)
+}
+
+func TestLockApplicationName(t *testing.T) {
+	name := lockApplicationName("sync", 10, "host")
+	assert.Equal(t, "yams-dav-sync:sync:10:host", name)
+	holder, ok := parseLockApplicationName(name)
+	assert.True(t, ok)
+	assert.Equal(t, "sync", holder.Command)
+	assert.Equal(t, 10, holder.PID)
+	assert.Equal(t, "host", holder.Host)
+
+	assert.Len(t, lockApplicationName("sync", 10, strings.Repeat("h", 100)), 63)
+	for _, name := range []string{"", "psql", "yams-dav-sync:sync:x:host", "other:sync:10:host"} {
+		_, ok = parseLockApplicationName(name)
+		assert.False(t, ok, name)
+	}
+}
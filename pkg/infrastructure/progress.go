@@ -0,0 +1,26 @@
+package infrastructure
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.mpi-internal.com/Yapo/yams-dav-sync/pkg/interfaces"
+)
+
+// ExposeProgress exposes the progress of the synchronization as json in
+// "/progress" path of the metrics server
+func ExposeProgress(progress func() interfaces.Progress) {
+	http.Handle("/progress", progressHandler(progress))
+}
+
+// progressHandler returns a handler writing the current progress as json
+func progressHandler(progress func() interfaces.Progress) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(progress()) // nolint: errcheck
+	}
+}
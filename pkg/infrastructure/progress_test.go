@@ -0,0 +1,38 @@
+package infrastructure
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.mpi-internal.com/Yapo/yams-dav-sync/pkg/interfaces"
+)
+
+func TestProgressHandler(t *testing.T) {
+	expected := interfaces.Progress{
+		Processed:       10,
+		Total:           40,
+		Sent:            8,
+		Errors:          2,
+		ElapsedSeconds:  5,
+		ImagesPerSecond: 2,
+		MBPerSecond:     1.5,
+		ETA:             "15s",
+		InProgress:      []string{"foo.jpg"},
+	}
+	handler := progressHandler(func() interfaces.Progress { return expected })
+
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest(http.MethodGet, "/progress", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	var progress interfaces.Progress
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &progress))
+	assert.Equal(t, expected, progress)
+
+	w = httptest.NewRecorder()
+	handler(w, httptest.NewRequest(http.MethodPost, "/progress", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+}
@@ -2,11 +2,13 @@ package infrastructure
 
 import (
 	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.mpi-internal.com/Yapo/yams-dav-sync/pkg/domain"
-	"github.mpi-internal.com/Yapo/yams-dav-sync/pkg/interfaces"
 	"github.mpi-internal.com/Yapo/yams-dav-sync/pkg/interfaces/loggers"
 )
 
@@ -16,11 +18,11 @@ type Prometheus struct {
 	// common  metrics for handlers
 	// requestDuration metric of latency for http request
 	requestDuration *prometheus.HistogramVec
-	// requestCounterVec metric of HTTP request qty
+	// requestCounterVec metric of HTTP request qty by status code
 	requestCounterVec *prometheus.CounterVec
-	// requestCounterVec metric of HTTP response size
+	// requestSize metric of HTTP request size
 	requestSize *prometheus.HistogramVec
-	// requestCounterVec metric of HTTP response size
+	// responseSize metric of HTTP response size
 	responseSize *prometheus.HistogramVec
 
 	// custom metrics
@@ -41,8 +43,27 @@ type Prometheus struct {
 	// recoveredImages counter of previous failed uploads and recovered in this
 	// script execution
 	recoveredImages prometheus.Counter
+	// quarantinedImages counter of images rejected by validation before upload
+	quarantinedImages prometheus.Counter
+	// errors counter of failed operations labelled by operation (upload,
+	// delete or local) & error class
+	errors *prometheus.CounterVec
 	// totalImages the total of images that should be uploaded to yams
 	totalImages prometheus.Gauge
+	// activeAccessKey the access key used to sign yams requests, 0 primary
+	// and 1 secondary
+	activeAccessKey prometheus.Gauge
+	// circuitBreakerState the state of each circuit breaker: 0 closed,
+	// 1 half-open and 2 open
+	circuitBreakerState *prometheus.GaugeVec
+	// concurrencyLimit the current limit of concurrent uploads to yams
+	concurrencyLimit prometheus.Gauge
+
+	// pushgateway pushes the final metrics on Close if enabled
+	pushgateway *pushgateway
+	// exitStatus is the exit status of the run pushed to the gateway, it is
+	// set by the command and read by the shutdown so it's accessed atomically
+	exitStatus int32
 
 	// server exposes the metrics on /metrics endopoint
 	server *http.Server
@@ -51,9 +72,10 @@ type Prometheus struct {
 }
 
 // NewPrometheusExporter generate a new prometheus instance
-func NewPrometheusExporter(port string) interfaces.MetricsExposer {
+func NewPrometheusExporter(port string, logger loggers.Logger) *Prometheus {
 	// Initialize exposed metrics
 	p := Prometheus{
+		logger: logger,
 		// Initialize handler histograms, counters & gauges
 		requestDuration: prometheus.NewHistogramVec(
 			prometheus.HistogramOpts{
@@ -68,7 +90,7 @@ func NewPrometheusExporter(port string) interfaces.MetricsExposer {
 				Name: "http_request_total",
 				Help: "Counter of HTTP request to the endpoint.",
 			},
-			[]string{"handler"},
+			[]string{"handler", "code"},
 		),
 		requestSize: prometheus.NewHistogramVec(
 			prometheus.HistogramOpts{
@@ -136,12 +158,44 @@ func NewPrometheusExporter(port string) interfaces.MetricsExposer {
 				Help: "Total of failed images in previous upload and now they were uploaded correctly",
 			},
 		),
+		quarantinedImages: prometheus.NewCounter(
+			prometheus.CounterOpts{
+				Name: "yams_quarantined_images_total",
+				Help: "Total of invalid images moved to quarantine instead of being sent to yams",
+			},
+		),
+		errors: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "yams_errors_total",
+				Help: "Total of failed operations by operation & error class",
+			},
+			[]string{"operation", "class"},
+		),
 		totalImages: prometheus.NewGauge(
 			prometheus.GaugeOpts{
 				Name: "yams_images_total",
 				Help: "Total of images to be sent to yams",
 			},
 		),
+		activeAccessKey: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "yams_active_access_key",
+				Help: "Access key used to sign yams requests: 0 primary, 1 secondary",
+			},
+		),
+		concurrencyLimit: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "yams_concurrency_limit",
+				Help: "Current limit of concurrent uploads to yams",
+			},
+		),
+		circuitBreakerState: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "yams_circuit_breaker_state",
+				Help: "State of each circuit breaker: 0 closed, 1 half-open, 2 open",
+			},
+			[]string{"breaker"},
+		),
 	}
 	// start to listen each m
 	prometheus.MustRegister(p.requestSize)
@@ -157,6 +211,11 @@ func NewPrometheusExporter(port string) interfaces.MetricsExposer {
 	prometheus.MustRegister(p.recoveredImages)
 	prometheus.MustRegister(p.totalImages)
 	prometheus.MustRegister(p.conflictiveImageName)
+	prometheus.MustRegister(p.quarantinedImages)
+	prometheus.MustRegister(p.errors)
+	prometheus.MustRegister(p.activeAccessKey)
+	prometheus.MustRegister(p.circuitBreakerState)
+	prometheus.MustRegister(p.concurrencyLimit)
 
 	// start prometheus exposer server in /metrics endopoint
 	p.expose(port)
@@ -176,16 +235,32 @@ func (p Prometheus) InstrumentHandler(handlerName string, handler http.HandlerFu
 		handler)
 	// instrument request duration
 	handler = promhttp.InstrumentHandlerDuration(
-		p.requestSize.MustCurryWith(prometheus.Labels{"handler": handlerName}),
+		p.requestDuration.MustCurryWith(prometheus.Labels{"handler": handlerName}),
 		handler)
 	// instrument response size
 	handler = promhttp.InstrumentHandlerResponseSize(
-		p.requestSize.MustCurryWith(prometheus.Labels{"handler": handlerName}),
+		p.responseSize.MustCurryWith(prometheus.Labels{"handler": handlerName}),
 		handler).(http.HandlerFunc)
 
 	return handler
 }
 
+// ObserveRequest records latency, sizes & status code of a request sent to
+// yams, the handler label is the yams operation
+func (p *Prometheus) ObserveRequest(operation, method string, code int, duration time.Duration,
+	requestBytes, responseBytes int64) {
+	status := "error"
+	if code > 0 {
+		status = strconv.Itoa(code)
+	}
+	p.requestCounterVec.WithLabelValues(operation, status).Inc()
+	p.requestDuration.WithLabelValues(operation, method).Observe(duration.Seconds())
+	p.requestSize.WithLabelValues(operation, method).Observe(float64(requestBytes))
+	if code > 0 {
+		p.responseSize.WithLabelValues(operation, method).Observe(float64(responseBytes))
+	}
+}
+
 // IncrementCounter increments a prometheus counter for a given metric
 func (p *Prometheus) IncrementCounter(metric int) {
 	switch metric {
@@ -205,6 +280,23 @@ func (p *Prometheus) IncrementCounter(metric int) {
 		p.recoveredImages.Inc()
 	case domain.ConflictiveImageName:
 		p.conflictiveImageName.Inc()
+	case domain.QuarantinedImages:
+		p.quarantinedImages.Inc()
+	}
+}
+
+// IncrementCounterWithLabel increments a prometheus counter for a given
+// metric & label
+func (p *Prometheus) IncrementCounterWithLabel(metric int, label string) {
+	switch metric {
+	case domain.UploadErrors:
+		p.errors.WithLabelValues("upload", label).Inc()
+	case domain.DeleteErrors:
+		p.errors.WithLabelValues("delete", label).Inc()
+	case domain.LocalImageErrors:
+		p.errors.WithLabelValues("local", label).Inc()
+	case domain.DBErrors:
+		p.errors.WithLabelValues("db", label).Inc()
 	}
 }
 
@@ -213,6 +305,18 @@ func (p *Prometheus) SetGauge(metric int, value float64) {
 	switch metric {
 	case domain.TotalImages:
 		p.totalImages.Set(value)
+	case domain.ActiveAccessKey:
+		p.activeAccessKey.Set(value)
+	case domain.ConcurrencyLimit:
+		p.concurrencyLimit.Set(value)
+	}
+}
+
+// SetGaugeWithLabel set a gauge for given metric & label
+func (p *Prometheus) SetGaugeWithLabel(metric int, label string, value float64) {
+	switch metric {
+	case domain.CircuitBreakerState:
+		p.circuitBreakerState.WithLabelValues(label).Set(value)
 	}
 }
 
@@ -227,7 +331,24 @@ func (p *Prometheus) expose(port string) {
 	}()
 }
 
-// Close closes prometheus server
+// EnablePush enables pushing the final metrics to the pushgateway in url when
+// the exporter is closed. Metrics are grouped by the grouping labels, e.g.
+// command & profile, lastMark is called on close to push the last sync mark
+func (p *Prometheus) EnablePush(url, job string, grouping map[string]string, lastMark func() time.Time) {
+	p.pushgateway = newPushgateway(url, job, grouping, lastMark)
+}
+
+// SetExitStatus sets the exit status of the run, 0 means success
+func (p *Prometheus) SetExitStatus(status int) {
+	atomic.StoreInt32(&p.exitStatus, int32(status))
+}
+
+// Close pushes the final metrics if push is enabled & closes prometheus server
 func (p *Prometheus) Close() error {
+	if p.pushgateway != nil {
+		if err := p.pushgateway.push(prometheus.DefaultGatherer, int(atomic.LoadInt32(&p.exitStatus))); err != nil {
+			p.logger.Error("Prometheus: error pushing metrics to %s: %s", p.pushgateway.url, err)
+		}
+	}
 	return p.server.Close()
 }
@@ -18,3 +18,9 @@ func NewProxyDialerHandler(connType, host string) (interface{}, error) {
 	}
 	return dialer, err
 }
+
+// NewDirectDialerHandler create a instance of a golang/net dialer connecting
+// directly without proxy
+func NewDirectDialerHandler() interface{} {
+	return proxy.Direct
+}
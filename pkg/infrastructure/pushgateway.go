@@ -0,0 +1,71 @@
+package infrastructure
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/push"
+)
+
+// pushgateway pushes the final metrics of a run to a prometheus pushgateway,
+// useful for short-lived commands that end before prometheus scrapes them
+type pushgateway struct {
+	url      string
+	job      string
+	grouping map[string]string
+	// lastMark returns the last synchronization mark at the end of the run
+	lastMark func() time.Time
+	start    time.Time
+	// run metrics only pushed to the gateway
+	runDuration  prometheus.Gauge
+	exitStatus   prometheus.Gauge
+	lastSyncMark prometheus.Gauge
+}
+
+// newPushgateway creates a new instance of pushgateway
+func newPushgateway(url, job string, grouping map[string]string, lastMark func() time.Time) *pushgateway {
+	return &pushgateway{
+		url:      url,
+		job:      job,
+		grouping: grouping,
+		lastMark: lastMark,
+		start:    time.Now(),
+		runDuration: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "yams_run_duration_seconds",
+				Help: "Duration of the run in seconds",
+			},
+		),
+		exitStatus: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "yams_run_exit_status",
+				Help: "Exit status of the run, 0 means success",
+			},
+		),
+		lastSyncMark: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "yams_last_sync_mark_timestamp_seconds",
+				Help: "Last synchronization mark at the end of the run as unix timestamp",
+			},
+		),
+	}
+}
+
+// push pushes the run metrics along with every metric of the gatherer
+// replacing the previous metrics of the same job & grouping
+func (p *pushgateway) push(gatherer prometheus.Gatherer, exitStatus int) error {
+	p.runDuration.Set(time.Since(p.start).Seconds())
+	p.exitStatus.Set(float64(exitStatus))
+	if p.lastMark != nil {
+		p.lastSyncMark.Set(float64(p.lastMark().Unix()))
+	}
+	pusher := push.New(p.url, p.job).
+		Gatherer(gatherer).
+		Collector(p.runDuration).
+		Collector(p.exitStatus).
+		Collector(p.lastSyncMark)
+	for name, value := range p.grouping {
+		pusher = pusher.Grouping(name, value)
+	}
+	return pusher.Push()
+}
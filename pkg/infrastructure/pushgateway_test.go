@@ -0,0 +1,52 @@
+package infrastructure
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPushgatewayPush(t *testing.T) {
+	var path, method string
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, method = r.URL.Path, r.Method
+		body, _ = ioutil.ReadAll(r.Body)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	registry := prometheus.NewRegistry()
+	sent := prometheus.NewCounter(prometheus.CounterOpts{Name: "yams_sent_images_total", Help: "sent"})
+	registry.MustRegister(sent)
+	sent.Add(3)
+
+	mark := time.Date(2019, 1, 2, 3, 4, 5, 0, time.UTC)
+	gateway := newPushgateway(server.URL, "yams-dav-sync", map[string]string{"command": "sync"},
+		func() time.Time { return mark })
+	err := gateway.push(registry, 1)
+
+	assert.NoError(t, err)
+	assert.Equal(t, http.MethodPut, method)
+	assert.Equal(t, "/metrics/job/yams-dav-sync/command/sync", path)
+	assert.Contains(t, string(body), "yams_sent_images_total")
+	assert.Contains(t, string(body), "yams_run_exit_status")
+	assert.Contains(t, string(body), "yams_run_duration_seconds")
+	assert.Contains(t, string(body), "yams_last_sync_mark_timestamp_seconds")
+}
+
+func TestPushgatewayPushError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	gateway := newPushgateway(server.URL, "yams-dav-sync", nil, nil)
+	err := gateway.push(prometheus.NewRegistry(), 0)
+	assert.Error(t, err)
+}
@@ -0,0 +1,200 @@
+package infrastructure
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/proxy"
+)
+
+// maxChunkSize is the maximum number of bytes read at once by limited
+// readers, small chunks keep the bandwidth smooth
+const maxChunkSize = 32 * 1024
+
+// RateLimiter limits the bandwidth (bytes per second) & the request rate
+// (requests per second) using token buckets. Limits may change depending on
+// the time of the day using schedules. A zero limit means no limit
+type RateLimiter struct {
+	// bytesPerSec & requestsPerSec are the limits out of schedules
+	bytesPerSec    float64
+	requestsPerSec float64
+	schedules      []rateSchedule
+	bytes          tokenBucket
+	requests       tokenBucket
+	// now & sleep allow to control the time in tests
+	now   func() time.Time
+	sleep func(time.Duration)
+}
+
+// rateSchedule holds the limits for a time window of the day
+type rateSchedule struct {
+	window         dayWindow
+	bytesPerSec    float64
+	requestsPerSec float64
+}
+
+// NewRateLimiter creates a new instance of RateLimiter. schedules is a comma
+// separated list of time windows with its own limits in the format
+// HH:MM-HH:MM=bytesPerSec/requestsPerSec, for example
+// "08:00-20:00=2000000/50,20:00-08:00=0/0" throttles the business hours and
+// runs at full speed at night
+func NewRateLimiter(bytesPerSec, requestsPerSec int, schedules string) (*RateLimiter, error) {
+	parsedSchedules, err := parseRateSchedules(schedules)
+	if err != nil {
+		return nil, err
+	}
+	return &RateLimiter{
+		bytesPerSec:    float64(bytesPerSec),
+		requestsPerSec: float64(requestsPerSec),
+		schedules:      parsedSchedules,
+		now:            time.Now,
+		sleep:          time.Sleep,
+	}, nil
+}
+
+// limits returns the limits in force at the given time
+func (l *RateLimiter) limits(now time.Time) (bytesPerSec, requestsPerSec float64) {
+	for _, s := range l.schedules {
+		if s.window.contains(now) {
+			return s.bytesPerSec, s.requestsPerSec
+		}
+	}
+	return l.bytesPerSec, l.requestsPerSec
+}
+
+// WaitBytes blocks until n bytes can be transferred
+func (l *RateLimiter) WaitBytes(n int) {
+	now := l.now()
+	rate, _ := l.limits(now)
+	l.sleep(l.bytes.take(float64(n), rate, now))
+}
+
+// WaitRequest blocks until a new request can be sent
+func (l *RateLimiter) WaitRequest() {
+	now := l.now()
+	_, rate := l.limits(now)
+	l.sleep(l.requests.take(1, rate, now))
+}
+
+// Reader wraps the reader limiting its bandwidth
+func (l *RateLimiter) Reader(r io.Reader) io.Reader {
+	return &limitedReader{reader: r, limiter: l}
+}
+
+// Dialer wraps the dialer limiting the bandwidth of data read from each
+// connection, uploads are limited wrapping the request bodies
+func (l *RateLimiter) Dialer(dialer proxy.Dialer) proxy.Dialer {
+	return &limitedDialer{dialer: dialer, limiter: l}
+}
+
+// tokenBucket holds tokens refilled at a given rate per second, up to one
+// second of tokens are kept
+type tokenBucket struct {
+	mutex  sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// take takes n tokens from the bucket returning the time to wait until those
+// tokens are available
+func (b *tokenBucket) take(n, rate float64, now time.Time) time.Duration {
+	if rate <= 0 {
+		return 0
+	}
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if !b.last.IsZero() {
+		b.tokens += now.Sub(b.last).Seconds() * rate
+	} else {
+		b.tokens = rate
+	}
+	if b.tokens > rate {
+		b.tokens = rate
+	}
+	b.last = now
+	b.tokens -= n
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / rate * float64(time.Second))
+}
+
+// limitedReader is a reader limited by a RateLimiter
+type limitedReader struct {
+	reader  io.Reader
+	limiter *RateLimiter
+}
+
+// Read reads at most maxChunkSize bytes waiting for the limiter
+func (r *limitedReader) Read(p []byte) (int, error) {
+	if len(p) > maxChunkSize {
+		p = p[:maxChunkSize]
+	}
+	n, err := r.reader.Read(p)
+	r.limiter.WaitBytes(n)
+	return n, err
+}
+
+// limitedDialer is a dialer whose connections are limited by a RateLimiter
+type limitedDialer struct {
+	dialer  proxy.Dialer
+	limiter *RateLimiter
+}
+
+// Dial connects to the address returning a limited connection
+func (d *limitedDialer) Dial(network, addr string) (net.Conn, error) {
+	conn, err := d.dialer.Dial(network, addr)
+	if err != nil {
+		return nil, err
+	}
+	return &limitedConn{Conn: conn, reader: d.limiter.Reader(conn)}, nil
+}
+
+// limitedConn is a connection whose reads are limited by a RateLimiter
+type limitedConn struct {
+	net.Conn
+	reader io.Reader
+}
+
+// Read reads from the connection waiting for the limiter
+func (c *limitedConn) Read(p []byte) (int, error) {
+	return c.reader.Read(p)
+}
+
+// parseRateSchedules parses a comma separated list of schedules in format
+// HH:MM-HH:MM=bytesPerSec/requestsPerSec
+func parseRateSchedules(schedules string) ([]rateSchedule, error) {
+	parsed := []rateSchedule{}
+	for _, item := range strings.Split(schedules, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		invalid := fmt.Errorf("Invalid rate limit schedule %q, expected HH:MM-HH:MM=bytes/requests", item)
+		parts := strings.SplitN(item, "=", 2)
+		if len(parts) != 2 {
+			return nil, invalid
+		}
+		limits := strings.SplitN(parts[1], "/", 2)
+		if len(limits) != 2 {
+			return nil, invalid
+		}
+		window, errWindow := parseDayWindow(parts[0])
+		bytesPerSec, errBytes := strconv.Atoi(strings.TrimSpace(limits[0]))
+		requestsPerSec, errRequests := strconv.Atoi(strings.TrimSpace(limits[1]))
+		if errWindow != nil || errBytes != nil || errRequests != nil {
+			return nil, invalid
+		}
+		parsed = append(parsed, rateSchedule{
+			window:         window,
+			bytesPerSec:    float64(bytesPerSec),
+			requestsPerSec: float64(requestsPerSec),
+		})
+	}
+	return parsed, nil
+}
@@ -0,0 +1,82 @@
+package infrastructure
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeClock controls the time seen by rate limiters
+type fakeClock struct {
+	now   time.Time
+	slept time.Duration
+}
+
+func (c *fakeClock) sleep(d time.Duration) {
+	c.slept += d
+	c.now = c.now.Add(d)
+}
+
+func newTestRateLimiter(t *testing.T, bytesPerSec, requestsPerSec int, schedules string, clock *fakeClock) *RateLimiter {
+	limiter, err := NewRateLimiter(bytesPerSec, requestsPerSec, schedules)
+	assert.NoError(t, err)
+	limiter.now = func() time.Time { return clock.now }
+	limiter.sleep = clock.sleep
+	return limiter
+}
+
+func TestRateLimiterRequests(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2019, 1, 1, 12, 0, 0, 0, time.UTC)}
+	limiter := newTestRateLimiter(t, 0, 10, "", clock)
+	for i := 0; i < 30; i++ {
+		limiter.WaitRequest()
+	}
+	// first second of tokens is available without waiting
+	assert.Equal(t, 2*time.Second, clock.slept.Round(time.Millisecond))
+}
+
+func TestRateLimiterReader(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2019, 1, 1, 12, 0, 0, 0, time.UTC)}
+	limiter := newTestRateLimiter(t, 100*1024, 0, "", clock)
+	content := bytes.Repeat([]byte("a"), 300*1024)
+
+	read, err := ioutil.ReadAll(limiter.Reader(bytes.NewReader(content)))
+	assert.NoError(t, err)
+	assert.Equal(t, content, read)
+	assert.Equal(t, 2*time.Second, clock.slept.Round(time.Millisecond))
+}
+
+func TestRateLimiterSchedules(t *testing.T) {
+	clock := &fakeClock{}
+	limiter := newTestRateLimiter(t, 100, 10, "08:00-20:00=50/5, 22:00-06:00=0/0", clock)
+
+	testCases := []struct {
+		hour, minute   int
+		bytesPerSec    float64
+		requestsPerSec float64
+	}{
+		{7, 59, 100, 10},
+		{8, 0, 50, 5},
+		{19, 59, 50, 5},
+		{20, 0, 100, 10},
+		{23, 30, 0, 0},
+		{5, 59, 0, 0},
+		{6, 0, 100, 10},
+	}
+	for _, testCase := range testCases {
+		bytesPerSec, requestsPerSec := limiter.limits(time.Date(2019, 1, 1, testCase.hour, testCase.minute, 0, 0, time.UTC))
+		assert.Equal(t, testCase.bytesPerSec, bytesPerSec, "%02d:%02d", testCase.hour, testCase.minute)
+		assert.Equal(t, testCase.requestsPerSec, requestsPerSec, "%02d:%02d", testCase.hour, testCase.minute)
+	}
+}
+
+func TestNewRateLimiterInvalidSchedules(t *testing.T) {
+	for _, schedules := range []string{"08:00-20:00", "8-20=1/1", "08:00-20:00=1", "08:00-20:00=a/1"} {
+		limiter, err := NewRateLimiter(0, 0, schedules)
+		assert.Nil(t, limiter)
+		assert.Error(t, err, schedules)
+	}
+}
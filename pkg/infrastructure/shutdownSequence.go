@@ -6,6 +6,7 @@ import (
 	"os"
 	"os/signal"
 	"sync"
+	"syscall"
 )
 
 // ShutdownSequence is a stack implementation to control the shutdown order of each
@@ -13,6 +14,12 @@ import (
 type ShutdownSequence struct {
 	sequence  []io.Closer
 	waitGroup *sync.WaitGroup
+	// once runs the shutdown only once, even if a signal arrives while the
+	// command is finishing
+	once sync.Once
+	// onInterrupt runs when a signal starts the shutdown, guarded by mutex
+	onInterrupt func()
+	mutex       sync.Mutex
 }
 
 // Push pushes a new component into the stack to be turned off.
@@ -46,28 +53,46 @@ func (s *ShutdownSequence) Wait() {
 	s.waitGroup.Wait()
 }
 
-// Listen launches a go routines that waits for sigint and then stops each task in the stack.
-// You need to call Listen before calling Wait, otherwise you risk waiting indefinitely
+// Listen launches a go routine that waits for SIGINT or SIGTERM and then stops each task
+// in the stack. SIGHUP is not handled here because it is used to reload configuration
+// as private keys. You need to call Listen before calling Wait, otherwise you risk
+// waiting indefinitely
 func (s *ShutdownSequence) Listen() {
 	go func() {
 		sigint := make(chan os.Signal, 1)
-		signal.Notify(sigint)
+		signal.Notify(sigint, os.Interrupt, syscall.SIGTERM)
 		<-sigint
 		// We received an interrupt signal, shut down.
+		s.mutex.Lock()
+		onInterrupt := s.onInterrupt
+		s.mutex.Unlock()
+		if onInterrupt != nil {
+			onInterrupt()
+		}
 		s.Done()
 	}()
 }
 
+// OnInterrupt sets a function to run before the shutdown started by a signal
+func (s *ShutdownSequence) OnInterrupt(task func()) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.onInterrupt = task
+}
+
 // Done finishes every goroutine waiting to be done
 func (s *ShutdownSequence) Done() {
+	s.once.Do(s.shutdown)
+}
+
+// shutdown closes every task in the stack
+func (s *ShutdownSequence) shutdown() {
 	go func() {
-		for i := 0; i <= len(s.sequence); i++ {
-			if task := s.pop(); task != nil {
-				if err := task.Close(); err != nil {
-					fmt.Printf("Error closing the task of type %T: %+v\n", task, err)
-				}
-				s.waitGroup.Done()
+		for task := s.pop(); task != nil; task = s.pop() {
+			if err := task.Close(); err != nil {
+				fmt.Printf("Error closing the task of type %T: %+v\n", task, err)
 			}
+			s.waitGroup.Done()
 		}
 		// At this point all processes must be done
 		fmt.Printf("\nProceeding to shutdown...\n")
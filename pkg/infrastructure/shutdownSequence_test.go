@@ -0,0 +1,76 @@
+package infrastructure
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type closerFunc func() error
+
+func (f closerFunc) Close() error { return f() }
+
+func TestShutdownSequence(t *testing.T) {
+	sequence := NewShutdownSequence()
+	var closed []int
+	for i := 0; i < 5; i++ {
+		i := i
+		sequence.Push(closerFunc(func() error {
+			closed = append(closed, i)
+			if i == 2 {
+				return fmt.Errorf("err")
+			}
+			return nil
+		}))
+	}
+	sequence.Done()
+	sequence.Done()
+
+	done := make(chan bool)
+	go func() {
+		sequence.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Wait didn't return")
+	}
+	// the last pushed is the first closed
+	assert.Equal(t, []int{4, 3, 2, 1, 0}, closed)
+}
+
+func TestShutdownSequenceListen(t *testing.T) {
+	sequence := NewShutdownSequence()
+	closed := make(chan bool)
+	interrupted := false
+	sequence.Push(closerFunc(func() error {
+		// the interrupt hook runs before the shutdown
+		assert.True(t, interrupted)
+		close(closed)
+		return nil
+	}))
+	sequence.OnInterrupt(func() { interrupted = true })
+	sequence.Listen()
+	// give Listen the time to register its signals
+	time.Sleep(50 * time.Millisecond)
+
+	assert.NoError(t, syscall.Kill(os.Getpid(), syscall.SIGCHLD))
+	select {
+	case <-closed:
+		t.Fatal("SIGCHLD must not shut down the sequence")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	assert.NoError(t, syscall.Kill(os.Getpid(), syscall.SIGTERM))
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("SIGTERM didn't shut down the sequence")
+	}
+	sequence.Wait()
+}
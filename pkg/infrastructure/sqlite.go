@@ -0,0 +1,178 @@
+package infrastructure
+
+import (
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strings"
+
+	_ "github.com/mattn/go-sqlite3" // nolint
+	"github.mpi-internal.com/Yapo/yams-dav-sync/pkg/interfaces/loggers"
+	"github.mpi-internal.com/Yapo/yams-dav-sync/pkg/interfaces/repository"
+)
+
+// SqliteHandler holds the connection instance to a local SQLite file. The
+// statements of the repositories are written for postgres, they are
+// translated to the SQLite dialect before being sent
+type SqliteHandler struct {
+	Conn   *sql.DB
+	Logger loggers.Logger
+}
+
+// Healthcheck implements a connection validator to check if the file is reachable
+func (handler *SqliteHandler) Healthcheck() bool {
+	return handler.Conn.Ping() == nil
+}
+
+// Close closes an open connection
+func (handler *SqliteHandler) Close() error {
+	if err := handler.Conn.Close(); err != nil {
+		handler.Logger.Error("Error closing connection: %+v", err)
+		return err
+	}
+	return nil
+}
+
+// Insert implements the incorporation of new data into an specific table of DB
+func (handler *SqliteHandler) Insert(statement string, params ...interface{}) error {
+	_, err := handler.Conn.Exec(sqliteStatement(statement), params...)
+	return err
+}
+
+// Update implements the actualization of a register from an specific table of DB
+func (handler *SqliteHandler) Update(statement string, params ...interface{}) error {
+	_, err := handler.Conn.Exec(sqliteStatement(statement), params...)
+	return err
+}
+
+// Query send statement to db returning rows
+func (handler *SqliteHandler) Query(statement string, params ...interface{}) (repository.DbResult, error) {
+	rows, err := handler.Conn.Query(sqliteStatement(statement), params...)
+	if err != nil {
+		handler.Logger.Error("Query error: %+v", err)
+		return new(PgsqlRow), err
+	}
+	return PgsqlRow{
+		Rows:   rows,
+		Logger: handler.Logger,
+	}, nil
+}
+
+// Begin starts a new transaction
+func (handler *SqliteHandler) Begin() (repository.DbTx, error) {
+	tx, err := handler.Conn.Begin()
+	if err != nil {
+		handler.Logger.Error("Begin transaction error: %+v", err)
+		return nil, err
+	}
+	return SqliteTx{
+		PgsqlTx: PgsqlTx{
+			Tx:     tx,
+			Logger: handler.Logger,
+		},
+	}, nil
+}
+
+// SqliteTx is a transaction in progress translating its statements
+type SqliteTx struct {
+	PgsqlTx
+}
+
+// Insert implements the incorporation of new data in the transaction
+func (tx SqliteTx) Insert(statement string, params ...interface{}) error {
+	return tx.PgsqlTx.Insert(sqliteStatement(statement), params...)
+}
+
+// Update implements the actualization of registers in the transaction
+func (tx SqliteTx) Update(statement string, params ...interface{}) error {
+	return tx.PgsqlTx.Update(sqliteStatement(statement), params...)
+}
+
+// Query send statement to db in the transaction returning rows
+func (tx SqliteTx) Query(statement string, params ...interface{}) (repository.DbResult, error) {
+	return tx.PgsqlTx.Query(sqliteStatement(statement), params...)
+}
+
+var (
+	sqlitePlaceholder = regexp.MustCompile(`\$(\d+)`)
+	sqliteLeast       = regexp.MustCompile(`\bLEAST\(`)
+)
+
+// sqliteInterval matches the start of the postgres interval arithmetic used
+// by the repositories, the seconds are enclosed in parentheses
+var sqliteInterval = regexp.MustCompile(`CURRENT_TIMESTAMP ([+-]) \(`)
+
+// sqliteIntervalEnd is the end of the postgres interval arithmetic
+const sqliteIntervalEnd = " * INTERVAL '1 second'"
+
+// sqliteStatement translates a postgres statement to the SQLite dialect:
+// $N placeholders to ?N, LEAST to the scalar MIN and
+// CURRENT_TIMESTAMP +/- (seconds) * INTERVAL '1 second' to datetime modifiers
+func sqliteStatement(statement string) string {
+	statement = sqlitePlaceholder.ReplaceAllString(statement, "?$1")
+	statement = sqliteLeast.ReplaceAllString(statement, "MIN(")
+
+	var translated strings.Builder
+	for {
+		match := sqliteInterval.FindStringSubmatchIndex(statement)
+		if match == nil {
+			break
+		}
+		start, open := match[0], match[1]-1
+		end := closingParen(statement, open)
+		if end < 0 || !strings.HasPrefix(statement[end+1:], sqliteIntervalEnd) {
+			translated.WriteString(statement[:open+1])
+			statement = statement[open+1:]
+			continue
+		}
+		sign := statement[match[2]:match[3]]
+		translated.WriteString(statement[:start])
+		translated.WriteString("datetime(CURRENT_TIMESTAMP, '" + sign + "' || ")
+		translated.WriteString(statement[open : end+1])
+		translated.WriteString(" || ' seconds')")
+		statement = statement[end+1+len(sqliteIntervalEnd):]
+	}
+	translated.WriteString(statement)
+	return translated.String()
+}
+
+// closingParen gets the position of the parenthesis closing the one at open,
+// -1 if it is not closed
+func closingParen(statement string, open int) int {
+	depth := 0
+	for i := open; i < len(statement); i++ {
+		switch statement[i] {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// NewSqliteHandler creates the connection handler to the SQLite file, a
+// single connection is kept since SQLite serializes the writes anyway
+func NewSqliteHandler(conf DatabaseConf, logger loggers.Logger) (*SqliteHandler, error) {
+	db, err := sql.Open("sqlite3",
+		fmt.Sprintf("%s?_busy_timeout=%d&_journal_mode=WAL&_foreign_keys=on",
+			conf.File, conf.BusyTimeout),
+	)
+	if err != nil || db == nil {
+		logger.Error("Error on DB file definition %+v\n", err)
+		return nil, err
+	}
+	if err := db.Ping(); err != nil {
+		logger.Error("Error opening DB file %s: %+v\n", conf.File, err)
+		return nil, err
+	}
+	db.SetMaxOpenConns(1)
+
+	return &SqliteHandler{
+		Conn:   db,
+		Logger: logger,
+	}, nil
+}
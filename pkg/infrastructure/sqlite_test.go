@@ -0,0 +1,300 @@
+package infrastructure
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.mpi-internal.com/Yapo/yams-dav-sync/pkg/interfaces"
+	"github.mpi-internal.com/Yapo/yams-dav-sync/pkg/interfaces/loggers"
+	"github.mpi-internal.com/Yapo/yams-dav-sync/pkg/interfaces/repository"
+)
+
+func TestSqliteStatement(t *testing.T) {
+	testCases := []struct {
+		statement string
+		expected  string
+	}{
+		{
+			"SELECT a FROM b WHERE c = $1 AND d = $12 OR $1 = ''",
+			"SELECT a FROM b WHERE c = ?1 AND d = ?12 OR ?1 = ''",
+		},
+		{
+			"SELECT LEAST($1, LEAST(a, $2)), GREATEST(b, c)",
+			"SELECT MIN(?1, MIN(a, ?2)), GREATEST(b, c)",
+		},
+		{
+			"SET next = CURRENT_TIMESTAMP + (CAST($1 AS DOUBLE PRECISION)) * INTERVAL '1 second'",
+			"SET next = datetime(CURRENT_TIMESTAMP, '+' || (CAST(?1 AS DOUBLE PRECISION)) || ' seconds')",
+		},
+		{
+			"VALUES (CURRENT_TIMESTAMP + (LEAST(a * (1 << b), c)) * INTERVAL '1 second', CURRENT_TIMESTAMP)",
+			"VALUES (datetime(CURRENT_TIMESTAMP, '+' || (MIN(a * (1 << b), c)) || ' seconds'), CURRENT_TIMESTAMP)",
+		},
+		{
+			"WHERE b < CURRENT_TIMESTAMP - (CAST($2 AS DOUBLE PRECISION)) * INTERVAL '1 second'",
+			"WHERE b < datetime(CURRENT_TIMESTAMP, '-' || (CAST(?2 AS DOUBLE PRECISION)) || ' seconds')",
+		},
+		{
+			"SELECT CURRENT_TIMESTAMP + (a) * 2, CURRENT_TIMESTAMP + (b",
+			"SELECT CURRENT_TIMESTAMP + (a) * 2, CURRENT_TIMESTAMP + (b",
+		},
+	}
+	for _, testCase := range testCases {
+		assert.Equal(t, testCase.expected, sqliteStatement(testCase.statement))
+	}
+}
+
+// newTestSqliteHandler opens an in memory database with the sqlite
+// migrations applied
+func newTestSqliteHandler(t *testing.T) *SqliteHandler {
+	handler, err := NewSqliteHandler(DatabaseConf{File: ":memory:", BusyTimeout: 1000}, testLogger{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	files, err := filepath.Glob("../../migrations/sqlite/*.up.sql")
+	if err != nil || len(files) == 0 {
+		t.Fatalf("sqlite migrations not found: %+v", err)
+	}
+	version := func(file string) int {
+		v, _ := strconv.Atoi(strings.SplitN(filepath.Base(file), "_", 2)[0])
+		return v
+	}
+	sort.Slice(files, func(i, j int) bool { return version(files[i]) < version(files[j]) })
+	for _, file := range files {
+		migration, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := handler.Conn.Exec(string(migration)); err != nil {
+			t.Fatalf("migration %s: %+v", file, err)
+		}
+	}
+	return handler
+}
+
+func TestSqliteErrorControl(t *testing.T) {
+	handler := newTestSqliteHandler(t)
+	defer handler.Close() // nolint
+	repo := repository.NewErrorControlRepo(handler, 10, time.Minute, time.Hour)
+	detail := interfaces.ErrorDetail{Class: "internal", HTTPStatus: 500, Message: "err", Retryable: true}
+
+	assert.NoError(t, repo.IncreaseErrorCounter("a/b.jpg", detail))
+	assert.NoError(t, repo.IncreaseErrorCounter("a/b.jpg", detail))
+	syncError, err := repo.GetError("a/b.jpg")
+	assert.NoError(t, err)
+	assert.Equal(t, "b.jpg", syncError.ImageName)
+	assert.Equal(t, 1, syncError.ErrorCounter)
+	assert.Equal(t, 2, syncError.Attempts)
+	assert.True(t, syncError.Retryable)
+	// the second failure doubles the backoff
+	assert.WithinDuration(t, syncError.LastFailureAt.Add(2*time.Minute), syncError.NextRetryAt, 2*time.Second)
+
+	// the image is not retried before its backoff
+	images, _, err := repo.GetPreviousErrors(0, 3)
+	assert.NoError(t, err)
+	assert.Empty(t, images)
+
+	assert.NoError(t, repo.SetErrorCounter("c.jpg", 2))
+	images, cursor, err := repo.GetPreviousErrors(0, 3)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"c.jpg"}, images)
+	assert.NotZero(t, cursor)
+
+	count, err := repo.ResetErrorCounters("")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+	list, pages, err := repo.ListErrors(1, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, pages)
+	assert.Len(t, list, 2)
+
+	assert.NoError(t, repo.CleanErrorMarks("c.jpg"))
+	_, err = repo.GetError("c.jpg")
+	assert.Equal(t, interfaces.ErrErrorMarkNotFound, err)
+}
+
+func TestSqliteErrorControlBatch(t *testing.T) {
+	handler := newTestSqliteHandler(t)
+	defer handler.Close() // nolint
+	batch := repository.NewErrorControlBatch(handler, 10, time.Minute, time.Hour, 10, 0)
+	detail := interfaces.ErrorDetail{Class: "not_found", HTTPStatus: 404, Message: "err"}
+
+	assert.NoError(t, batch.SetErrorCounter("a.jpg", 0))
+	assert.NoError(t, batch.SetErrorCounter("b.jpg", 0))
+	assert.NoError(t, batch.IncreaseErrorCounter("c.jpg", detail))
+	assert.NoError(t, batch.CleanErrorMarks("a.jpg"))
+	assert.NoError(t, batch.Close())
+
+	dead, err := batch.GetDeadErrors(3)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"c.jpg"}, dead)
+	images, _, err := batch.GetPreviousErrors(0, 3)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"b.jpg"}, images)
+}
+
+func TestSqliteDeadLetter(t *testing.T) {
+	handler := newTestSqliteHandler(t)
+	defer handler.Close() // nolint
+	errorControl := repository.NewErrorControlRepo(handler, 10, time.Minute, time.Hour)
+	deadLetter := repository.NewDeadLetterRepo(handler)
+
+	assert.NoError(t, errorControl.IncreaseErrorCounter("a.jpg",
+		interfaces.ErrorDetail{Class: "bad_request", HTTPStatus: 400, Message: "bad"}))
+	assert.NoError(t, errorControl.SetErrorCounter("b.jpg", 5))
+	assert.NoError(t, errorControl.SetErrorCounter("c.jpg", 1))
+
+	moved, err := deadLetter.Move(3)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, moved)
+	list, err := deadLetter.List()
+	assert.NoError(t, err)
+	if assert.Len(t, list, 2) {
+		assert.Equal(t, "a.jpg", list[0].ImagePath)
+		assert.Equal(t, "not retryable: bad", list[0].Reason)
+		assert.Equal(t, "b.jpg", list[1].ImagePath)
+		assert.Equal(t, "retries exhausted: ", list[1].Reason)
+	}
+
+	assert.NoError(t, deadLetter.Requeue("a.jpg"))
+	syncError, err := errorControl.GetError("a.jpg")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, syncError.ErrorCounter)
+	assert.Equal(t, "a.jpg", syncError.ImageName)
+	list, err = deadLetter.List()
+	assert.NoError(t, err)
+	assert.Len(t, list, 1)
+}
+
+func TestSqliteDeadLetterWithErrorControlBatch(t *testing.T) {
+	handler := newTestSqliteHandler(t)
+	defer handler.Close() // nolint
+	batch := repository.NewErrorControlBatch(handler, 10, time.Minute, time.Hour, 10, 0)
+	defer batch.Close() // nolint
+	detail := interfaces.ErrorDetail{Class: "internal", HTTPStatus: 500, Message: "err", Retryable: true}
+	cli := interfaces.NewCLIYams(nil, batch, nil, nil, loggers.MakeCLIYamsLogger(testLogger{}),
+		time.Time{}, interfaces.Stats{}, "20060102T150405")
+	cli.SetDeadLetter(repository.NewDeadLetterRepo(handler))
+
+	assert.NoError(t, batch.SetErrorCounter("a.jpg", 3))
+	assert.Empty(t, batch.FlushErrorMarks())
+	// the last retry failure is still buffered by the batch
+	assert.NoError(t, batch.IncreaseErrorCounter("a.jpg", detail))
+
+	assert.NoError(t, cli.MoveToDeadLetter(3))
+	list, err := repository.NewDeadLetterRepo(handler).List()
+	assert.NoError(t, err)
+	if assert.Len(t, list, 1) {
+		assert.Equal(t, "a.jpg", list[0].ImagePath)
+	}
+}
+
+func TestSqliteLastSync(t *testing.T) {
+	handler := newTestSqliteHandler(t)
+	defer handler.Close() // nolint
+	repo := repository.NewLastSyncRepo(handler, "20060102T150405", time.Time{})
+
+	assert.NoError(t, repo.SetLastSynchronizationMark(time.Date(2019, 3, 4, 5, 6, 7, 8, time.UTC)))
+	assert.NoError(t, repo.SetLastSynchronizationMark(time.Date(2019, 3, 5, 5, 6, 7, 8, time.UTC)))
+	assert.Equal(t, time.Date(2019, 3, 5, 5, 6, 7, 0, time.UTC), repo.GetLastSynchronizationMark())
+	// the previous mark is the last one after a reset
+	assert.NoError(t, repo.Reset())
+	assert.Equal(t, time.Date(2019, 3, 4, 5, 6, 7, 0, time.UTC), repo.GetLastSynchronizationMark())
+	marks, err := repo.Get()
+	assert.NoError(t, err)
+	assert.Len(t, marks, 1)
+}
+
+func TestSqliteQuarantineAndRunHistory(t *testing.T) {
+	handler := newTestSqliteHandler(t)
+	defer handler.Close() // nolint
+	quarantine := repository.NewQuarantineRepo(handler)
+	runHistory := repository.NewRunHistoryRepo(handler)
+
+	assert.NoError(t, quarantine.Add("a.jpg", "too small"))
+	assert.NoError(t, quarantine.Add("a.jpg", "corrupted"))
+	list, err := quarantine.List()
+	assert.NoError(t, err)
+	if assert.Len(t, list, 1) {
+		assert.Contains(t, list[0], "a.jpg corrupted")
+	}
+
+	run := interfaces.SyncRun{
+		Command:    "sync",
+		Flags:      map[string]string{"threads": "5"},
+		StartedAt:  time.Date(2019, 3, 4, 5, 6, 7, 0, time.UTC),
+		FinishedAt: time.Date(2019, 3, 4, 5, 7, 7, 0, time.UTC),
+		Stats:      map[string]int{"sent": 3, "db_errors": 1},
+		ExitReason: "done",
+	}
+	assert.NoError(t, runHistory.Add(run))
+	runs, err := runHistory.List(10)
+	assert.NoError(t, err)
+	if assert.Len(t, runs, 1) {
+		assert.Equal(t, run.Flags, runs[0].Flags)
+		assert.Equal(t, time.Minute, runs[0].Duration())
+		assert.Equal(t, 3, runs[0].Stats["sent"])
+		assert.Equal(t, 1, runs[0].Stats["db_errors"])
+	}
+}
+
+func TestSqliteRunLock(t *testing.T) {
+	handler := newTestSqliteHandler(t)
+	defer handler.Close() // nolint
+	first, err := repository.NewRunLockRepo(handler, "default", "host", 1, time.Hour, time.Minute)
+	assert.NoError(t, err)
+	second, err := repository.NewRunLockRepo(handler, "default", "host", 2, time.Hour, time.Minute)
+	assert.NoError(t, err)
+	other, err := repository.NewRunLockRepo(handler, "other", "host", 3, time.Hour, time.Minute)
+	assert.NoError(t, err)
+
+	acquired, err := first.TryAcquire("sync")
+	assert.NoError(t, err)
+	assert.True(t, acquired)
+	acquired, err = second.TryAcquire("deleteAll")
+	assert.NoError(t, err)
+	assert.False(t, acquired)
+	acquired, err = other.TryAcquire("sync")
+	assert.NoError(t, err)
+	assert.True(t, acquired)
+
+	holder, held, err := second.Holder()
+	assert.NoError(t, err)
+	assert.True(t, held)
+	assert.Equal(t, "sync", holder.Command)
+	assert.Equal(t, 1, holder.PID)
+	assert.False(t, holder.Stale)
+
+	// the first holder stopped its heartbeat
+	assert.NoError(t, handler.Update(
+		"UPDATE sync_lock SET heartbeat_at = '2019-03-04 05:06:07' WHERE profile = 'default'"))
+	holder, _, err = second.Holder()
+	assert.NoError(t, err)
+	assert.True(t, holder.Stale)
+	acquired, err = second.TryAcquire("deleteAll")
+	assert.NoError(t, err)
+	assert.True(t, acquired)
+
+	// the first holder doesn't release the lock taken over
+	assert.NoError(t, first.Release())
+	holder, held, err = first.Holder()
+	assert.NoError(t, err)
+	assert.True(t, held)
+	assert.Equal(t, 2, holder.PID)
+
+	broken, err := first.Break()
+	assert.NoError(t, err)
+	assert.True(t, broken)
+	_, held, err = second.Holder()
+	assert.NoError(t, err)
+	assert.False(t, held)
+	assert.NoError(t, second.Release())
+	assert.NoError(t, other.Release())
+}
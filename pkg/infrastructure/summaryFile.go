@@ -0,0 +1,33 @@
+package infrastructure
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+
+	"github.mpi-internal.com/Yapo/yams-dav-sync/pkg/interfaces"
+)
+
+// SummaryFile writes the run summary as a json document in a file
+type SummaryFile struct {
+	path string
+}
+
+// NewSummaryFile creates a new instance of SummaryFile writing in path
+func NewSummaryFile(path string) *SummaryFile {
+	return &SummaryFile{path: path}
+}
+
+// Write writes the summary in a temporary file renamed to the final path,
+// readers never get a partial document
+func (f *SummaryFile) Write(summary interfaces.RunSummary) error {
+	data, err := json.MarshalIndent(summary, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := f.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, append(data, '\n'), 0644); err != nil { // nolint: gosec
+		return err
+	}
+	return os.Rename(tmp, f.path)
+}
@@ -0,0 +1,43 @@
+package infrastructure
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.mpi-internal.com/Yapo/yams-dav-sync/pkg/interfaces"
+)
+
+func TestSummaryFileWrite(t *testing.T) {
+	dir, err := ioutil.TempDir("", "summary")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir) // nolint
+	path := filepath.Join(dir, "summary.json")
+	summary := interfaces.RunSummary{
+		Command:      "sync",
+		Outcome:      interfaces.OutcomePartial,
+		ExitCode:     interfaces.ExitPartial,
+		Stats:        map[string]int{"sent": 1, "errors": 1},
+		FailedTotal:  1,
+		FailedSample: []string{"foo.jpg"},
+	}
+
+	err = NewSummaryFile(path).Write(summary)
+	assert.NoError(t, err)
+	data, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	var result interfaces.RunSummary
+	assert.NoError(t, json.Unmarshal(data, &result))
+	assert.Equal(t, summary, result)
+	_, err = os.Stat(path + ".tmp")
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestSummaryFileWriteError(t *testing.T) {
+	err := NewSummaryFile("/nonexistent/dir/summary.json").Write(interfaces.RunSummary{})
+	assert.Error(t, err)
+}
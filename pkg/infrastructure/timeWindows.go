@@ -0,0 +1,107 @@
+package infrastructure
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.mpi-internal.com/Yapo/yams-dav-sync/pkg/interfaces"
+)
+
+// minutesPerDay is the number of minutes in a day
+const minutesPerDay = 24 * 60
+
+// dayWindow is a time window of the day, from & to are minutes since
+// midnight, windows may cross midnight
+type dayWindow struct {
+	from int
+	to   int
+}
+
+// contains returns true if the given time is inside the window
+func (w dayWindow) contains(t time.Time) bool {
+	minute := t.Hour()*60 + t.Minute()
+	if w.from > w.to {
+		return minute >= w.from || minute < w.to
+	}
+	return minute >= w.from && minute < w.to
+}
+
+// timeWindows allows the synchronization only inside the given windows
+type timeWindows struct {
+	windows []dayWindow
+}
+
+// NewTimeWindows creates a new sync schedule from a comma separated list of
+// windows in format HH:MM-HH:MM, e.g. "20:00-07:00,12:00-13:00". Windows may
+// cross midnight. An empty list allows the synchronization at any time
+func NewTimeWindows(windows string) (interfaces.SyncSchedule, error) {
+	schedule := &timeWindows{}
+	for _, item := range strings.Split(windows, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		window, err := parseDayWindow(item)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid sync window %q, expected HH:MM-HH:MM", item)
+		}
+		schedule.windows = append(schedule.windows, window)
+	}
+	return schedule, nil
+}
+
+// IsAllowed returns true if the given time is inside any window
+func (s *timeWindows) IsAllowed(t time.Time) bool {
+	if len(s.windows) == 0 {
+		return true
+	}
+	for _, window := range s.windows {
+		if window.contains(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// NextAllowed returns the beginning of the next window after the given time,
+// if the time is already allowed then it is returned
+func (s *timeWindows) NextAllowed(t time.Time) time.Time {
+	if s.IsAllowed(t) {
+		return t
+	}
+	next := t.Truncate(time.Minute)
+	for i := 0; i < minutesPerDay; i++ {
+		next = next.Add(time.Minute)
+		if s.IsAllowed(next) {
+			return next
+		}
+	}
+	return t
+}
+
+// parseDayWindow parses a window in format HH:MM-HH:MM
+func parseDayWindow(value string) (dayWindow, error) {
+	limits := strings.SplitN(value, "-", 2)
+	if len(limits) != 2 {
+		return dayWindow{}, fmt.Errorf("Invalid window %q", value)
+	}
+	from, err := parseDayMinute(limits[0])
+	if err != nil {
+		return dayWindow{}, err
+	}
+	to, err := parseDayMinute(limits[1])
+	if err != nil {
+		return dayWindow{}, err
+	}
+	return dayWindow{from: from, to: to}, nil
+}
+
+// parseDayMinute parses a HH:MM time returning the minutes since midnight
+func parseDayMinute(value string) (int, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(value))
+	if err != nil {
+		return 0, err
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
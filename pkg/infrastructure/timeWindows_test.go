@@ -0,0 +1,45 @@
+package infrastructure
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTimeWindows(t *testing.T) {
+	schedule, err := NewTimeWindows("20:00-07:00, 12:00-13:30")
+	assert.NoError(t, err)
+
+	at := func(hour, minute int) time.Time {
+		return time.Date(2019, 1, 1, hour, minute, 30, 0, time.UTC)
+	}
+	testCases := []struct {
+		time    time.Time
+		allowed bool
+		next    time.Time
+	}{
+		{at(6, 59), true, at(6, 59)},
+		{at(7, 0), false, at(12, 0).Truncate(time.Minute)},
+		{at(13, 29), true, at(13, 29)},
+		{at(13, 30), false, at(20, 0).Truncate(time.Minute)},
+		{at(23, 0), true, at(23, 0)},
+	}
+	for _, testCase := range testCases {
+		assert.Equal(t, testCase.allowed, schedule.IsAllowed(testCase.time), "%v", testCase.time)
+		assert.Equal(t, testCase.next, schedule.NextAllowed(testCase.time), "%v", testCase.time)
+	}
+
+	// no windows means always allowed
+	schedule, err = NewTimeWindows("")
+	assert.NoError(t, err)
+	assert.True(t, schedule.IsAllowed(at(7, 0)))
+}
+
+func TestNewTimeWindowsErrors(t *testing.T) {
+	for _, windows := range []string{"20:00", "20-07", "20:00-25:00"} {
+		schedule, err := NewTimeWindows(windows)
+		assert.Nil(t, schedule)
+		assert.Error(t, err, windows)
+	}
+}
@@ -0,0 +1,112 @@
+package infrastructure
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.mpi-internal.com/Yapo/yams-dav-sync/pkg/interfaces"
+)
+
+// OpenTelemetryTracer traces the synchronization stages using OpenTelemetry,
+// spans are exported in batches & flushed on Close
+type OpenTelemetryTracer struct {
+	provider *sdktrace.TracerProvider
+	tracer   trace.Tracer
+}
+
+// NewOpenTelemetryTracer creates a new instance of OpenTelemetryTracer.
+// exporter is "otlp" to export to the OTLP/HTTP collector in endpoint, e.g.
+// "localhost:4318", or "stdout" to print spans for local testing. sampleRatio
+// is the fraction of traces exported, between 0 & 1
+func NewOpenTelemetryTracer(exporter, endpoint string, insecure bool, serviceName string,
+	sampleRatio float64) (*OpenTelemetryTracer, error) {
+	var spanExporter sdktrace.SpanExporter
+	var err error
+	switch exporter {
+	case "otlp":
+		options := []otlptracehttp.Option{otlptracehttp.WithEndpoint(endpoint)}
+		if insecure {
+			options = append(options, otlptracehttp.WithInsecure())
+		}
+		spanExporter, err = otlptracehttp.New(context.Background(), options...)
+	case "stdout":
+		spanExporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	default:
+		err = fmt.Errorf("Invalid tracing exporter %q, expected otlp or stdout", exporter)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return newOpenTelemetryTracer(spanExporter, serviceName, sampleRatio), nil
+}
+
+// newOpenTelemetryTracer creates a new instance of OpenTelemetryTracer
+// exporting spans with the given exporter
+func newOpenTelemetryTracer(spanExporter sdktrace.SpanExporter, serviceName string,
+	sampleRatio float64) *OpenTelemetryTracer {
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(spanExporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(sampleRatio))),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", serviceName))),
+	)
+	return &OpenTelemetryTracer{
+		provider: provider,
+		tracer:   provider.Tracer("github.mpi-internal.com/Yapo/yams-dav-sync"),
+	}
+}
+
+// StartSpan starts a new trace with its root span
+func (t *OpenTelemetryTracer) StartSpan(name string, attributes map[string]string) interfaces.Span {
+	return t.start(context.Background(), name, attributes)
+}
+
+// start starts a span child of the span in ctx, if any
+func (t *OpenTelemetryTracer) start(ctx context.Context, name string, attributes map[string]string) interfaces.Span {
+	attrs := make([]attribute.KeyValue, 0, len(attributes))
+	for key, value := range attributes {
+		attrs = append(attrs, attribute.String(key, value))
+	}
+	ctx, span := t.tracer.Start(ctx, name, trace.WithAttributes(attrs...))
+	return &openTelemetrySpan{ctx: ctx, span: span, tracer: t}
+}
+
+// Close exports the pending spans
+func (t *OpenTelemetryTracer) Close() error {
+	return t.provider.Shutdown(context.Background())
+}
+
+// openTelemetrySpan is an OpenTelemetry span, ctx holds the span to create
+// its children
+type openTelemetrySpan struct {
+	ctx    context.Context
+	span   trace.Span
+	tracer *OpenTelemetryTracer
+}
+
+// StartChild starts a span nested in this one
+func (s *openTelemetrySpan) StartChild(name string, attributes map[string]string) interfaces.Span {
+	return s.tracer.start(s.ctx, name, attributes)
+}
+
+// SetAttribute adds an attribute to the span
+func (s *openTelemetrySpan) SetAttribute(key, value string) {
+	s.span.SetAttributes(attribute.String(key, value))
+}
+
+// End finishes the span, errors are recorded setting the span status
+func (s *openTelemetrySpan) End(err error) {
+	if err != nil {
+		s.span.RecordError(err)
+		s.span.SetStatus(codes.Error, err.Error())
+	}
+	s.span.End()
+}
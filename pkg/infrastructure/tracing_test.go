@@ -0,0 +1,60 @@
+package infrastructure
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestOpenTelemetryTracer(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tracer := newOpenTelemetryTracer(exporter, "yams-dav-sync", 1)
+
+	root := tracer.StartSpan("yams.put", map[string]string{"image.name": "foo.jpg"})
+	sign := root.StartChild("jwt.sign", nil)
+	sign.End(nil)
+	send := root.StartChild("http.send", map[string]string{"http.method": "POST"})
+	send.SetAttribute("http.status_code", "500")
+	send.End(fmt.Errorf("internal error"))
+	root.End(nil)
+	// the in memory exporter is reset on Close
+	assert.NoError(t, tracer.provider.ForceFlush(context.Background()))
+
+	spans := exporter.GetSpans()
+	assert.Len(t, spans, 3)
+	byName := map[string]tracetest.SpanStub{}
+	for _, span := range spans {
+		byName[span.Name] = span
+	}
+	put := byName["yams.put"]
+	assert.Contains(t, put.Attributes, attribute.String("image.name", "foo.jpg"))
+	assert.Equal(t, codes.Unset, put.Status.Code)
+	assert.Equal(t, put.SpanContext.SpanID(), byName["jwt.sign"].Parent.SpanID())
+	assert.Equal(t, put.SpanContext.TraceID(), byName["http.send"].SpanContext.TraceID())
+	assert.Equal(t, codes.Error, byName["http.send"].Status.Code)
+	assert.Contains(t, byName["http.send"].Attributes, attribute.String("http.status_code", "500"))
+	assert.NoError(t, tracer.Close())
+}
+
+func TestOpenTelemetryTracerSampling(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tracer := newOpenTelemetryTracer(exporter, "yams-dav-sync", 0)
+
+	root := tracer.StartSpan("yams.put", nil)
+	root.StartChild("jwt.sign", nil).End(nil)
+	root.End(nil)
+	assert.NoError(t, tracer.provider.ForceFlush(context.Background()))
+	assert.Empty(t, exporter.GetSpans())
+	assert.NoError(t, tracer.Close())
+}
+
+func TestNewOpenTelemetryTracerInvalidExporter(t *testing.T) {
+	tracer, err := NewOpenTelemetryTracer("zipkin", "", true, "yams-dav-sync", 1)
+	assert.Error(t, err)
+	assert.Nil(t, tracer)
+}
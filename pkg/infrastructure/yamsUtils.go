@@ -1,9 +1,18 @@
 package infrastructure
 
 import (
+	"crypto/ecdsa"
+	"crypto/rsa"
 	"crypto/x509"
+	"encoding/json"
 	"encoding/pem"
+	"fmt"
 	"io/ioutil"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
 
 	jwt "github.com/dgrijalva/jwt-go"
 	"github.mpi-internal.com/Yapo/yams-dav-sync/pkg/interfaces/loggers"
@@ -14,39 +23,143 @@ import (
 type jwtSigner struct {
 	privateKeyFile string
 	logger         loggers.Logger
+	// mutex guards the parsed key and the signed tokens cache
+	mutex sync.RWMutex
+	// key is the parsed private key used to sign every token
+	key interface{}
+	// method is the signing method supported by the parsed key
+	method jwt.SigningMethod
+	// tokens caches signed tokens by claims, claims carry its issued time in
+	// seconds so the cache is flushed every second
+	tokens map[string]string
+	// tokensTime is the second when cached tokens were signed
+	tokensTime int64
 }
 
-// NewJWTSigner returns a new instance of JWTSigner
-func NewJWTSigner(privateKetyFile string, logger loggers.Logger) repository.Signer {
-	return &jwtSigner{
+// NewJWTSigner returns a new instance of JWTSigner loading the private key
+// from file. Returns error if the key can not be read or parsed. The key is
+// reloaded from file each time the process receives a SIGHUP signal
+func NewJWTSigner(privateKetyFile string, logger loggers.Logger) (repository.Signer, error) {
+	signer := &jwtSigner{
 		privateKeyFile: privateKetyFile,
 		logger:         logger,
+		tokens:         make(map[string]string),
 	}
+	if err := signer.Reload(); err != nil {
+		return nil, err
+	}
+	signer.reloadOnSignal()
+	return signer, nil
+}
+
+// Reload reads & parses again the private key from file. If the new key is
+// not valid then the current key is kept
+func (signer *jwtSigner) Reload() error {
+	key, method, err := loadPrivateKey(signer.privateKeyFile)
+	if err != nil {
+		return err
+	}
+	signer.mutex.Lock()
+	signer.key = key
+	signer.method = method
+	signer.tokens = make(map[string]string)
+	signer.mutex.Unlock()
+	return nil
 }
 
-func (signer *jwtSigner) getRSAKey() interface{} {
-	mySigningKey, err := ioutil.ReadFile(signer.privateKeyFile)
+// reloadOnSignal launches a go routine that reloads the private key each time
+// the process receives a SIGHUP signal, this allows keys rotation without
+// restarting the process
+func (signer *jwtSigner) reloadOnSignal() {
+	sighup := make(chan os.Signal, 1)
+	signal.Notify(sighup, syscall.SIGHUP)
+	go func() {
+		for range sighup {
+			if err := signer.Reload(); err != nil {
+				signer.logger.Error("Error reloading Private Key, keeping the previous one: %+v", err)
+				continue
+			}
+			signer.logger.Info("Private Key %s reloaded", signer.privateKeyFile)
+		}
+	}()
+}
+
+// loadPrivateKey reads a PEM private key from file, supporting PKCS#1 and
+// PKCS#8 RSA keys and SEC 1 and PKCS#8 EC keys. Returns the parsed key with
+// its signing method
+func loadPrivateKey(file string) (interface{}, jwt.SigningMethod, error) {
+	content, err := ioutil.ReadFile(file) // nolint: gosec
 	if err != nil {
-		signer.logger.Error("Error reading Private Key: %+v", err)
-		return nil
+		return nil, nil, fmt.Errorf("Error reading Private Key %s: %+v", file, err)
+	}
+	block, _ := pem.Decode(content)
+	if block == nil {
+		return nil, nil, fmt.Errorf("Error decoding Private Key %s: no PEM data found", file)
 	}
-	block, _ := pem.Decode(mySigningKey)
 
-	rsaKey, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	var key interface{}
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
 	if err != nil {
-		signer.logger.Error("Error parsing Private Key: %+v", err)
-		return nil
+		return nil, nil, fmt.Errorf("Error parsing Private Key %s: %+v", file, err)
 	}
-	return rsaKey
+
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		return k, jwt.SigningMethodRS512, nil
+	case *ecdsa.PrivateKey:
+		switch k.Curve.Params().BitSize {
+		case 256:
+			return k, jwt.SigningMethodES256, nil
+		case 384:
+			return k, jwt.SigningMethodES384, nil
+		case 521:
+			return k, jwt.SigningMethodES512, nil
+		}
+		return nil, nil, fmt.Errorf("Error parsing Private Key %s: unsupported curve %s",
+			file, k.Curve.Params().Name)
+	}
+	return nil, nil, fmt.Errorf("Error parsing Private Key %s: unsupported key type %T", file, key)
 }
 
-// GenerateTokenString Create a new token object, specifying signing method and the claims
+// GenerateTokenString Create a new token object, specifying signing method and the claims.
+// Tokens for the same claims are signed only once
 func (signer *jwtSigner) GenerateTokenString(claims jwt.Claims) string {
-	token := jwt.NewWithClaims(jwt.SigningMethodRS512, claims)
+	cacheKey, err := json.Marshal(claims)
+	if err != nil {
+		signer.logger.Error("Error with signature for claims: %+v", err)
+		return ""
+	}
+	now := time.Now().Unix()
+
+	signer.mutex.RLock()
+	tokenString, ok := signer.tokens[string(cacheKey)]
+	key, method, tokensTime := signer.key, signer.method, signer.tokensTime
+	signer.mutex.RUnlock()
+	if ok && tokensTime == now {
+		return tokenString
+	}
+
+	token := jwt.NewWithClaims(method, claims)
 	// Sign and get the complete encoded token as a string using the secret
-	tokenString, err := token.SignedString(signer.getRSAKey())
+	tokenString, err = token.SignedString(key)
 	if err != nil {
 		signer.logger.Error("Error with signature for claims: %+v", err)
+		return tokenString
+	}
+
+	signer.mutex.Lock()
+	if signer.tokensTime != now {
+		signer.tokens = make(map[string]string)
+		signer.tokensTime = now
 	}
+	signer.tokens[string(cacheKey)] = tokenString
+	signer.mutex.Unlock()
 	return tokenString
 }
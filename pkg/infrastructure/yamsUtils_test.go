@@ -0,0 +1,149 @@
+package infrastructure
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	jwt "github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+)
+
+type testLogger struct{}
+
+func (testLogger) Debug(format string, params ...interface{}) {}
+func (testLogger) Info(format string, params ...interface{})  {}
+func (testLogger) Warn(format string, params ...interface{})  {}
+func (testLogger) Error(format string, params ...interface{}) {}
+
+type testClaims struct {
+	jwt.StandardClaims
+	Rqs string `json:"rqs"`
+}
+
+// writeTestKey writes a PEM block in a temporary file returning its name
+func writeTestKey(t testing.TB, blockType string, content []byte) string {
+	f, err := ioutil.TempFile("", "yams-key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close() // nolint
+	if err := pem.Encode(f, &pem.Block{Type: blockType, Bytes: content}); err != nil {
+		t.Fatal(err)
+	}
+	return f.Name()
+}
+
+func TestNewJWTSignerKeyFormats(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 1024)
+	pkcs8RSA, _ := x509.MarshalPKCS8PrivateKey(rsaKey)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	sec1EC, _ := x509.MarshalECPrivateKey(ecKey)
+	pkcs8EC, _ := x509.MarshalPKCS8PrivateKey(ecKey)
+
+	testCases := []struct {
+		blockType string
+		content   []byte
+		algorithm string
+	}{
+		{"RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(rsaKey), "RS512"},
+		{"PRIVATE KEY", pkcs8RSA, "RS512"},
+		{"EC PRIVATE KEY", sec1EC, "ES256"},
+		{"PRIVATE KEY", pkcs8EC, "ES256"},
+	}
+	for _, testCase := range testCases {
+		file := writeTestKey(t, testCase.blockType, testCase.content)
+		defer os.Remove(file) // nolint
+
+		signer, err := NewJWTSigner(file, testLogger{})
+		assert.NoError(t, err)
+
+		tokenString := signer.GenerateTokenString(testClaims{Rqs: "GET\\/path"})
+		token, _, err := new(jwt.Parser).ParseUnverified(tokenString, &testClaims{})
+		assert.NoError(t, err)
+		assert.Equal(t, testCase.algorithm, token.Method.Alg())
+	}
+}
+
+func TestNewJWTSignerErrors(t *testing.T) {
+	notPEM, _ := ioutil.TempFile("", "yams-key")
+	notPEM.WriteString("not a pem file") // nolint
+	notPEM.Close()                       // nolint
+	defer os.Remove(notPEM.Name())       // nolint
+
+	badKey := writeTestKey(t, "PRIVATE KEY", []byte("not a key"))
+	defer os.Remove(badKey) // nolint
+
+	for _, file := range []string{"/non/existent/key.rsa", notPEM.Name(), badKey} {
+		signer, err := NewJWTSigner(file, testLogger{})
+		assert.Nil(t, signer)
+		assert.Error(t, err)
+	}
+}
+
+func TestGenerateTokenStringCache(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 1024)
+	file := writeTestKey(t, "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(rsaKey))
+	defer os.Remove(file) // nolint
+
+	signer, err := NewJWTSigner(file, testLogger{})
+	assert.NoError(t, err)
+
+	claims := testClaims{jwt.StandardClaims{IssuedAt: time.Now().Unix()}, "GET\\/path"}
+	first := signer.GenerateTokenString(claims)
+	second := signer.GenerateTokenString(claims)
+	assert.Equal(t, first, second)
+
+	// a new key must invalidate cached tokens
+	newKey, _ := rsa.GenerateKey(rand.Reader, 1024)
+	ioutil.WriteFile(file, pem.EncodeToMemory(&pem.Block{ // nolint
+		Type:  "RSA PRIVATE KEY",
+		Bytes: x509.MarshalPKCS1PrivateKey(newKey),
+	}), 0600)
+	assert.NoError(t, signer.(*jwtSigner).Reload())
+	third := signer.GenerateTokenString(claims)
+	assert.NotEqual(t, first, third)
+
+	// an invalid key must not replace the current one
+	ioutil.WriteFile(file, []byte("corrupted"), 0600) // nolint
+	assert.Error(t, signer.(*jwtSigner).Reload())
+	assert.Equal(t, third, signer.GenerateTokenString(claims))
+}
+
+func BenchmarkGenerateTokenString(b *testing.B) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	file := writeTestKey(b, "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(rsaKey))
+	defer os.Remove(file) // nolint
+	signer, _ := NewJWTSigner(file, testLogger{})
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		signer.GenerateTokenString(testClaims{
+			jwt.StandardClaims{IssuedAt: time.Now().Unix()},
+			"POST\\/objects/" + strconv.Itoa(i),
+		})
+	}
+}
+
+func BenchmarkGenerateTokenStringCached(b *testing.B) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	file := writeTestKey(b, "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(rsaKey))
+	defer os.Remove(file) // nolint
+	signer, _ := NewJWTSigner(file, testLogger{})
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		signer.GenerateTokenString(testClaims{
+			jwt.StandardClaims{IssuedAt: time.Now().Unix()},
+			"GET\\/objects",
+		})
+	}
+}
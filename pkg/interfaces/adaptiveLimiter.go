@@ -0,0 +1,119 @@
+package interfaces
+
+import (
+	"sync"
+	"time"
+
+	"github.mpi-internal.com/Yapo/yams-dav-sync/pkg/domain"
+	"github.mpi-internal.com/Yapo/yams-dav-sync/pkg/usecases"
+)
+
+// AdaptiveLimiter limits the number of concurrent uploads using AIMD: the
+// limit grows by one for each window of healthy uploads and it is reduced
+// multiplying by backoffRatio each time yams fails or answers too slow
+type AdaptiveLimiter struct {
+	// mutex guards limit & inFlight, cond waits for a free slot
+	mutex sync.Mutex
+	cond  *sync.Cond
+	// limit is the current concurrency limit, kept as float to grow by
+	// fractions on each success
+	limit float64
+	// inFlight is the number of uploads in progress
+	inFlight int
+	// minLimit & maxLimit are the bounds of the limit
+	minLimit int
+	maxLimit int
+	// latencyThreshold is the maximum latency considered healthy
+	latencyThreshold time.Duration
+	// backoffRatio multiplies the limit when yams is unhealthy
+	backoffRatio float64
+	exposer      MetricsExposer
+}
+
+// NewAdaptiveLimiter creates a new instance of AdaptiveLimiter starting with
+// initialLimit concurrent uploads bounded by minLimit & maxLimit
+func NewAdaptiveLimiter(initialLimit, minLimit, maxLimit int, latencyThreshold time.Duration,
+	backoffRatio float64, exposer MetricsExposer) *AdaptiveLimiter {
+	if minLimit < 1 {
+		minLimit = 1
+	}
+	if maxLimit < minLimit {
+		maxLimit = minLimit
+	}
+	if initialLimit < minLimit {
+		initialLimit = minLimit
+	}
+	if initialLimit > maxLimit {
+		initialLimit = maxLimit
+	}
+	if backoffRatio <= 0 || backoffRatio >= 1 {
+		backoffRatio = 0.5
+	}
+	limiter := &AdaptiveLimiter{
+		limit:            float64(initialLimit),
+		minLimit:         minLimit,
+		maxLimit:         maxLimit,
+		latencyThreshold: latencyThreshold,
+		backoffRatio:     backoffRatio,
+		exposer:          exposer,
+	}
+	limiter.cond = sync.NewCond(&limiter.mutex)
+	limiter.exposer.SetGauge(domain.ConcurrencyLimit, float64(initialLimit))
+	return limiter
+}
+
+// Acquire blocks until the number of uploads in progress is under the limit
+func (l *AdaptiveLimiter) Acquire() {
+	l.mutex.Lock()
+	for l.inFlight >= int(l.limit) {
+		l.cond.Wait()
+	}
+	l.inFlight++
+	l.mutex.Unlock()
+}
+
+// Release frees the slot taken by Acquire adjusting the limit with the
+// latency & error of the upload
+func (l *AdaptiveLimiter) Release(latency time.Duration, err *usecases.YamsRepositoryError) {
+	l.mutex.Lock()
+	l.inFlight--
+	previous := int(l.limit)
+	if isOverloadError(err) || (l.latencyThreshold > 0 && latency > l.latencyThreshold) {
+		l.limit *= l.backoffRatio
+		if l.limit < float64(l.minLimit) {
+			l.limit = float64(l.minLimit)
+		}
+	} else if err == nil || err.Kind() == usecases.ErrYamsDuplicate {
+		l.limit += 1 / l.limit
+		if l.limit > float64(l.maxLimit) {
+			l.limit = float64(l.maxLimit)
+		}
+	}
+	current := int(l.limit)
+	l.mutex.Unlock()
+
+	if current != previous {
+		l.exposer.SetGauge(domain.ConcurrencyLimit, float64(current))
+	}
+	l.cond.Broadcast()
+}
+
+// Limit returns the current concurrency limit
+func (l *AdaptiveLimiter) Limit() int {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return int(l.limit)
+}
+
+// MaxLimit returns the maximum concurrency limit
+func (l *AdaptiveLimiter) MaxLimit() int {
+	return l.maxLimit
+}
+
+// isOverloadError returns true if the error means yams is overloaded or
+// unreachable: server errors, timeouts & circuit breaker trips
+func isOverloadError(err *usecases.YamsRepositoryError) bool {
+	kind := err.Kind()
+	return kind == usecases.ErrYamsInternal || kind == usecases.ErrYamsConnection ||
+		kind == usecases.ErrYamsTimeout
+}
@@ -0,0 +1,92 @@
+package interfaces
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.mpi-internal.com/Yapo/yams-dav-sync/pkg/domain"
+	"github.mpi-internal.com/Yapo/yams-dav-sync/pkg/usecases"
+)
+
+func TestNewAdaptiveLimiterBounds(t *testing.T) {
+	mMetricsExposer := &mockMetricsExposer{}
+	mMetricsExposer.On("SetGauge", domain.ConcurrencyLimit, mock.AnythingOfType("float64"))
+
+	limiter := NewAdaptiveLimiter(50, 0, 10, time.Second, 2, mMetricsExposer)
+	assert.Equal(t, 10, limiter.Limit())
+	assert.Equal(t, 10, limiter.MaxLimit())
+	assert.Equal(t, 1, limiter.minLimit)
+	assert.Equal(t, 0.5, limiter.backoffRatio)
+
+	limiter = NewAdaptiveLimiter(0, 2, 10, time.Second, 0.5, mMetricsExposer)
+	assert.Equal(t, 2, limiter.Limit())
+}
+
+func TestAdaptiveLimiterIncrease(t *testing.T) {
+	mMetricsExposer := &mockMetricsExposer{}
+	mMetricsExposer.On("SetGauge", domain.ConcurrencyLimit, 2.0).Once()
+	mMetricsExposer.On("SetGauge", domain.ConcurrencyLimit, 3.0).Once()
+	mMetricsExposer.On("SetGauge", domain.ConcurrencyLimit, 4.0).Once()
+
+	limiter := NewAdaptiveLimiter(2, 1, 4, time.Second, 0.5, mMetricsExposer)
+	// each window of healthy uploads increases the limit by one
+	for i := 0; i < 20; i++ {
+		limiter.Acquire()
+		limiter.Release(time.Millisecond, nil)
+	}
+	assert.Equal(t, 4, limiter.Limit())
+	mMetricsExposer.AssertExpectations(t)
+}
+
+func TestAdaptiveLimiterBackoff(t *testing.T) {
+	mMetricsExposer := &mockMetricsExposer{}
+	mMetricsExposer.On("SetGauge", domain.ConcurrencyLimit, mock.AnythingOfType("float64"))
+
+	limiter := NewAdaptiveLimiter(8, 2, 8, time.Second, 0.5, mMetricsExposer)
+	limiter.Acquire()
+	limiter.Release(time.Millisecond, usecases.ErrYamsInternal)
+	assert.Equal(t, 4, limiter.Limit())
+
+	// slow responses are considered as overload
+	limiter.Acquire()
+	limiter.Release(2*time.Second, nil)
+	assert.Equal(t, 2, limiter.Limit())
+
+	// the limit is never under the minimum
+	limiter.Acquire()
+	limiter.Release(time.Millisecond, usecases.ErrYamsConnection)
+	assert.Equal(t, 2, limiter.Limit())
+
+	// client errors do not change the limit
+	limiter.Acquire()
+	limiter.Release(time.Millisecond, usecases.ErrYamsImage)
+	assert.Equal(t, 2, limiter.Limit())
+}
+
+func TestAdaptiveLimiterAcquireBlocks(t *testing.T) {
+	mMetricsExposer := &mockMetricsExposer{}
+	mMetricsExposer.On("SetGauge", domain.ConcurrencyLimit, mock.AnythingOfType("float64"))
+
+	limiter := NewAdaptiveLimiter(1, 1, 1, time.Second, 0.5, mMetricsExposer)
+	limiter.Acquire()
+
+	acquired := make(chan bool)
+	go func() {
+		limiter.Acquire()
+		acquired <- true
+	}()
+	select {
+	case <-acquired:
+		t.Fatal("Acquire must block while the limit is reached")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	limiter.Release(time.Millisecond, nil)
+	select {
+	case <-acquired:
+	case <-time.After(time.Second):
+		t.Fatal("Acquire must continue after Release")
+	}
+}
@@ -19,10 +19,33 @@ type CLIYams struct {
 	dateLayout           string
 	lastSyncDate         chan time.Time
 	inProgressTimestamps chan []time.Time
+	inProgressNames      chan []string
+	sentBytes            chan int64
+	startedAt            chan time.Time
+	total                int
+	progressLogInterval  int
 	stats                Stats
-	quit                 chan bool
+	stop                 chan bool
+	stopOnce             sync.Once
+	running              sync.Mutex
+	paused               chan bool
 	isSync               bool
 	isDelete             bool
+	validator            ImageValidator
+	quarantine           Quarantine
+	deadLetter           DeadLetter
+	limiter              ConcurrencyLimiter
+	schedule             SyncSchedule
+	tracer               Tracer
+	runHistory           RunHistory
+	run                  chan SyncRun
+	failedNames          chan []string
+	failedTotal          chan int
+	summaryWriter        SummaryWriter
+	runLock              RunLock
+	lockWait             time.Duration
+	lockPollInterval     time.Duration
+	lockHeld             bool
 }
 
 // NewCLIYams creates a new instance of CLIYams
@@ -31,10 +54,20 @@ func NewCLIYams(imageService ImageService, errorControl ErrorControl, lastSync L
 
 	lastSyncDate := make(chan time.Time, 1)
 	lastSyncDate <- defaultLastSyncDate
-	quit := make(chan bool, 1)
-	quit <- false
 	inProgressTimestamps := make(chan []time.Time, 1)
 	inProgressTimestamps <- []time.Time{}
+	inProgressNames := make(chan []string, 1)
+	inProgressNames <- []string{}
+	sentBytes := make(chan int64, 1)
+	sentBytes <- 0
+	startedAt := make(chan time.Time, 1)
+	startedAt <- time.Now()
+	paused := make(chan bool, 1)
+	paused <- false
+	failedNames := make(chan []string, 1)
+	failedNames <- []string{}
+	failedTotal := make(chan int, 1)
+	failedTotal <- 0
 
 	return &CLIYams{
 		imageService:         imageService,
@@ -45,8 +78,14 @@ func NewCLIYams(imageService ImageService, errorControl ErrorControl, lastSync L
 		dateLayout:           dateLayout,
 		lastSyncDate:         lastSyncDate,
 		inProgressTimestamps: inProgressTimestamps,
-		quit:                 quit,
+		inProgressNames:      inProgressNames,
+		sentBytes:            sentBytes,
+		startedAt:            startedAt,
+		stop:                 make(chan bool),
+		paused:               paused,
 		stats:                stats,
+		failedNames:          failedNames,
+		failedTotal:          failedTotal,
 	}
 }
 
@@ -64,20 +103,111 @@ type ImageService interface {
 	GetMaxConcurrency() int
 }
 
+// TracedImageService is implemented by image services able to trace their
+// requests as children of the span of the caller
+type TracedImageService interface {
+	// SendTraced sends images from local storage to yams bucket
+	SendTraced(parent Span, image domain.Image) (checksum string, err *usecases.YamsRepositoryError)
+	// RemoteDeleteTraced deletes image from yams bucket
+	RemoteDeleteTraced(parent Span, imageName string, force bool) *usecases.YamsRepositoryError
+}
+
 // ErrorControl allows operations to control errors with yams synchronization
 type ErrorControl interface {
-	// GetErrorsPagesQty gets the number of pages for error pagination
-	GetErrorsPagesQty(maxErrorTolerance int) int
-	// GetPreviousErrors gets a list with previus errors, errors must have its own counter
-	// over maxErrorTolerance
-	GetPreviousErrors(pagination, maxErrorTolerance int) ([]string, error)
+	// GetPreviousErrors gets a page of previus retryable errors due to be
+	// retried, errors must have its own counter over maxErrorTolerance. Pages
+	// start after the cursor returned with the previous one, zero for the first
+	GetPreviousErrors(cursor, maxErrorTolerance int) ([]string, int, error)
 	// CleanErrorMarks cleans every error mark associated with the image
 	CleanErrorMarks(imgName string) error
 	// SetErrorCounter sets the error counter
 	SetErrorCounter(imageName string, counter int) error
-	// IncreaseErrorCounter increase the error counter in one, if the image does not
-	// have error mark, the mark will be created
-	IncreaseErrorCounter(imageName string) error
+	// IncreaseErrorCounter increase the error counter in one recording the
+	// detail of the failure, if the image does not have error mark, the mark
+	// will be created
+	IncreaseErrorCounter(imageName string, detail ErrorDetail) error
+	// ListErrors gets a page of error marks with counter equal or over
+	// minCounter and the number of pages
+	ListErrors(page, minCounter int) ([]SyncError, int, error)
+	// GetError gets the error mark of the image
+	GetError(imagePath string) (SyncError, error)
+	// ResetErrorCounters resets the counter of the image error mark, or every
+	// counter if imagePath is empty, returning the number of marks reset
+	ResetErrorCounters(imagePath string) (int, error)
+	// PurgeErrors deletes the error marks with counter over maxErrorTolerance
+	// or parked, returning the number of marks deleted
+	PurgeErrors(maxErrorTolerance int) (int, error)
+	// GetDeadErrors gets the images with counter over maxErrorTolerance or
+	// parked by a non retryable error
+	GetDeadErrors(maxErrorTolerance int) ([]string, error)
+}
+
+// ImageValidator allows operations to validate local images before upload
+type ImageValidator interface {
+	// Validate checks format, dimensions and integrity of the image,
+	// returning the reason in case of invalid image as a
+	// *usecases.ImageValidationError, any other error is a failure reading it
+	Validate(image domain.Image) error
+}
+
+// Quarantine allows operations to keep track of images rejected by validation
+type Quarantine interface {
+	// Add puts the image in quarantine with the reason of its rejection
+	Add(imagePath, reason string) error
+	// List gets the list of quarantined images
+	List() ([]string, error)
+}
+
+// DeadLetter allows operations to keep track of images that are not retried
+// anymore
+type DeadLetter interface {
+	// Move moves the error marks over maxErrorTolerance or parked to the dead
+	// letter, returning the number of images moved
+	Move(maxErrorTolerance int) (int, error)
+	// List gets the images in the dead letter
+	List() ([]DeadImage, error)
+	// Requeue takes the image out of the dead letter to be retried
+	Requeue(imagePath string) error
+}
+
+// RunLock allows operations to keep a single run changing yams or the
+// synchronization marks at once for each profile
+type RunLock interface {
+	// TryAcquire takes the lock for the command if it is free or stale
+	TryAcquire(command string) (bool, error)
+	// Release frees the lock held by this process
+	Release() error
+	// Holder gets the run holding the lock, false if it is free
+	Holder() (LockHolder, bool, error)
+	// Break frees the lock whoever holds it, false if it was free
+	Break() (bool, error)
+}
+
+// RunHistory allows operations to keep track of command executions
+type RunHistory interface {
+	// Add stores the record of a run
+	Add(run SyncRun) error
+	// List gets the latest runs ordered by newer to older
+	List(limit int) ([]SyncRun, error)
+}
+
+// ConcurrencyLimiter allows operations to adapt the number of concurrent
+// uploads to yams
+type ConcurrencyLimiter interface {
+	// Acquire blocks until a new upload is allowed
+	Acquire()
+	// Release notifies the end of an upload with its latency & error
+	Release(latency time.Duration, err *usecases.YamsRepositoryError)
+	// MaxLimit returns the maximum number of concurrent uploads
+	MaxLimit() int
+}
+
+// SyncSchedule allows operations to know when synchronization is allowed
+type SyncSchedule interface {
+	// IsAllowed returns true if synchronization is allowed at the given time
+	IsAllowed(t time.Time) bool
+	// NextAllowed returns the next time when synchronization is allowed
+	NextAllowed(t time.Time) time.Time
 }
 
 // LastSync allows operations to control latest synchornization status
@@ -119,21 +249,341 @@ type CLIYamsLogger interface {
 	LogErrorIncreasingErrorCounter(imgName string, err error)
 	LogErrorGettingRemoteChecksum(imgName string, err error)
 	LogErrorSettingSyncMark(mark time.Time, err error)
+	LogQuarantinedImage(imgName string, reason error)
+	LogErrorQuarantiningImage(imgName string, err error)
 	LogRetryPreviousFailedUploads()
 	LogReadingNewImages()
 	LogUploadingNewImages()
 	LogStats(timer int, stats *Stats)
+	LogProgress(progress Progress)
 	LogMarksList(list []string)
+	LogQuarantineList(list []string)
+	LogRunHistory(runs []SyncRun)
+	LogRunHistoryJSON(runs []SyncRun)
+	LogErrorSavingRun(command string, err error)
+	LogErrorWritingSummary(err error)
+	LogErrorsList(list []SyncError, page, pages int)
+	LogSyncError(syncError SyncError)
+	LogErrorCountersReset(count int)
+	LogErrorsPurged(count int)
+	LogDeadErrors(list []string)
+	LogMovedToDeadLetter(count int)
+	LogErrorMovingToDeadLetter(err error)
+	LogDeadLetterList(list []DeadImage)
+	LogErrorRequeuingImage(imgName string, err error)
+	LogDeadLetterRequeued(requeued, skipped int)
+	LogSyncPaused(resumeAt time.Time)
+	LogSyncResumed()
+	LogWaitingForRunLock(holder LockHolder)
+	LogErrorReleasingRunLock(err error)
+	LogRunLock(holder LockHolder, held bool)
+	LogRunLockBroken(broken bool)
 }
 
-// retryPreviousFailedUploads gets images from errorControlRepository and try
-// to upload those images to yams one more time. If fails increase the counter of errors
-// in repo. Repository only returns images with less than a specific number of errors.
-func (cli *CLIYams) retryPreviousFailedUploads(threads, maxErrorTolerance int, latestSynchronizedImageDate time.Time) {
+// SetImageValidation enables the validation stage before each upload, invalid
+// images are moved to quarantine instead of being sent to yams
+func (cli *CLIYams) SetImageValidation(validator ImageValidator, quarantine Quarantine) {
+	cli.validator = validator
+	cli.quarantine = quarantine
+}
+
+// SetRunHistory enables the run history, runs started with StartRun are
+// stored on Close
+func (cli *CLIYams) SetRunHistory(history RunHistory) {
+	cli.runHistory = history
+}
+
+// SetSummaryWriter enables the summary of the run, written on Close
+func (cli *CLIYams) SetSummaryWriter(writer SummaryWriter) {
+	cli.summaryWriter = writer
+}
+
+// StartRun starts the record of the command execution, taking the current
+// synchronization mark. Sync & deleteAll take it again once they hold the run
+// lock
+func (cli *CLIYams) StartRun(command string, flags map[string]string) {
+	run := SyncRun{
+		Command:   command,
+		Flags:     flags,
+		StartedAt: time.Now(),
+	}
+	if cli.lastSync != nil {
+		run.MarkBefore = cli.lastSync.GetLastSynchronizationMark()
+	}
+	cli.run = make(chan SyncRun, 1)
+	cli.run <- run
+}
+
+// takeMarkBefore takes the synchronization mark the current run starts from
+func (cli *CLIYams) takeMarkBefore() {
+	if cli.run == nil || cli.lastSync == nil {
+		return
+	}
+	run := <-cli.run
+	run.MarkBefore = cli.lastSync.GetLastSynchronizationMark()
+	cli.run <- run
+}
+
+// SetRunResult sets the exit reason of the current run from the command
+// result, runs closed without result or stopped are recorded as interrupted
+func (cli *CLIYams) SetRunResult(err error) {
+	if cli.run == nil || cli.isStopped() {
+		return
+	}
+	run := <-cli.run
+	run.ExitReason = runExitReason(err)
+	cli.run <- run
+}
+
+// ExitCode returns the exit code of the process by the outcome of the run,
+// success if no run was started
+func (cli *CLIYams) ExitCode() int {
+	if cli.run == nil {
+		return ExitSuccess
+	}
+	run := <-cli.run
+	cli.run <- run
+	failedTotal := <-cli.failedTotal
+	cli.failedTotal <- failedTotal
+	_, exitCode := runOutcome(run.ExitReason, failedTotal)
+	return exitCode
+}
+
+// addFailed counts a failed object keeping its name if the sample is not full
+func (cli *CLIYams) addFailed(name string) {
+	cli.failedTotal <- inc(<-cli.failedTotal)
+	names := <-cli.failedNames
+	if len(names) < maxFailedSample {
+		names = append(names, name)
+	}
+	cli.failedNames <- names
+}
+
+// GetHistory gets the latest runs, as json or text
+func (cli *CLIYams) GetHistory(limit int, asJSON bool) error {
+	if limit <= 0 {
+		limit = defaultHistoryLimit
+	}
+	runs, err := cli.runHistory.List(limit)
+	if err != nil {
+		return err
+	}
+	if asJSON {
+		cli.logger.LogRunHistoryJSON(runs)
+	} else {
+		cli.logger.LogRunHistory(runs)
+	}
+	return nil
+}
+
+// SetProgress sets the total of images to process, used to estimate the
+// remaining time, and the interval in seconds between progress logs. Zero
+// interval disables progress logs
+func (cli *CLIYams) SetProgress(total, logInterval int) {
+	cli.total = total
+	cli.progressLogInterval = logInterval
+}
+
+// Progress returns the current progress of the synchronization with its
+// throughput, ETA & the images in progress
+func (cli *CLIYams) Progress() Progress {
+	startedAt := <-cli.startedAt
+	cli.startedAt <- startedAt
+	sentBytes := <-cli.sentBytes
+	cli.sentBytes <- sentBytes
+	inProgress := <-cli.inProgressNames
+	cli.inProgressNames <- inProgress
+	stats := cli.stats.Snapshot()
+	return newProgress(
+		stats["processed"],
+		cli.total,
+		stats["sent"],
+		stats["errors"],
+		sentBytes,
+		time.Since(startedAt),
+		append([]string{}, inProgress...),
+	)
+}
+
+// SetConcurrencyLimiter enables the adaptive concurrency, the send workers
+// pool grows up to the limiter maximum and each upload waits for the limiter
+func (cli *CLIYams) SetConcurrencyLimiter(limiter ConcurrencyLimiter) {
+	cli.limiter = limiter
+}
+
+// sendWorkers returns the number of send workers to launch, if the
+// concurrency is adaptive the limiter controls how many of them are sending
+func (cli *CLIYams) sendWorkers(threads int) int {
 	maxConcurrency := cli.imageService.GetMaxConcurrency()
+	if cli.limiter != nil {
+		threads = cli.limiter.MaxLimit()
+	}
 	if threads > maxConcurrency {
 		threads = maxConcurrency
 	}
+	return threads
+}
+
+// send sends the image to yams waiting for the concurrency limiter if any
+func (cli *CLIYams) send(span Span, image domain.Image) (string, *usecases.YamsRepositoryError) {
+	if cli.limiter == nil {
+		return cli.sendImage(span, image)
+	}
+	cli.limiter.Acquire()
+	start := time.Now()
+	remoteChecksum, err := cli.sendImage(span, image)
+	cli.limiter.Release(time.Since(start), err)
+	return remoteChecksum, err
+}
+
+// sendImage sends the image to yams, the request is traced as a child of
+// span if the image service supports it
+func (cli *CLIYams) sendImage(span Span, image domain.Image) (string, *usecases.YamsRepositoryError) {
+	if service, ok := cli.imageService.(TracedImageService); ok {
+		return service.SendTraced(span, image)
+	}
+	return cli.imageService.Send(image)
+}
+
+// remoteDelete deletes the image from yams, the request is traced as a child
+// of span if the image service supports it
+func (cli *CLIYams) remoteDelete(span Span, imageName string) *usecases.YamsRepositoryError {
+	if service, ok := cli.imageService.(TracedImageService); ok {
+		return service.RemoteDeleteTraced(span, imageName, domain.YAMSForceRemoval)
+	}
+	return cli.imageService.RemoteDelete(imageName, domain.YAMSForceRemoval)
+}
+
+// SetTracer enables tracing of the synchronization stages
+func (cli *CLIYams) SetTracer(tracer Tracer) {
+	cli.tracer = tracer
+}
+
+// startSpan starts a new trace if tracing is enabled, the stages of the trace
+// are traced as its children
+func (cli *CLIYams) startSpan(name string, attributes map[string]string) Span {
+	if cli.tracer == nil {
+		return NoopTracer.StartSpan(name, attributes)
+	}
+	return cli.tracer.StartSpan(name, attributes)
+}
+
+// traced runs the error control operation fn of an image inside a child span
+// of parent
+func (cli *CLIYams) traced(parent Span, name, imageName string, fn func() error) error {
+	span := parent.StartChild(name, map[string]string{"image.name": imageName})
+	err := fn()
+	span.End(err)
+	return err
+}
+
+// getLocalImage gets the image from local storage tracing the read as a
+// child span of parent
+func (cli *CLIYams) getLocalImage(parent Span, imagePath string) (domain.Image, error) {
+	span := parent.StartChild("local.get_image", map[string]string{"image.path": imagePath})
+	image, err := cli.localImage.GetLocalImage(imagePath)
+	span.End(err)
+	return image, err
+}
+
+// SetSyncSchedule sets the windows when synchronization is allowed, out of
+// them the workers are paused keeping the progress in the sync mark
+func (cli *CLIYams) SetSyncSchedule(schedule SyncSchedule) {
+	cli.schedule = schedule
+}
+
+// Pause pauses the synchronization until Resume is called, images in
+// progress are finished and the progress is kept in the sync mark
+func (cli *CLIYams) Pause() {
+	<-cli.paused
+	cli.paused <- true
+}
+
+// Resume resumes a paused synchronization
+func (cli *CLIYams) Resume() {
+	<-cli.paused
+	cli.paused <- false
+}
+
+// isPaused returns true if the synchronization was paused by Pause
+func (cli *CLIYams) isPaused() bool {
+	paused := <-cli.paused
+	cli.paused <- paused
+	return paused
+}
+
+// pauseCheckInterval is the maximum time between checks while paused
+var pauseCheckInterval = time.Second
+
+// waitForSyncWindow blocks while synchronization is paused or not allowed by
+// schedule, saving the progress before pausing. Returns false if the process
+// is stopped while waiting
+func (cli *CLIYams) waitForSyncWindow() bool {
+	waiting := false
+	for cli.isPaused() || (cli.schedule != nil && !cli.schedule.IsAllowed(time.Now())) {
+		wait := pauseCheckInterval
+		if !waiting {
+			waiting = true
+			cli.saveSyncMark() // nolint
+			var resumeAt time.Time
+			if !cli.isPaused() {
+				resumeAt = cli.schedule.NextAllowed(time.Now())
+			}
+			cli.logger.LogSyncPaused(resumeAt)
+		}
+		if cli.isStopped() {
+			return false
+		}
+		if cli.schedule != nil && !cli.isPaused() {
+			if untilResume := time.Until(cli.schedule.NextAllowed(time.Now())); untilResume < wait {
+				wait = untilResume
+			}
+		}
+		time.Sleep(wait)
+	}
+	if waiting {
+		cli.logger.LogSyncResumed()
+	}
+	return true
+}
+
+// enqueue sends the image to the workers when synchronization is allowed.
+// Returns false if the process is stopped
+func (cli *CLIYams) enqueue(jobs chan<- domain.Image, image domain.Image) bool {
+	if !cli.waitForSyncWindow() {
+		return false
+	}
+	select {
+	case jobs <- image:
+		return true
+	case <-cli.stop:
+		return false
+	}
+}
+
+// Stop asks the running sync or deleteAll to finish, images in progress are
+// completed. It doesn't wait for the command to return, Close does
+func (cli *CLIYams) Stop() {
+	cli.stopOnce.Do(func() {
+		close(cli.stop)
+	})
+}
+
+// isStopped returns true if Stop or Close was called
+func (cli *CLIYams) isStopped() bool {
+	select {
+	case <-cli.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+// retryPreviousFailedUploads gets images from errorControlRepository and try
+// to upload those images to yams one more time. If fails increase the counter of errors
+// in repo. Repository only returns images with less than a specific number of errors.
+func (cli *CLIYams) retryPreviousFailedUploads(threads, maxErrorTolerance int, latestSynchronizedImageDate time.Time) {
+	threads = cli.sendWorkers(threads)
 	// Prepare the jobs using concurrency
 	jobs := make(chan domain.Image)
 	var waitGroup sync.WaitGroup
@@ -141,22 +591,34 @@ func (cli *CLIYams) retryPreviousFailedUploads(threads, maxErrorTolerance int, l
 		waitGroup.Add(1)
 		go cli.retrySendWorker(w, jobs, &waitGroup)
 	}
-	// Get how many pages of failed uploads are in DB
-	nPages := cli.errorControl.GetErrorsPagesQty(maxErrorTolerance)
-	for pagination := 1; pagination <= nPages; pagination++ {
+	// Failed uploads are paged by cursor, marks cleaned or updated while
+	// retrying do not shift the next pages
+	cursor := 0
+	readSpan := cli.startSpan("retry.read", nil)
+pages:
+	for {
 		// Get a list of failed uploads
-		result, err := cli.errorControl.GetPreviousErrors(pagination, maxErrorTolerance)
-		if err != nil {
-			continue
+		result, nextCursor, err := cli.errorControl.GetPreviousErrors(cursor, maxErrorTolerance)
+		if err != nil || len(result) == 0 {
+			break
 		}
+		cursor = nextCursor
 		// For each image in the list of failed uplaods
 		for _, imagePath := range result {
 			cli.stats.Processed <- inc(<-cli.stats.Processed)
 			cli.stats.exposer.IncrementCounter(domain.ProcessedImages)
-			image, err := cli.localImage.GetLocalImage(imagePath)
+			image, err := cli.getLocalImage(readSpan, imagePath)
 			if err != nil {
 				cli.stats.NotFound <- inc(<-cli.stats.NotFound)
 				cli.stats.exposer.IncrementCounter(domain.NotFoundImages)
+				cli.stats.exposer.IncrementCounterWithLabel(domain.LocalImageErrors, errorClass(err))
+				// the local failure is recorded, missing images are not retried
+				if e := cli.traced(readSpan, "db.increase_error_counter", imagePath, func() error {
+					return cli.errorControl.IncreaseErrorCounter(imagePath, newErrorDetail(err))
+				}); e != nil {
+					cli.logger.LogErrorIncreasingErrorCounter(imagePath, e)
+					cli.countDBError("increase_error_counter")
+				}
 				continue
 			}
 
@@ -167,28 +629,40 @@ func (cli *CLIYams) retryPreviousFailedUploads(threads, maxErrorTolerance int, l
 				imageDate.After(latestSynchronizedImageDate) {
 				cli.stats.Recovered <- inc(<-cli.stats.Recovered)
 				cli.stats.exposer.IncrementCounter(domain.RecoveredImages)
-				if e := cli.errorControl.CleanErrorMarks(image.Metadata.ImageName); e != nil {
-					cli.logger.LogErrorCleaningMarks(image.Metadata.ImageName, e)
+				if e := cli.traced(readSpan, "db.clean_error_marks", imagePath, func() error {
+					return cli.errorControl.CleanErrorMarks(imagePath)
+				}); e != nil {
+					cli.logger.LogErrorCleaningMarks(imagePath, e)
 				}
 				continue
 			}
 			// Concurrent upload to imageService
-			jobs <- image
+			if !cli.enqueue(jobs, image) {
+				break pages
+			}
 		}
 	}
+	readSpan.End(nil)
 
 	close(jobs)
 	waitGroup.Wait()
 }
 
 // Sync synchronizes images between local repository and image service repository
-// using go concurrency
+// using go concurrency, holding the run lock if it is enabled
 func (cli *CLIYams) Sync(threads, syncLimit, maxErrorTolerance int, imagesDumpYamsPath string) error {
-	cli.isSync = true
-	maxConcurrency := cli.imageService.GetMaxConcurrency()
-	if threads > maxConcurrency {
-		threads = maxConcurrency
+	cli.running.Lock()
+	defer cli.running.Unlock()
+	if cli.isStopped() {
+		return nil
 	}
+	if err := cli.acquireRunLock("sync"); err != nil {
+		return err
+	}
+	// a previous holder may have moved the mark while this run waited
+	cli.takeMarkBefore()
+	cli.isSync = true
+	threads = cli.sendWorkers(threads)
 	cli.showStats()
 	cli.logger.LogRetryPreviousFailedUploads()
 
@@ -210,8 +684,10 @@ func (cli *CLIYams) Sync(threads, syncLimit, maxErrorTolerance int, imagesDumpYa
 	cli.logger.LogReadingNewImages()
 
 	// Get the data file with list of images to upload
+	dumpSpan := cli.startSpan("dump.read", map[string]string{"dump.path": imagesDumpYamsPath})
 	file, e := cli.localImage.OpenFile(imagesDumpYamsPath)
 	if e != nil {
+		dumpSpan.End(e)
 		cli.logger.LogErrorGettingImagesList(imagesDumpYamsPath, e)
 		return e
 	}
@@ -236,27 +712,36 @@ func (cli *CLIYams) Sync(threads, syncLimit, maxErrorTolerance int, imagesDumpYa
 			continue
 		}
 		_, imagePath := tuple[0], tuple[1]
-		image, err := cli.localImage.GetLocalImage(imagePath)
+		image, err := cli.getLocalImage(dumpSpan, imagePath)
 		if err != nil {
 			cli.stats.NotFound <- inc(<-cli.stats.NotFound)
 			cli.stats.exposer.IncrementCounter(domain.NotFoundImages)
+			cli.stats.exposer.IncrementCounterWithLabel(domain.LocalImageErrors, errorClass(err))
 			continue
 		}
-		jobs <- image
+		if !cli.enqueue(jobs, image) {
+			break
+		}
 	}
 
+	scanErr := scanner.Err()
+	dumpSpan.End(scanErr)
 	close(jobs)
 	waitGroup.Wait()
 
 	// If scanner stopped because error
-	if e := scanner.Err(); e != nil {
-		return e
+	if scanErr != nil {
+		return scanErr
 	}
 
 	// When the process is done, retry failed uploads using the new latestSynchronizedImageDate
+	if cli.isStopped() {
+		return nil
+	}
 	latestSynchronizedImageDate = <-cli.lastSyncDate
 	cli.lastSyncDate <- latestSynchronizedImageDate
 	cli.retryPreviousFailedUploads(threads, maxErrorTolerance, latestSynchronizedImageDate)
+	cli.moveToDeadLetter(maxErrorTolerance)
 	return nil
 }
 
@@ -287,7 +772,7 @@ func (cli *CLIYams) List(limit int) (err error) {
 	for {
 		list, continuationToken, err = cli.imageService.List(continuationToken, 0)
 		if err != yamsErrNil {
-			if err == usecases.ErrYamsInternal {
+			if yamsErrorKind(err) == usecases.ErrYamsInternal {
 				continuationToken = backupToken
 			}
 			continue
@@ -309,11 +794,25 @@ func (cli *CLIYams) List(limit int) (err error) {
 
 // Delete deletes an object in yams repository
 func (cli *CLIYams) Delete(imageName string) error {
-	return cli.imageService.RemoteDelete(imageName, domain.YAMSForceRemoval)
+	// a nil *YamsRepositoryError must not be returned as a non nil error
+	if err := cli.imageService.RemoteDelete(imageName, domain.YAMSForceRemoval); err != nil {
+		return err
+	}
+	return nil
 }
 
-// DeleteAll deletes every imagen in yams repository and redis using concurency
+// DeleteAll deletes every imagen in yams repository and redis using concurency,
+// holding the run lock if it is enabled
 func (cli *CLIYams) DeleteAll(threads, limit int) (err error) {
+	cli.running.Lock()
+	defer cli.running.Unlock()
+	if cli.isStopped() {
+		return nil
+	}
+	if err = cli.acquireRunLock("deleteAll"); err != nil {
+		return err
+	}
+	cli.takeMarkBefore()
 	cli.isDelete = true
 	cli.showStats()
 	jobs := make(chan domain.Image)
@@ -336,10 +835,12 @@ func (cli *CLIYams) DeleteAll(threads, limit int) (err error) {
 	var counter int
 
 	// While images Service has images, delete all of them
-	for {
+	readSpan := cli.startSpan("delete.read", nil)
+pages:
+	for !cli.isStopped() {
 		list, continuationToken, err = cli.imageService.List(continuationToken, 0)
 		if err != yamsErrNil {
-			if err == usecases.ErrYamsInternal {
+			if yamsErrorKind(err) == usecases.ErrYamsInternal {
 				continuationToken = backupToken
 			}
 			continue
@@ -347,13 +848,17 @@ func (cli *CLIYams) DeleteAll(threads, limit int) (err error) {
 		for _, yamsObject := range list {
 			cli.stats.Processed <- inc(<-cli.stats.Processed)
 			cli.stats.exposer.IncrementCounter(domain.ProcessedImages)
-			image, e := cli.localImage.GetLocalImage(yamsObject.ID)
+			image, e := cli.getLocalImage(readSpan, yamsObject.ID)
 			if e != nil {
 				image.Metadata.ImageName = yamsObject.ID
 				image.Metadata.ModTime = time.Now()
 			}
 			image.Metadata.ModTime = removeTimezoneDiff(image.Metadata.ModTime)
-			jobs <- image
+			select {
+			case jobs <- image:
+			case <-cli.stop:
+				break pages
+			}
 			counter++
 			if counter >= limit && limit > 0 {
 				break
@@ -365,6 +870,7 @@ func (cli *CLIYams) DeleteAll(threads, limit int) (err error) {
 		}
 		backupToken = continuationToken
 	}
+	readSpan.End(nil)
 	close(jobs)
 	waitGroup.Wait()
 	if err == yamsErrNil {
@@ -383,34 +889,37 @@ func (cli *CLIYams) sendWorker(id int, jobs <-chan domain.Image, wg *sync.WaitGr
 		inProgress = append(inProgress, image.Metadata.ModTime)
 
 		cli.inProgressTimestamps <- inProgress
+		cli.inProgressNames <- append(<-cli.inProgressNames, image.Metadata.ImageName)
 
-		// send new image to Image Service
-		remoteChecksum, err := cli.imageService.Send(image)
-		cli.sendErrorControl(image, previousUploadFailed, remoteChecksum, err)
+		var remoteChecksum string
+		var err *usecases.YamsRepositoryError
+		span := cli.startSpan("image.sync", map[string]string{"image.name": image.Metadata.ImageName})
+		valid, readErr := cli.validateImage(image)
+		if readErr != nil {
+			// the image is marked to be retried as a failed upload
+			cli.sendErrorControl(span, image, previousUploadFailed, "", readErr)
+		} else if valid {
+			// send new image to Image Service
+			remoteChecksum, err = cli.send(span, image)
+			cli.sendErrorControl(span, image, previousUploadFailed, remoteChecksum, err)
+		}
+		span.End(nil)
 
 		// remove sent timestamp image of inProgress list
 		inProgress = <-cli.inProgressTimestamps
 		inProgress = removeElement(image.Metadata.ModTime, inProgress)
 		cli.inProgressTimestamps <- inProgress
+		cli.inProgressNames <- removeName(image.Metadata.ImageName, <-cli.inProgressNames)
 
-		// Update latest sync mark only if yams returns no error
-		if err == yamsNilResponse || err == usecases.ErrYamsDuplicate || err == nil {
+		// Update latest sync mark only if the image was read & yams returns no
+		// error
+		if readErr == nil && (err == yamsNilResponse || err.Kind() == usecases.ErrYamsDuplicate) {
 			date := <-cli.lastSyncDate
 			if image.Metadata.ModTime.After(date) {
 				date = image.Metadata.ModTime
 			}
 			cli.lastSyncDate <- date
 		}
-
-		// determine if the worker should finish
-		if quit, ok := <-cli.quit; ok {
-			cli.quit <- quit
-			if quit {
-				return
-			}
-		} else {
-			return
-		}
 	}
 }
 
@@ -418,74 +927,130 @@ func (cli *CLIYams) sendWorker(id int, jobs <-chan domain.Image, wg *sync.WaitGr
 func (cli *CLIYams) retrySendWorker(id int, jobs <-chan domain.Image, wg *sync.WaitGroup) {
 	defer wg.Done()
 	for image := range jobs {
-		// Retry to upload image to Image Service
-		remoteChecksum, err := cli.imageService.Send(image)
-		cli.sendErrorControl(image, domain.SWRetry, remoteChecksum, err)
-		// determine if the worker should finish
-		if quit, ok := <-cli.quit; ok {
-			cli.quit <- quit
-			if quit {
-				return
-			}
-		} else {
-			return
+		span := cli.startSpan("image.retry", map[string]string{"image.name": image.Metadata.ImageName})
+		valid, readErr := cli.validateImage(image)
+		if readErr != nil {
+			cli.sendErrorControl(span, image, domain.SWRetry, "", readErr)
+		} else if valid {
+			// Retry to upload image to Image Service
+			remoteChecksum, err := cli.send(span, image)
+			cli.sendErrorControl(span, image, domain.SWRetry, remoteChecksum, err)
+		} else if e := cli.errorControl.CleanErrorMarks(image.Path); e != nil {
+			// quarantined images must not be retried anymore
+			cli.logger.LogErrorCleaningMarks(image.Path, e)
 		}
+		span.End(nil)
 	}
 }
 
-// sendErrorControl takes action depending of error type retuned by send method
-func (cli *CLIYams) sendErrorControl(image domain.Image, previousUploadFailed int, remoteChecksum string, err error) {
+// sendErrorControl takes action depending of error type retuned by send method,
+// its operations are traced as children of span
+func (cli *CLIYams) sendErrorControl(span Span, image domain.Image, previousUploadFailed int, remoteChecksum string, err error) {
 	imageName := image.Metadata.ImageName
+	// error marks are stored by the path the image is read with
+	imagePath := image.Path
 	localImageChecksum := image.Metadata.Checksum
 	yamsErrNil := (*usecases.YamsRepositoryError)(nil)
-	switch err {
+	switch yamsErrorKind(err) {
 	case nil:
 		fallthrough
 	case yamsErrNil:
 		if previousUploadFailed == domain.SWRetry {
-			if e := cli.errorControl.CleanErrorMarks(imageName); e != nil {
-				cli.logger.LogErrorCleaningMarks(imageName, e)
+			if e := cli.traced(span, "db.clean_error_marks", imagePath, func() error {
+				return cli.errorControl.CleanErrorMarks(imagePath)
+			}); e != nil {
+				cli.logger.LogErrorCleaningMarks(imagePath, e)
 			}
 			cli.stats.Recovered <- inc(<-cli.stats.Recovered)
 			cli.stats.exposer.IncrementCounter(domain.RecoveredImages)
 		}
 		cli.stats.Sent <- inc(<-cli.stats.Sent)
 		cli.stats.exposer.IncrementCounter(domain.SentImages)
+		cli.sentBytes <- <-cli.sentBytes + image.Metadata.Size
 		return
 	case usecases.ErrYamsDuplicate:
 		cli.stats.Duplicated <- inc(<-cli.stats.Duplicated)
 		if remoteChecksum != localImageChecksum {
 			cli.stats.exposer.IncrementCounter(domain.ConflictiveImageName)
-			if e := cli.imageService.RemoteDelete(imageName, domain.YAMSForceRemoval); e != yamsErrNil {
+			if e := cli.remoteDelete(span, imageName); e != yamsErrNil {
 				cli.logger.LogErrorRemoteDelete(imageName, e)
 				// recursive increase error counter
-				cli.sendErrorControl(image, previousUploadFailed, remoteChecksum, e)
+				cli.sendErrorControl(span, image, previousUploadFailed, remoteChecksum, e)
 				return
 			}
 			// mark to upload in the next sync process (because yams cache)
-			if e := cli.errorControl.SetErrorCounter(imageName, 0); e != nil {
-				cli.logger.LogErrorResetingErrorCounter(imageName, e)
+			if e := cli.traced(span, "db.set_error_counter", imagePath, func() error {
+				return cli.errorControl.SetErrorCounter(imagePath, 0)
+			}); e != nil {
+				// the image was deleted from yams & it is not marked to be
+				// uploaded again, so it counts as failed
+				cli.logger.LogErrorResetingErrorCounter(imagePath, e)
+				cli.countDBError("set_error_counter")
+				cli.addFailed(imageName)
 			}
 		} else {
 			cli.stats.exposer.IncrementCounter(domain.DuplicatedImages)
 			// recursive clean up marks with nil error in case of presviousUploadFailed true
-			cli.sendErrorControl(image, previousUploadFailed, remoteChecksum, nil)
+			cli.sendErrorControl(span, image, previousUploadFailed, remoteChecksum, nil)
 		}
 	default: // any other kind of error increase error counter
 		cli.stats.Errors <- inc(<-cli.stats.Errors)
+		cli.addFailed(imageName)
 		cli.stats.exposer.IncrementCounter(domain.FailedUploads)
-		if e := cli.errorControl.IncreaseErrorCounter(imageName); e != nil {
-			cli.logger.LogErrorIncreasingErrorCounter(imageName, e)
+		cli.stats.exposer.IncrementCounterWithLabel(domain.UploadErrors, errorClass(err))
+		if e := cli.traced(span, "db.increase_error_counter", imagePath, func() error {
+			return cli.errorControl.IncreaseErrorCounter(imagePath, newErrorDetail(err))
+		}); e != nil {
+			cli.logger.LogErrorIncreasingErrorCounter(imagePath, e)
+			cli.countDBError("increase_error_counter")
 		}
 	}
 }
 
+// countDBError counts a failure storing an error mark labelled by operation,
+// the failed image is not retried in the next syncs
+func (cli *CLIYams) countDBError(operation string) {
+	cli.stats.DBErrors <- inc(<-cli.stats.DBErrors)
+	cli.stats.exposer.IncrementCounterWithLabel(domain.DBErrors, operation)
+}
+
+// validateImage validates the image if validation stage is enabled, invalid
+// images are moved to quarantine. A failure reading the image is returned, the
+// image is not quarantined so it can be retried
+func (cli *CLIYams) validateImage(image domain.Image) (valid bool, err error) {
+	if cli.validator == nil {
+		return true, nil
+	}
+	err = cli.validator.Validate(image)
+	if err == nil {
+		return true, nil
+	}
+	reason, ok := err.(*usecases.ImageValidationError)
+	if !ok {
+		return false, err
+	}
+	cli.logger.LogQuarantinedImage(image.Metadata.ImageName, reason)
+	cli.stats.Quarantined <- inc(<-cli.stats.Quarantined)
+	cli.stats.exposer.IncrementCounter(domain.QuarantinedImages)
+	// images with the same name in different folders are quarantined apart
+	if e := cli.quarantine.Add(image.Path, reason.Error()); e != nil {
+		cli.logger.LogErrorQuarantiningImage(image.Path, e)
+	}
+	return false, nil
+}
+
 // deleteWorker deletes every image to yams repository
 func (cli *CLIYams) deleteWorker(id int, jobs <-chan domain.Image, wg *sync.WaitGroup) {
+	defer wg.Done()
 	yamsErrNil := (*usecases.YamsRepositoryError)(nil)
 	for image := range jobs {
-		if e := cli.imageService.RemoteDelete(image.Metadata.ImageName, domain.YAMSForceRemoval); e != yamsErrNil {
+		span := cli.startSpan("image.delete", map[string]string{"image.name": image.Metadata.ImageName})
+		e := cli.remoteDelete(span, image.Metadata.ImageName)
+		span.End(nil)
+		if e != yamsErrNil {
 			cli.logger.LogErrorRemoteDelete(image.Metadata.ImageName, e)
+			cli.stats.exposer.IncrementCounterWithLabel(domain.DeleteErrors, errorClass(e))
+			cli.addFailed(image.Metadata.ImageName)
 		} else {
 			date := <-cli.lastSyncDate
 			if image.Metadata.ModTime.Before(date) {
@@ -493,13 +1058,7 @@ func (cli *CLIYams) deleteWorker(id int, jobs <-chan domain.Image, wg *sync.Wait
 			}
 			cli.lastSyncDate <- date
 		}
-		quit := <-cli.quit
-		cli.quit <- quit
-		if quit {
-			return
-		}
 	}
-	defer wg.Done()
 }
 
 // Reset cleans the last synchronization date mark to return to the previous
@@ -518,62 +1077,132 @@ func (cli *CLIYams) GetMarks() error {
 	return nil
 }
 
-// Close closes cliYams execution
+// GetQuarantine gets list of images rejected by validation
+func (cli *CLIYams) GetQuarantine() error {
+	list, err := cli.quarantine.List()
+	if err != nil {
+		return err
+	}
+	cli.logger.LogQuarantineList(list)
+	return nil
+}
+
+// Close closes cliYams execution, stopping the running sync or deleteAll and
+// waiting for it to return, running is held while they run, before saving
+// its progress
 func (cli *CLIYams) Close() (err error) {
+	cli.Stop()
+	cli.running.Lock()
+	defer cli.running.Unlock()
+	if cli.isSync {
+		cli.flushErrorMarks()
+	}
 	if cli.isSync || cli.isDelete {
-		newMark := <-cli.lastSyncDate
-		cli.lastSyncDate <- newMark
-		oldMark := cli.lastSync.GetLastSynchronizationMark()
-		var condition bool
-		if cli.isSync {
-			condition = newMark.After(oldMark)
-		} else if cli.isDelete {
-			condition = newMark.Before(oldMark)
+		err = cli.saveSyncMark()
+	}
+	// the lock is held until the synchronization mark is saved
+	if e := cli.releaseRunLock(); e != nil && err == nil {
+		err = e
+	}
+	// the run is finished before stopping the stats, they are released once
+	// the stats display ends
+	if e := cli.finishRun(); e != nil && err == nil {
+		err = e
+	}
+	return
+}
+
+// finishRun completes the current run with its final stats & synchronization
+// mark, storing it in the history and writing its summary if enabled
+func (cli *CLIYams) finishRun() (err error) {
+	if cli.run == nil {
+		return
+	}
+	run := <-cli.run
+	if run.ExitReason == "" {
+		run.ExitReason = RunInterrupted
+	}
+	run.FinishedAt = time.Now()
+	run.Stats = cli.stats.Snapshot()
+	if cli.lastSync != nil {
+		run.MarkAfter = cli.lastSync.GetLastSynchronizationMark()
+	}
+	cli.run <- run
+	if cli.runHistory != nil {
+		if err = cli.runHistory.Add(run); err != nil {
+			cli.logger.LogErrorSavingRun(run.Command, err)
 		}
-		if condition {
-			inProgress := <-cli.inProgressTimestamps
-			// Search if images in progress have an older date mark
-			for _, timestamp := range inProgress {
-				if timestamp.Before(newMark) {
-					newMark = timestamp
-				}
+	}
+	if cli.summaryWriter != nil {
+		failedTotal := <-cli.failedTotal
+		cli.failedTotal <- failedTotal
+		failedNames := <-cli.failedNames
+		cli.failedNames <- failedNames
+		sentBytes := <-cli.sentBytes
+		cli.sentBytes <- sentBytes
+		summary := newRunSummary(run, failedTotal, append([]string{}, failedNames...), sentBytes)
+		if e := cli.summaryWriter.Write(summary); e != nil {
+			cli.logger.LogErrorWritingSummary(e)
+			if err == nil {
+				err = e
 			}
-			cli.inProgressTimestamps <- inProgress
-			err = cli.lastSync.SetLastSynchronizationMark(newMark)
-			if err != nil {
-				cli.logger.LogErrorSettingSyncMark(newMark, err)
+		}
+	}
+	return
+}
+
+// saveSyncMark persists the synchronization progress as the last sync mark,
+// images in progress are not considered as synchronized
+func (cli *CLIYams) saveSyncMark() (err error) {
+	newMark := <-cli.lastSyncDate
+	cli.lastSyncDate <- newMark
+	oldMark := cli.lastSync.GetLastSynchronizationMark()
+	var condition bool
+	if cli.isSync {
+		condition = newMark.After(oldMark)
+	} else if cli.isDelete {
+		condition = newMark.Before(oldMark)
+	}
+	if condition {
+		inProgress := <-cli.inProgressTimestamps
+		// Search if images in progress have an older date mark
+		for _, timestamp := range inProgress {
+			if timestamp.Before(newMark) {
+				newMark = timestamp
 			}
 		}
-		quit := <-cli.quit
-		cli.quit <- !quit
+		cli.inProgressTimestamps <- inProgress
+		err = cli.lastSync.SetLastSynchronizationMark(newMark)
+		if err != nil {
+			cli.logger.LogErrorSettingSyncMark(newMark, err)
+		}
 	}
 	return
 }
 
-// showStats displays synchronization stats in screen while yams-dav-sync script is running
+// showStats displays synchronization stats in screen while yams-dav-sync script is running,
+// until Stop or Close is called. Stats are kept open, the run summary and the progress
+// endpoint still read them after the display ends
 func (cli *CLIYams) showStats() {
 	go func() {
-		quit, ok := <-cli.quit
-		if ok {
-			cli.quit <- quit
-		}
 		timer := 0
+		<-cli.startedAt
+		cli.startedAt <- time.Now()
 		ticker := time.NewTicker(time.Second)
-		for !quit {
+		defer ticker.Stop()
+		for {
 			cli.logger.LogStats(timer, &cli.stats)
-			<-ticker.C
-			timer++
-			quit, ok := <-cli.quit
-			if ok {
-				cli.quit <- quit
+			if cli.progressLogInterval > 0 && timer > 0 && timer%cli.progressLogInterval == 0 {
+				cli.logger.LogProgress(cli.Progress())
+			}
+			select {
+			case <-ticker.C:
+			case <-cli.stop:
+				cli.logger.LogStats(timer, &cli.stats)
+				return
 			}
+			timer++
 		}
-		cli.logger.LogStats(timer, &cli.stats)
-		cli.stats.Close() // nolint
-		close(cli.quit)
-		close(cli.inProgressTimestamps)
-		close(cli.lastSyncDate)
-		ticker.Stop()
 	}()
 }
 
@@ -586,6 +1215,16 @@ func removeTimezoneDiff(imageDate time.Time) time.Time {
 		Truncate(time.Second)
 }
 
+// removeName removes the first occurrence of name from slice
+func removeName(name string, slice []string) []string {
+	for i := range slice {
+		if slice[i] == name {
+			return append(slice[:i], slice[i+1:]...)
+		}
+	}
+	return slice
+}
+
 // removeElement removes a given element from slice
 func removeElement(element time.Time, slice []time.Time) []time.Time {
 	for i := 0; i < len(slice); i++ {
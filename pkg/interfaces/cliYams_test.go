@@ -51,14 +51,9 @@ type mockErrorControl struct {
 	mock.Mock
 }
 
-func (m *mockErrorControl) GetErrorsPagesQty(tolerance int) int {
-	args := m.Called(tolerance)
-	return args.Int(0)
-}
-
-func (m *mockErrorControl) GetPreviousErrors(pagination, tolerance int) ([]string, error) {
-	args := m.Called(pagination, tolerance)
-	return args.Get(0).([]string), args.Error(1)
+func (m *mockErrorControl) GetPreviousErrors(cursor, tolerance int) ([]string, int, error) {
+	args := m.Called(cursor, tolerance)
+	return args.Get(0).([]string), args.Int(1), args.Error(2)
 }
 
 func (m *mockErrorControl) CleanErrorMarks(imageName string) error {
@@ -71,11 +66,36 @@ func (m *mockErrorControl) SetErrorCounter(imageName string, counter int) error
 	return args.Error(0)
 }
 
-func (m *mockErrorControl) IncreaseErrorCounter(imageName string) error {
-	args := m.Called(imageName)
+func (m *mockErrorControl) IncreaseErrorCounter(imageName string, detail ErrorDetail) error {
+	args := m.Called(imageName, detail)
 	return args.Error(0)
 }
 
+func (m *mockErrorControl) ListErrors(page, minCounter int) ([]SyncError, int, error) {
+	args := m.Called(page, minCounter)
+	return args.Get(0).([]SyncError), args.Int(1), args.Error(2)
+}
+
+func (m *mockErrorControl) GetError(imagePath string) (SyncError, error) {
+	args := m.Called(imagePath)
+	return args.Get(0).(SyncError), args.Error(1)
+}
+
+func (m *mockErrorControl) ResetErrorCounters(imagePath string) (int, error) {
+	args := m.Called(imagePath)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *mockErrorControl) PurgeErrors(maxErrorTolerance int) (int, error) {
+	args := m.Called(maxErrorTolerance)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *mockErrorControl) GetDeadErrors(maxErrorTolerance int) ([]string, error) {
+	args := m.Called(maxErrorTolerance)
+	return args.Get(0).([]string), args.Error(1)
+}
+
 type mockLocalImage struct {
 	mock.Mock
 }
@@ -187,6 +207,14 @@ func (m *mockLogger) LogErrorSettingSyncMark(mark time.Time, err error) {
 	m.Called(mark, err)
 }
 
+func (m *mockLogger) LogQuarantinedImage(imgName string, reason error) {
+	m.Called(imgName, reason)
+}
+
+func (m *mockLogger) LogErrorQuarantiningImage(imgName string, err error) {
+	m.Called(imgName, err)
+}
+
 func (m *mockLogger) LogRetryPreviousFailedUploads() {
 	m.Called()
 }
@@ -207,6 +235,117 @@ func (m *mockLogger) LogMarksList(list []string) {
 	m.Called(list)
 }
 
+func (m *mockLogger) LogQuarantineList(list []string) {
+	m.Called(list)
+}
+
+func (m *mockLogger) LogRunHistory(runs []SyncRun) {
+	m.Called(runs)
+}
+
+func (m *mockLogger) LogRunHistoryJSON(runs []SyncRun) {
+	m.Called(runs)
+}
+
+func (m *mockLogger) LogErrorSavingRun(command string, err error) {
+	m.Called(command, err)
+}
+
+func (m *mockLogger) LogErrorWritingSummary(err error) {
+	m.Called(err)
+}
+
+func (m *mockLogger) LogErrorsList(list []SyncError, page, pages int) {
+	m.Called(list, page, pages)
+}
+
+func (m *mockLogger) LogSyncError(syncError SyncError) {
+	m.Called(syncError)
+}
+
+func (m *mockLogger) LogErrorCountersReset(count int) {
+	m.Called(count)
+}
+
+func (m *mockLogger) LogErrorsPurged(count int) {
+	m.Called(count)
+}
+
+func (m *mockLogger) LogDeadErrors(list []string) {
+	m.Called(list)
+}
+
+func (m *mockLogger) LogMovedToDeadLetter(count int) {
+	m.Called(count)
+}
+
+func (m *mockLogger) LogErrorMovingToDeadLetter(err error) {
+	m.Called(err)
+}
+
+func (m *mockLogger) LogDeadLetterList(list []DeadImage) {
+	m.Called(list)
+}
+
+func (m *mockLogger) LogErrorRequeuingImage(imgName string, err error) {
+	m.Called(imgName, err)
+}
+
+func (m *mockLogger) LogDeadLetterRequeued(requeued, skipped int) {
+	m.Called(requeued, skipped)
+}
+
+func (m *mockLogger) LogSyncPaused(resumeAt time.Time) {
+	m.Called(resumeAt)
+}
+
+func (m *mockLogger) LogSyncResumed() {
+	m.Called()
+}
+
+func (m *mockLogger) LogWaitingForRunLock(holder LockHolder) {
+	m.Called(holder)
+}
+
+func (m *mockLogger) LogErrorReleasingRunLock(err error) {
+	m.Called(err)
+}
+
+func (m *mockLogger) LogRunLock(holder LockHolder, held bool) {
+	m.Called(holder, held)
+}
+
+func (m *mockLogger) LogRunLockBroken(broken bool) {
+	m.Called(broken)
+}
+
+func (m *mockLogger) LogProgress(progress Progress) {
+	m.Called(progress)
+}
+
+type mockImageValidator struct {
+	mock.Mock
+}
+
+func (m *mockImageValidator) Validate(image domain.Image) error {
+	args := m.Called(image)
+	return args.Error(0)
+}
+
+type mockQuarantine struct {
+	mock.Mock
+}
+
+func (m *mockQuarantine) Add(imagePath, reason string) error {
+	args := m.Called(imagePath, reason)
+	return args.Error(0)
+}
+
+func (m *mockQuarantine) List() ([]string, error) {
+	args := m.Called()
+	return args.Get(0).([]string), args.Error(1)
+}
+
 type mockMetricsExposer struct {
 	mock.Mock
 }
@@ -215,10 +354,18 @@ func (m *mockMetricsExposer) IncrementCounter(metric int) {
 	m.Called(metric)
 }
 
+func (m *mockMetricsExposer) IncrementCounterWithLabel(metric int, label string) {
+	m.Called(metric, label)
+}
+
 func (m *mockMetricsExposer) SetGauge(metric int, value float64) {
 	m.Called(metric, value)
 }
 
+func (m *mockMetricsExposer) SetGaugeWithLabel(metric int, label string, value float64) {
+	m.Called(metric, label, value)
+}
+
 func (m *mockMetricsExposer) Close() error {
 	args := m.Called()
 	return args.Error(0)
@@ -256,14 +403,16 @@ func TestSyncProcess(t *testing.T) {
 	mLogger := &mockLogger{}
 	// images to send
 	mImageService.On("GetMaxConcurrency").Return(1)
-	mErrorControl.On("GetErrorsPagesQty", mock.AnythingOfType("int")).Return(1)
 	mMetricsExposer.On("IncrementCounter", mock.AnythingOfType("int"))
 
 	imagesToRetrySend := []string{}
 
 	mErrorControl.On("GetPreviousErrors",
 		mock.AnythingOfType("int"),
-		mock.AnythingOfType("int")).Return(imagesToRetrySend, nil)
+		mock.AnythingOfType("int")).Return(imagesToRetrySend, len(imagesToRetrySend), nil).Once()
+	mErrorControl.On("GetPreviousErrors",
+		mock.AnythingOfType("int"),
+		mock.AnythingOfType("int")).Return([]string{}, 0, nil)
 	mLogger.On("LogRetryPreviousFailedUploads").Once()
 	mLogger.On("LogReadingNewImages").Once()
 	mLogger.On("LogUploadingNewImages").Once()
@@ -297,6 +446,7 @@ func TestSyncProcess(t *testing.T) {
 		case 2: // Image not found in local & skipped
 			mScanner.On("Text").Return(imageListElements[i]).Once()
 			mLocalImage.On("GetLocalImage", mock.AnythingOfType("string")).Return(domain.Image{}, fmt.Errorf("error"))
+			mMetricsExposer.On("IncrementCounterWithLabel", domain.LocalImageErrors, "local_read").Once()
 			mScanner.On("Scan").Return(true).Once()
 		}
 	}
@@ -341,10 +491,9 @@ func TestSyncProcessErrorScanning(t *testing.T) {
 	mFile := &mockFile{}
 	mLogger := &mockLogger{}
 	mImageService.On("GetMaxConcurrency").Return(1)
-	mErrorControl.On("GetErrorsPagesQty", mock.AnythingOfType("int")).Return(1)
 	mErrorControl.On("GetPreviousErrors",
 		mock.AnythingOfType("int"),
-		mock.AnythingOfType("int")).Return([]string{}, nil)
+		mock.AnythingOfType("int")).Return([]string{}, 0, nil)
 	mLocalImage.On("OpenFile", mock.AnythingOfType("string")).Return(mFile, nil)
 	mLocalImage.On("InitImageListScanner", mock.AnythingOfType("*interfaces.mockFile")).
 		Return(mScanner)
@@ -398,11 +547,10 @@ func TestSyncOverTheLimit(t *testing.T) {
 	mFile := &mockFile{}
 	mLogger := &mockLogger{}
 	mImageService.On("GetMaxConcurrency").Return(1)
-	mErrorControl.On("GetErrorsPagesQty", mock.AnythingOfType("int")).Return(1)
 	mMetricsExposer.On("IncrementCounter", mock.AnythingOfType("int"))
 	mErrorControl.On("GetPreviousErrors",
 		mock.AnythingOfType("int"),
-		mock.AnythingOfType("int")).Return([]string{}, nil)
+		mock.AnythingOfType("int")).Return([]string{}, 0, nil)
 	mLocalImage.On("OpenFile", mock.AnythingOfType("string")).Return(mFile, nil)
 	mLocalImage.On("InitImageListScanner", mock.AnythingOfType("*interfaces.mockFile")).
 		Return(mScanner)
@@ -459,11 +607,10 @@ func TestSyncErrorOpeningFile(t *testing.T) {
 	mLogger := &mockLogger{}
 	// images to send
 	mImageService.On("GetMaxConcurrency").Return(1)
-	mErrorControl.On("GetErrorsPagesQty", mock.AnythingOfType("int")).Return(1)
 
 	mErrorControl.On("GetPreviousErrors",
 		mock.AnythingOfType("int"),
-		mock.AnythingOfType("int")).Return([]string{}, nil)
+		mock.AnythingOfType("int")).Return([]string{}, 0, nil)
 
 	mLogger.On("LogRetryPreviousFailedUploads")
 	mLogger.On("LogReadingNewImages")
@@ -509,13 +656,15 @@ func TestRetryPreviousFailedUploads(t *testing.T) {
 	mMetricsExposer := &mockMetricsExposer{}
 	mLogger := &mockLogger{}
 	mImageService.On("GetMaxConcurrency").Return(1)
-	mErrorControl.On("GetErrorsPagesQty", mock.AnythingOfType("int")).Return(1)
 	mMetricsExposer.On("IncrementCounter", mock.AnythingOfType("int"))
 
 	imagesToRetrySend := []string{"0.jpg", "1.jpg", "2.jpg"}
 	mErrorControl.On("GetPreviousErrors",
 		mock.AnythingOfType("int"),
-		mock.AnythingOfType("int")).Return(imagesToRetrySend, nil).Once()
+		mock.AnythingOfType("int")).Return(imagesToRetrySend, len(imagesToRetrySend), nil).Once()
+	mErrorControl.On("GetPreviousErrors",
+		mock.AnythingOfType("int"),
+		mock.AnythingOfType("int")).Return([]string{}, 0, nil)
 	for i, testCases := 0, len(imagesToRetrySend); i < testCases; i++ {
 		switch i {
 		case 0: // Happy case: Everything OK
@@ -528,6 +677,12 @@ func TestRetryPreviousFailedUploads(t *testing.T) {
 			err := fmt.Errorf("Error")
 			mLocalImage.On("GetLocalImage", imagesToRetrySend[i]).
 				Return(domain.Image{}, err).Once()
+			mMetricsExposer.On("IncrementCounterWithLabel", domain.LocalImageErrors, "local_read").Once()
+			mErrorControl.On("IncreaseErrorCounter", imagesToRetrySend[i], ErrorDetail{
+				Class:     "local_read",
+				Message:   "Error",
+				Retryable: true,
+			}).Return(nil).Once()
 		case 2: // Image will be synchronized in this process and is not necessary to upload again
 			err := fmt.Errorf("Error")
 			image := domain.Image{
@@ -573,11 +728,10 @@ func TestRetryPreviousFailedUploadsErrorGettingErrors(t *testing.T) {
 	mMetricsExposer := &mockMetricsExposer{}
 
 	mImageService.On("GetMaxConcurrency").Return(1)
-	mErrorControl.On("GetErrorsPagesQty", mock.AnythingOfType("int")).Return(1)
 	err := fmt.Errorf("Error")
 	mErrorControl.On("GetPreviousErrors",
 		mock.AnythingOfType("int"),
-		mock.AnythingOfType("int")).Return([]string{}, err).Once()
+		mock.AnythingOfType("int")).Return([]string{}, 0, err).Once()
 
 	layout := "20060102T150405"
 	newDate, _ := time.Parse(layout, "20170102T150405")
@@ -605,6 +759,12 @@ func TestErrorControl(t *testing.T) {
 	mLocalImage := &mockLocalImage{}
 	mMetricsExposer := &mockMetricsExposer{}
 	mLogger := &mockLogger{}
+	internalErrorDetail := ErrorDetail{
+		Class:      "internal",
+		HTTPStatus: 500,
+		Message:    "internal error",
+		Retryable:  true,
+	}
 
 	layout := "20060102T150405"
 	newDate, _ := time.Parse(layout, "20170102T150405")
@@ -628,14 +788,14 @@ func TestErrorControl(t *testing.T) {
 		case 0: // Error nil, clean error marks ok
 			mErrorControl.On("CleanErrorMarks", mock.AnythingOfType("string")).
 				Return(nil).Once()
-			cli.sendErrorControl(image, domain.SWRetry, remoteChecksum, nil)
+			cli.sendErrorControl(noopSpan{}, image, domain.SWRetry, remoteChecksum, nil)
 
 		case 1: // Error nil, clean error marks error
 			mErrorControl.On("CleanErrorMarks", mock.AnythingOfType("string")).
 				Return(fmt.Errorf("err")).Once()
 			mLogger.On("LogErrorCleaningMarks", mock.AnythingOfType("string"),
 				mock.AnythingOfType("*errors.errorString")).Once()
-			cli.sendErrorControl(image, domain.SWRetry, remoteChecksum, nil)
+			cli.sendErrorControl(noopSpan{}, image, domain.SWRetry, remoteChecksum, nil)
 
 		case 2: // Error duplicated, different checksums
 			image.Metadata.Checksum, remoteChecksum = "the same", "not the same"
@@ -643,17 +803,18 @@ func TestErrorControl(t *testing.T) {
 				Return(yamsErrNil).Once()
 			mErrorControl.On("SetErrorCounter", mock.AnythingOfType("string"), 0).
 				Return(nil).Once()
-			cli.sendErrorControl(image, domain.SWRetry, remoteChecksum, usecases.ErrYamsDuplicate)
+			cli.sendErrorControl(noopSpan{}, image, domain.SWRetry, remoteChecksum, usecases.ErrYamsDuplicate)
 
 		case 3: // Error duplicated, different checksums & error with remote delete
 			image.Metadata.Checksum, remoteChecksum = "the same", "not the same"
 			mImageService.On("RemoteDelete", mock.AnythingOfType("string"), true).
-				Return(usecases.ErrYamsInternal).Once()
-			mLogger.On("LogErrorRemoteDelete", mock.AnythingOfType("string"), usecases.ErrYamsInternal).
+				Return(usecases.ErrYamsInternal.WithStatus(500)).Once()
+			mLogger.On("LogErrorRemoteDelete", mock.AnythingOfType("string"), usecases.ErrYamsInternal.WithStatus(500)).
 				Return().Once()
-			mErrorControl.On("IncreaseErrorCounter", mock.AnythingOfType("string")).
+			mMetricsExposer.On("IncrementCounterWithLabel", domain.UploadErrors, "internal").Once()
+			mErrorControl.On("IncreaseErrorCounter", mock.AnythingOfType("string"), internalErrorDetail).
 				Return(nil).Once()
-			cli.sendErrorControl(image, domain.SWRetry, remoteChecksum, usecases.ErrYamsDuplicate)
+			cli.sendErrorControl(noopSpan{}, image, domain.SWRetry, remoteChecksum, usecases.ErrYamsDuplicate)
 
 		case 4: // Error duplicated, different checksums & error with SetErrorCounter()
 			image.Metadata.Checksum, remoteChecksum = "the same", "not the same"
@@ -663,19 +824,24 @@ func TestErrorControl(t *testing.T) {
 				Return(fmt.Errorf("error")).Once()
 			mLogger.On("LogErrorResetingErrorCounter", mock.AnythingOfType("string"),
 				mock.AnythingOfType("*errors.errorString")).Once()
-			cli.sendErrorControl(image, domain.SWRetry, remoteChecksum, usecases.ErrYamsDuplicate)
+			mMetricsExposer.On("IncrementCounterWithLabel", domain.DBErrors, "set_error_counter").Once()
+			cli.sendErrorControl(noopSpan{}, image, domain.SWRetry, remoteChecksum, usecases.ErrYamsDuplicate)
 
 		case 5: // Error duplicated, same checksums, skip because it was already uploaded
 			image.Metadata.Checksum, remoteChecksum = "the same", "the same"
-			cli.sendErrorControl(image, domain.SWUpload, remoteChecksum, usecases.ErrYamsDuplicate)
+			cli.sendErrorControl(noopSpan{}, image, domain.SWUpload, remoteChecksum, usecases.ErrYamsDuplicate)
 		case 6: // Error default, increase error counter error
-			mErrorControl.On("IncreaseErrorCounter", mock.AnythingOfType("string")).
+			mErrorControl.On("IncreaseErrorCounter", mock.AnythingOfType("string"), internalErrorDetail).
 				Return(fmt.Errorf("error")).Once()
 			mLogger.On("LogErrorIncreasingErrorCounter", mock.AnythingOfType("string"),
 				mock.AnythingOfType("*errors.errorString")).Once()
-			cli.sendErrorControl(image, domain.SWUpload, remoteChecksum, usecases.ErrYamsInternal)
+			mMetricsExposer.On("IncrementCounterWithLabel", domain.UploadErrors, "internal").Once()
+			mMetricsExposer.On("IncrementCounterWithLabel", domain.DBErrors, "increase_error_counter").Once()
+			cli.sendErrorControl(noopSpan{}, image, domain.SWUpload, remoteChecksum, usecases.ErrYamsInternal.WithStatus(500))
 		}
 	}
+	// failures storing the error marks are counted
+	assert.Equal(t, 2, cli.stats.Snapshot()["db_errors"])
 	mImageService.AssertExpectations(t)
 	mErrorControl.AssertExpectations(t)
 	mLocalImage.AssertExpectations(t)
@@ -768,7 +934,7 @@ func TestDelete(t *testing.T) {
 	yamsErrResponse := (*usecases.YamsRepositoryError)(nil)
 	mImageService.On("RemoteDelete", mock.AnythingOfType("string"), true).Return(yamsErrResponse)
 	err := cli.Delete("foto.jpg")
-	assert.Nil(t, err)
+	assert.NoError(t, err)
 	mImageService.AssertExpectations(t)
 }
 
@@ -794,6 +960,7 @@ func TestDeleteAll(t *testing.T) {
 	mImageService.On("RemoteDelete", mock.AnythingOfType("string"), true).Return(yamsNilResponse).Once()
 	mLocalImage.On("GetLocalImage", mock.AnythingOfType("string")).Return(domain.Image{}, fmt.Errorf("err")).Once()
 	mImageService.On("RemoteDelete", mock.AnythingOfType("string"), true).Return(usecases.ErrYamsInternal).Once()
+	mMetricsExposer.On("IncrementCounterWithLabel", domain.DeleteErrors, "internal").Once()
 	// Get list page two but with error, keep the continuation token.
 	mImageService.On("List", mock.AnythingOfType("string"), mock.AnythingOfType("int")).Return([]usecases.YamsObject{}, "", usecases.ErrYamsInternal).Once()
 
@@ -824,8 +991,6 @@ func TestCloseSync(t *testing.T) {
 
 	layout := "20060102T150405"
 	cli := NewCLIYams(nil, nil, mLastSync, nil, mLogger, time.Now(), NewStats(mMetricsExposer), layout)
-	quit := <-cli.quit
-	cli.quit <- !quit
 	inProgress := <-cli.inProgressTimestamps
 	inProgress = append(
 		inProgress,
@@ -866,8 +1031,6 @@ func TestCloseDeleteAll(t *testing.T) {
 
 	layout := "20060102T150405"
 	cli := NewCLIYams(nil, nil, mLastSync, nil, mLogger, time.Now(), NewStats(mMetricsExposer), layout)
-	quit := <-cli.quit
-	cli.quit <- !quit
 
 	mLastSync.On("GetLastSynchronizationMark").Return(time.Now().Add(2 * time.Hour))
 
@@ -910,20 +1073,114 @@ func TestSendWorker(t *testing.T) {
 	}
 	testImages := []string{"1.jpg", "2.jpg"}
 	image := domain.Image{}
-	for i, imageName := range testImages {
+	for _, imageName := range testImages {
 		image.Metadata.ImageName = imageName
 		image.Metadata.ModTime = time.Now()
 		jobs <- image
-		quit := <-cli.quit
-		if i > 0 { // in case of sys interrumption
-			quit = true
-		}
-		cli.quit <- quit
 	}
+	close(jobs)
+	waitGroup.Wait()
 	mImageService.AssertExpectations(t)
 	mMetricsExposer.AssertExpectations(t)
 }
 
+func TestSendWorkerQuarantine(t *testing.T) {
+	mImageService := &mockImageService{}
+	mMetricsExposer := &mockMetricsExposer{}
+	mValidator := &mockImageValidator{}
+	mQuarantine := &mockQuarantine{}
+	mLogger := &mockLogger{}
+	var waitGroup sync.WaitGroup
+
+	jobs := make(chan domain.Image)
+	yamsErrNil := (*usecases.YamsRepositoryError)(nil)
+
+	invalidImage := domain.Image{Path: "a/1.jpg"}
+	invalidImage.Metadata.ImageName = "1.jpg"
+	invalidImage.Metadata.ModTime = time.Now()
+	validImage := domain.Image{}
+	validImage.Metadata.ImageName = "2.jpg"
+	validImage.Metadata.ModTime = time.Now()
+
+	mValidator.On("Validate", invalidImage).Return(usecases.ErrImageTruncated).Once()
+	mValidator.On("Validate", validImage).Return(nil).Once()
+	// images are quarantined by path, the same name may be in other folders
+	mQuarantine.On("Add", "a/1.jpg", usecases.ErrImageTruncated.Error()).Return(fmt.Errorf("err")).Once()
+	mLogger.On("LogQuarantinedImage", "1.jpg", usecases.ErrImageTruncated).Once()
+	mLogger.On("LogErrorQuarantiningImage", "a/1.jpg", mock.AnythingOfType("*errors.errorString")).Once()
+	mImageService.On("Send", validImage).Return("", yamsErrNil).Once()
+	mMetricsExposer.On("IncrementCounter", domain.QuarantinedImages).Once()
+	mMetricsExposer.On("IncrementCounter", domain.SentImages).Once()
+
+	layout := "20060102T150405"
+
+	cli := NewCLIYams(mImageService, nil, nil, nil, mLogger, time.Now(), NewStats(mMetricsExposer), layout)
+	cli.SetImageValidation(mValidator, mQuarantine)
+
+	waitGroup.Add(1)
+	go cli.sendWorker(0, jobs, &waitGroup, domain.SWUpload)
+	jobs <- invalidImage
+	jobs <- validImage
+	close(jobs)
+	waitGroup.Wait()
+
+	quarantined := <-cli.stats.Quarantined
+	sent := <-cli.stats.Sent
+	assert.Equal(t, 1, quarantined)
+	assert.Equal(t, 1, sent)
+	mImageService.AssertExpectations(t)
+	mMetricsExposer.AssertExpectations(t)
+	mValidator.AssertExpectations(t)
+	mQuarantine.AssertExpectations(t)
+	mLogger.AssertExpectations(t)
+}
+
+func TestSendWorkerValidationReadError(t *testing.T) {
+	t.Parallel()
+	mImageService := &mockImageService{}
+	mErrorControl := &mockErrorControl{}
+	mMetricsExposer := &mockMetricsExposer{}
+	mValidator := &mockImageValidator{}
+	mQuarantine := &mockQuarantine{}
+	var waitGroup sync.WaitGroup
+
+	jobs := make(chan domain.Image)
+	readErr := fmt.Errorf("input/output error")
+	image := domain.Image{Path: "fo/1.jpg"}
+	image.Metadata.ImageName = "1.jpg"
+	image.Metadata.ModTime = time.Now()
+
+	mValidator.On("Validate", image).Return(readErr).Once()
+	mErrorControl.On("IncreaseErrorCounter", "fo/1.jpg", ErrorDetail{
+		Class:     "local_read",
+		Message:   "input/output error",
+		Retryable: true,
+	}).Return(nil).Once()
+	mMetricsExposer.On("IncrementCounter", domain.FailedUploads).Once()
+	mMetricsExposer.On("IncrementCounterWithLabel", domain.UploadErrors, "local_read").Once()
+
+	layout := "20060102T150405"
+	lastSync := image.Metadata.ModTime.Add(-time.Hour)
+	cli := NewCLIYams(mImageService, mErrorControl, nil, nil, nil, lastSync, NewStats(mMetricsExposer), layout)
+	cli.SetImageValidation(mValidator, mQuarantine)
+
+	waitGroup.Add(1)
+	go cli.sendWorker(0, jobs, &waitGroup, domain.SWUpload)
+	jobs <- image
+	close(jobs)
+	waitGroup.Wait()
+
+	// the image is not quarantined & it is retried in the next syncs
+	assert.Equal(t, 0, <-cli.stats.Quarantined)
+	assert.Equal(t, 1, <-cli.stats.Errors)
+	assert.Equal(t, lastSync, <-cli.lastSyncDate)
+	mImageService.AssertExpectations(t)
+	mErrorControl.AssertExpectations(t)
+	mMetricsExposer.AssertExpectations(t)
+	mValidator.AssertExpectations(t)
+	mQuarantine.AssertExpectations(t)
+}
+
 func TestRetrySendWorker(t *testing.T) {
 	t.Parallel()
 	mImageService := &mockImageService{}
@@ -944,8 +1201,6 @@ func TestRetrySendWorker(t *testing.T) {
 	layout := "20060102T150405"
 
 	cli := NewCLIYams(mImageService, mErrorControl, mLastSync, nil, nil, time.Now(), NewStats(mMetricsExposer), layout)
-	<-cli.quit
-	cli.quit <- true
 	for w := 0; w < 1; w++ {
 		waitGroup.Add(1)
 		go cli.retrySendWorker(w, jobs, &waitGroup)
@@ -965,7 +1220,7 @@ func TestRetrySendWorker(t *testing.T) {
 	mLastSync.AssertExpectations(t)
 }
 
-func TestRetryOnClosedChann(t *testing.T) {
+func TestRetrySendWorkerStopped(t *testing.T) {
 	t.Parallel()
 	mImageService := &mockImageService{}
 	mMetricsExposer := &mockMetricsExposer{}
@@ -984,8 +1239,8 @@ func TestRetryOnClosedChann(t *testing.T) {
 	layout := "20060102T150405"
 
 	cli := NewCLIYams(mImageService, mErrorControl, mLastSync, nil, nil, time.Now(), NewStats(mMetricsExposer), layout)
-	close(cli.quit)
-	<-cli.quit
+	// images received by the workers are completed after Stop
+	cli.Stop()
 	for w := 0; w < 1; w++ {
 		waitGroup.Add(1)
 		go cli.retrySendWorker(w, jobs, &waitGroup)
@@ -1005,7 +1260,7 @@ func TestRetryOnClosedChann(t *testing.T) {
 	mLastSync.AssertExpectations(t)
 }
 
-func TestSendWorkerWithClosedChannel(t *testing.T) {
+func TestSendWorkerStopped(t *testing.T) {
 	t.Parallel()
 	mImageService := &mockImageService{}
 	mMetricsExposer := &mockMetricsExposer{}
@@ -1033,13 +1288,12 @@ func TestSendWorkerWithClosedChannel(t *testing.T) {
 		image.Metadata.ImageName = imageName
 		image.Metadata.ModTime = time.Now()
 		jobs <- image
-		quit := <-cli.quit
-		if i > 0 { // in case of close the channel
-			close(cli.quit)
-		} else {
-			cli.quit <- quit
+		if i == 0 {
+			cli.Stop()
 		}
 	}
+	close(jobs)
+	waitGroup.Wait()
 	mImageService.AssertExpectations(t)
 	mMetricsExposer.AssertExpectations(t)
 }
@@ -1066,18 +1320,15 @@ func TestDeleteWorker(t *testing.T) {
 	}
 
 	testImages := []string{"1.jpg", "2.j:g"}
-	for i, imageName := range testImages {
+	for _, imageName := range testImages {
 		jobs <- domain.Image{
 			Metadata: domain.ImageMetadata{
 				ImageName: imageName,
 			},
 		}
-		quit := <-cli.quit
-		if i > 0 { // in case of sys interrumption
-			quit = true
-		}
-		cli.quit <- quit
 	}
+	close(jobs)
+	waitGroup.Wait()
 	mLogger.AssertExpectations(t)
 	mImageService.AssertExpectations(t)
 	mMetricsExposer.AssertExpectations(t)
@@ -1092,11 +1343,7 @@ func TestShowStatsWithInterrumption(t *testing.T) {
 	cli.showStats()
 	ticker := time.Tick(time.Second + time.Millisecond*500)
 	<-ticker
-	<-cli.quit
-	cli.quit <- false
-	<-ticker
-	<-cli.quit
-	cli.quit <- true
+	cli.Stop()
 	<-ticker
 	mLogger.AssertExpectations(t)
 	mMetricsExposer.AssertExpectations(t)
@@ -1111,8 +1358,7 @@ func TestShowStats(t *testing.T) {
 	cli := NewCLIYams(nil, nil, nil, nil, mLogger, time.Now(), NewStats(mMetricsExposer), layout)
 	cli.showStats()
 	ticker := time.Tick(time.Second + time.Millisecond*500)
-	<-cli.quit
-	cli.quit <- true
+	cli.Stop()
 	<-ticker
 	mLogger.AssertExpectations(t)
 	mMetricsExposer.AssertExpectations(t)
@@ -1160,6 +1406,37 @@ func TestGetMarksError(t *testing.T) {
 	mLastSync.AssertExpectations(t)
 }
 
+func TestGetQuarantine(t *testing.T) {
+	mMetricsExposer := &mockMetricsExposer{}
+	mQuarantine := &mockQuarantine{}
+	mLogger := &mockLogger{}
+	layout := "20060102T150405"
+	mQuarantine.On("List").Return([]string{"foo.jpg"}, nil)
+	mLogger.On("LogQuarantineList", []string{"foo.jpg"})
+	cli := NewCLIYams(nil, nil, nil, nil, mLogger, time.Now(), NewStats(mMetricsExposer), layout)
+	cli.SetImageValidation(nil, mQuarantine)
+	err := cli.GetQuarantine()
+	assert.NoError(t, err)
+	mLogger.AssertExpectations(t)
+	mMetricsExposer.AssertExpectations(t)
+	mQuarantine.AssertExpectations(t)
+}
+
+func TestGetQuarantineError(t *testing.T) {
+	mMetricsExposer := &mockMetricsExposer{}
+	mQuarantine := &mockQuarantine{}
+	mLogger := &mockLogger{}
+	layout := "20060102T150405"
+	mQuarantine.On("List").Return([]string{}, fmt.Errorf("err"))
+	cli := NewCLIYams(nil, nil, nil, nil, mLogger, time.Now(), NewStats(mMetricsExposer), layout)
+	cli.SetImageValidation(nil, mQuarantine)
+	err := cli.GetQuarantine()
+	assert.Error(t, err)
+	mLogger.AssertExpectations(t)
+	mMetricsExposer.AssertExpectations(t)
+	mQuarantine.AssertExpectations(t)
+}
+
 func TestRemoveElement(t *testing.T) {
 	element1, element2 := time.Now(), time.Time{}
 	cases := []struct {
@@ -1188,3 +1465,109 @@ func TestRemoveElement(t *testing.T) {
 		assert.Equal(t, v.expected, result)
 	}
 }
+
+type mockConcurrencyLimiter struct {
+	mock.Mock
+}
+
+func (m *mockConcurrencyLimiter) Acquire() {
+	m.Called()
+}
+
+func (m *mockConcurrencyLimiter) Release(latency time.Duration, err *usecases.YamsRepositoryError) {
+	m.Called(latency, err)
+}
+
+func (m *mockConcurrencyLimiter) MaxLimit() int {
+	args := m.Called()
+	return args.Int(0)
+}
+
+func TestSendWithConcurrencyLimiter(t *testing.T) {
+	mImageService := &mockImageService{}
+	mLimiter := &mockConcurrencyLimiter{}
+
+	image := domain.Image{}
+	mImageService.On("GetMaxConcurrency").Return(20)
+	mImageService.On("Send", image).Return("checksum", usecases.ErrYamsInternal)
+	mLimiter.On("MaxLimit").Return(30)
+	mLimiter.On("Acquire").Once()
+	mLimiter.On("Release", mock.AnythingOfType("time.Duration"), usecases.ErrYamsInternal).Once()
+
+	cli := NewCLIYams(mImageService, nil, nil, nil, nil, time.Now(), Stats{}, "")
+	assert.Equal(t, 5, cli.sendWorkers(5))
+
+	cli.SetConcurrencyLimiter(mLimiter)
+	// workers are bounded by yams max concurrency
+	assert.Equal(t, 20, cli.sendWorkers(5))
+
+	checksum, err := cli.send(noopSpan{}, image)
+	assert.Equal(t, "checksum", checksum)
+	assert.Equal(t, usecases.ErrYamsInternal, err)
+
+	mImageService.AssertExpectations(t)
+	mLimiter.AssertExpectations(t)
+}
+
+type mockSyncSchedule struct {
+	mock.Mock
+}
+
+func (m *mockSyncSchedule) IsAllowed(t time.Time) bool {
+	args := m.Called(t)
+	return args.Bool(0)
+}
+
+func (m *mockSyncSchedule) NextAllowed(t time.Time) time.Time {
+	args := m.Called(t)
+	return args.Get(0).(time.Time)
+}
+
+func TestWaitForSyncWindow(t *testing.T) {
+	mLastSync := &mockLastSync{}
+	mLogger := &mockLogger{}
+	mSchedule := &mockSyncSchedule{}
+
+	syncMark := time.Now()
+	resumeAt := time.Now().Add(20 * time.Millisecond)
+	mSchedule.On("IsAllowed", mock.AnythingOfType("time.Time")).Return(false).Once()
+	mSchedule.On("IsAllowed", mock.AnythingOfType("time.Time")).Return(true)
+	mSchedule.On("NextAllowed", mock.AnythingOfType("time.Time")).Return(resumeAt)
+	mLastSync.On("GetLastSynchronizationMark").Return(syncMark.Add(-time.Hour))
+	// progress is saved before pausing
+	mLastSync.On("SetLastSynchronizationMark", syncMark).Return(nil).Once()
+	mLogger.On("LogSyncPaused", resumeAt).Once()
+	mLogger.On("LogSyncResumed").Once()
+
+	cli := NewCLIYams(nil, nil, mLastSync, nil, mLogger, syncMark, Stats{}, "")
+	cli.isSync = true
+	cli.SetSyncSchedule(mSchedule)
+	assert.True(t, cli.waitForSyncWindow())
+
+	// without schedule synchronization is always allowed
+	cli.SetSyncSchedule(nil)
+	assert.True(t, cli.waitForSyncWindow())
+
+	mLastSync.AssertExpectations(t)
+	mLogger.AssertExpectations(t)
+}
+
+func TestWaitForSyncWindowQuit(t *testing.T) {
+	mLastSync := &mockLastSync{}
+	mLogger := &mockLogger{}
+	mSchedule := &mockSyncSchedule{}
+
+	mSchedule.On("IsAllowed", mock.AnythingOfType("time.Time")).Return(false)
+	mSchedule.On("NextAllowed", mock.AnythingOfType("time.Time")).Return(time.Now().Add(time.Hour))
+	mLastSync.On("GetLastSynchronizationMark").Return(time.Now())
+	mLogger.On("LogSyncPaused", mock.AnythingOfType("time.Time")).Once()
+
+	cli := NewCLIYams(nil, nil, mLastSync, nil, mLogger, time.Now().Add(-time.Hour), Stats{}, "")
+	cli.isSync = true
+	cli.SetSyncSchedule(mSchedule)
+	cli.Stop()
+	assert.False(t, cli.waitForSyncWindow())
+
+	mLastSync.AssertExpectations(t)
+	mLogger.AssertExpectations(t)
+}
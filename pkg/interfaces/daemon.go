@@ -0,0 +1,294 @@
+package interfaces
+
+import (
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DaemonError is the error returned by daemon operations
+type DaemonError struct {
+	ErrorString string
+}
+
+func (de *DaemonError) Error() string { return de.ErrorString }
+
+var (
+	// ErrDaemonJobRunning is returned when a job is triggered while another
+	// job is running
+	ErrDaemonJobRunning = &DaemonError{"a job is already running"}
+	// ErrDaemonNoJob is returned when there is no job running to cancel
+	ErrDaemonNoJob = &DaemonError{"there is no job running"}
+)
+
+// BreakerStates allows to know the state of circuit breakers
+type BreakerStates interface {
+	// States returns the state of each circuit breaker by name
+	States() map[string]string
+}
+
+// DaemonLogger logs daemon events
+type DaemonLogger interface {
+	LogJobStarted(job string)
+	LogJobFinished(job string, err error)
+	LogJobCancelled(job string)
+	LogErrorPreparingJob(job string, err error)
+}
+
+// DaemonStatus is the status of the daemon and its current job
+type DaemonStatus struct {
+	Running         bool              `json:"running"`
+	Paused          bool              `json:"paused"`
+	LastSyncMark    time.Time         `json:"last_sync_mark"`
+	LastRunStart    time.Time         `json:"last_run_start"`
+	LastRunEnd      time.Time         `json:"last_run_end"`
+	LastError       string            `json:"last_error,omitempty"`
+	NextRun         time.Time         `json:"next_run"`
+	Stats           map[string]int    `json:"stats"`
+	CircuitBreakers map[string]string `json:"circuit_breakers"`
+	Progress        *Progress         `json:"progress,omitempty"`
+}
+
+// Daemon runs sync jobs periodically and on demand, each job uses a new
+// CLIYams so its stats & marks are independent of previous runs
+type Daemon struct {
+	// newCLIYams creates the CLIYams used by each job
+	newCLIYams func() *CLIYams
+	// prepare is executed before each sync, e.g. to generate the dump file
+	prepare           func() error
+	threads           int
+	maxErrorTolerance int
+	dumpFile          string
+	interval          time.Duration
+	lastSync          LastSync
+	breakers          BreakerStates
+	logger            DaemonLogger
+
+	// mutex guards the current job & status fields, current is nil while
+	// the job is being prepared
+	mutex     sync.Mutex
+	running   bool
+	current   *CLIYams
+	cancelled bool
+	paused    bool
+	status    DaemonStatus
+	trigger   chan bool
+	quit      chan bool
+	// loop is done when the daemon loop returns
+	loop sync.WaitGroup
+}
+
+// NewDaemon creates a new instance of Daemon running a sync each interval,
+// if interval is zero syncs run only when they are triggered
+func NewDaemon(newCLIYams func() *CLIYams, prepare func() error, threads, maxErrorTolerance int,
+	dumpFile string, interval time.Duration, lastSync LastSync, breakers BreakerStates, logger DaemonLogger) *Daemon {
+	return &Daemon{
+		newCLIYams:        newCLIYams,
+		prepare:           prepare,
+		threads:           threads,
+		maxErrorTolerance: maxErrorTolerance,
+		dumpFile:          dumpFile,
+		interval:          interval,
+		lastSync:          lastSync,
+		breakers:          breakers,
+		logger:            logger,
+		trigger:           make(chan bool, 1),
+		quit:              make(chan bool),
+	}
+}
+
+// Start launches the daemon loop in background
+func (d *Daemon) Start() {
+	var tick <-chan time.Time
+	if d.interval > 0 {
+		ticker := time.NewTicker(d.interval)
+		tick = ticker.C
+		d.setNextRun(time.Now().Add(d.interval))
+		go func() {
+			<-d.quit
+			ticker.Stop()
+		}()
+	}
+	d.loop.Add(1)
+	go func() {
+		defer d.loop.Done()
+		for {
+			select {
+			case <-tick:
+				d.setNextRun(time.Now().Add(d.interval))
+			case <-d.trigger:
+			case <-d.quit:
+				return
+			}
+			// a job is not started once the daemon is closing
+			select {
+			case <-d.quit:
+				return
+			default:
+				d.runSync()
+			}
+		}
+	}()
+}
+
+// setNextRun sets the next scheduled run in status
+func (d *Daemon) setNextRun(next time.Time) {
+	d.mutex.Lock()
+	d.status.NextRun = next
+	d.mutex.Unlock()
+}
+
+// Trigger asks for a new sync, returns error if a job is already running
+func (d *Daemon) Trigger() error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if d.running {
+		return ErrDaemonJobRunning
+	}
+	select {
+	case d.trigger <- true:
+		return nil
+	default:
+		return ErrDaemonJobRunning
+	}
+}
+
+// Pause pauses the current job and the next ones until Resume is called
+func (d *Daemon) Pause() {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.paused = true
+	if d.current != nil && !d.cancelled {
+		d.current.Pause()
+	}
+}
+
+// Resume resumes the current job & allows the next ones to run
+func (d *Daemon) Resume() {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.paused = false
+	if d.current != nil && !d.cancelled {
+		d.current.Resume()
+	}
+}
+
+// Cancel asks the current job to stop, it keeps its progress in the sync
+// mark once its images in progress are done. A job being prepared doesn't
+// start
+func (d *Daemon) Cancel() error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if !d.running || d.cancelled {
+		return ErrDaemonNoJob
+	}
+	d.cancelled = true
+	d.logger.LogJobCancelled("sync")
+	if d.current != nil {
+		d.current.Stop()
+	}
+	return nil
+}
+
+// Status returns the status of the daemon and its current job
+func (d *Daemon) Status() DaemonStatus {
+	d.mutex.Lock()
+	status := d.status
+	status.Paused = d.paused
+	status.Running = d.running
+	if d.current != nil {
+		status.Stats = d.current.stats.Snapshot()
+		progress := d.current.Progress()
+		status.Progress = &progress
+	}
+	d.mutex.Unlock()
+
+	status.LastSyncMark = d.lastSync.GetLastSynchronizationMark()
+	if d.breakers != nil {
+		status.CircuitBreakers = d.breakers.States()
+	}
+	return status
+}
+
+// Progress returns the progress of the current job, empty if no job is running
+func (d *Daemon) Progress() Progress {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if d.current == nil {
+		return Progress{InProgress: []string{}}
+	}
+	return d.current.Progress()
+}
+
+// runSync runs a new sync job waiting until it is done
+func (d *Daemon) runSync() {
+	d.mutex.Lock()
+	d.running = true
+	d.cancelled = false
+	d.status.LastRunStart = time.Now()
+	d.mutex.Unlock()
+
+	if d.prepare != nil {
+		if err := d.prepare(); err != nil {
+			d.logger.LogErrorPreparingJob("sync", err)
+			d.finish(err)
+			return
+		}
+	}
+
+	d.mutex.Lock()
+	if d.cancelled {
+		d.mutex.Unlock()
+		d.finish(nil)
+		return
+	}
+	cli := d.newCLIYams()
+	d.current = cli
+	if d.paused {
+		cli.Pause()
+	}
+	d.mutex.Unlock()
+
+	d.logger.LogJobStarted("sync")
+	cli.StartRun("sync", map[string]string{
+		"mode":     "daemon",
+		"threads":  strconv.Itoa(d.threads),
+		"dumpfile": d.dumpFile,
+	})
+	err := cli.Sync(d.threads, 0, d.maxErrorTolerance, d.dumpFile)
+	cli.SetRunResult(err)
+	// the job is closed once Sync returned, cancelled or not, saving its
+	// progress & releasing the run lock
+	if e := cli.Close(); e != nil && err == nil {
+		err = e
+	}
+	d.finish(err)
+}
+
+// finish records the end of a job in status
+func (d *Daemon) finish(err error) {
+	d.logger.LogJobFinished("sync", err)
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if d.current != nil {
+		d.status.Stats = d.current.stats.Snapshot()
+	}
+	d.current = nil
+	d.running = false
+	d.status.LastRunEnd = time.Now()
+	d.status.LastError = ""
+	if err != nil {
+		d.status.LastError = err.Error()
+	}
+}
+
+// Close stops the daemon loop, cancelling the current job & waiting for it
+// to be closed
+func (d *Daemon) Close() error {
+	close(d.quit)
+	if err := d.Cancel(); err != nil && err != ErrDaemonNoJob {
+		return err
+	}
+	d.loop.Wait()
+	return nil
+}
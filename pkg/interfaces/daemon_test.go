@@ -0,0 +1,212 @@
+package interfaces
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type mockDaemonLogger struct {
+	mock.Mock
+}
+
+func (m *mockDaemonLogger) LogJobStarted(job string) {
+	m.Called(job)
+}
+
+func (m *mockDaemonLogger) LogJobFinished(job string, err error) {
+	m.Called(job, err)
+}
+
+func (m *mockDaemonLogger) LogJobCancelled(job string) {
+	m.Called(job)
+}
+
+func (m *mockDaemonLogger) LogErrorPreparingJob(job string, err error) {
+	m.Called(job, err)
+}
+
+type mockBreakerStates struct {
+	mock.Mock
+}
+
+func (m *mockBreakerStates) States() map[string]string {
+	args := m.Called()
+	return args.Get(0).(map[string]string)
+}
+
+// waitJobEnd waits until the daemon finishes a job
+func waitJobEnd(t *testing.T, daemon *Daemon) DaemonStatus {
+	for i := 0; i < 100; i++ {
+		if status := daemon.Status(); !status.LastRunEnd.IsZero() && !status.Running {
+			return status
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("daemon job did not finish")
+	return DaemonStatus{}
+}
+
+func TestDaemonStatus(t *testing.T) {
+	mLastSync := &mockLastSync{}
+	mBreakers := &mockBreakerStates{}
+	mark := time.Now()
+	mLastSync.On("GetLastSynchronizationMark").Return(mark)
+	mBreakers.On("States").Return(map[string]string{"HTTP_SEND_POST": "closed"})
+
+	daemon := NewDaemon(nil, nil, 1, 1, "dump", 0, mLastSync, mBreakers, nil)
+	daemon.Pause()
+	status := daemon.Status()
+	assert.False(t, status.Running)
+	assert.True(t, status.Paused)
+	assert.Equal(t, mark, status.LastSyncMark)
+	assert.Equal(t, map[string]string{"HTTP_SEND_POST": "closed"}, status.CircuitBreakers)
+
+	daemon.Resume()
+	assert.False(t, daemon.Status().Paused)
+	assert.Equal(t, ErrDaemonNoJob, daemon.Cancel())
+}
+
+func TestDaemonTriggerSync(t *testing.T) {
+	mImageService := &mockImageService{}
+	mErrorControl := &mockErrorControl{}
+	mLastSync := &mockLastSync{}
+	mLocalImage := &mockLocalImage{}
+	mLogger := &mockLogger{}
+	mDaemonLogger := &mockDaemonLogger{}
+	mMetricsExposer := &mockMetricsExposer{}
+
+	openErr := fmt.Errorf("dump file not found")
+	mImageService.On("GetMaxConcurrency").Return(10)
+	mErrorControl.On("GetPreviousErrors", 0, 3).Return([]string{}, 0, nil)
+	mLastSync.On("GetLastSynchronizationMark").Return(time.Now())
+	mLocalImage.On("OpenFile", "dump").Return(&mockFile{}, openErr)
+	mLogger.On("LogStats", mock.Anything, mock.Anything)
+	mLogger.On("LogRetryPreviousFailedUploads")
+	mLogger.On("LogReadingNewImages")
+	mLogger.On("LogErrorGettingImagesList", "dump", openErr)
+	mDaemonLogger.On("LogJobStarted", "sync").Once()
+	mDaemonLogger.On("LogJobFinished", "sync", openErr).Once()
+
+	newCLIYams := func() *CLIYams {
+		return NewCLIYams(mImageService, mErrorControl, mLastSync, mLocalImage, mLogger,
+			time.Now(), NewStats(mMetricsExposer), "")
+	}
+	daemon := NewDaemon(newCLIYams, nil, 5, 3, "dump", 0, mLastSync, nil, mDaemonLogger)
+	daemon.Start()
+	defer daemon.Close() // nolint
+
+	assert.NoError(t, daemon.Trigger())
+	status := waitJobEnd(t, daemon)
+	assert.Equal(t, openErr.Error(), status.LastError)
+	assert.Equal(t, 0, status.Stats["sent"])
+	mDaemonLogger.AssertExpectations(t)
+}
+
+func TestDaemonPrepareError(t *testing.T) {
+	mLastSync := &mockLastSync{}
+	mDaemonLogger := &mockDaemonLogger{}
+
+	prepareErr := fmt.Errorf("sort failed")
+	mLastSync.On("GetLastSynchronizationMark").Return(time.Now())
+	mDaemonLogger.On("LogErrorPreparingJob", "sync", prepareErr).Once()
+	mDaemonLogger.On("LogJobFinished", "sync", prepareErr).Once()
+
+	prepare := func() error { return prepareErr }
+	daemon := NewDaemon(nil, prepare, 5, 3, "dump", 0, mLastSync, nil, mDaemonLogger)
+	daemon.Start()
+	defer daemon.Close() // nolint
+
+	assert.NoError(t, daemon.Trigger())
+	status := waitJobEnd(t, daemon)
+	assert.Equal(t, prepareErr.Error(), status.LastError)
+	mDaemonLogger.AssertExpectations(t)
+}
+
+// waitJobRunning waits until the daemon starts a job
+func waitJobRunning(t *testing.T, daemon *Daemon) {
+	for i := 0; i < 100; i++ {
+		if daemon.Status().Running {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("daemon job did not start")
+}
+
+func TestDaemonCancelWhilePreparing(t *testing.T) {
+	mLastSync := &mockLastSync{}
+	mDaemonLogger := &mockDaemonLogger{}
+	mLastSync.On("GetLastSynchronizationMark").Return(time.Now())
+	mDaemonLogger.On("LogJobCancelled", "sync").Once()
+	mDaemonLogger.On("LogJobFinished", "sync", nil).Once()
+
+	preparing := make(chan bool)
+	prepare := func() error {
+		<-preparing
+		return nil
+	}
+	newCLIYams := func() *CLIYams {
+		t.Error("cancelled job must not start")
+		return nil
+	}
+	daemon := NewDaemon(newCLIYams, prepare, 5, 3, "dump", 0, mLastSync, nil, mDaemonLogger)
+	daemon.Start()
+	defer daemon.Close() // nolint
+
+	assert.NoError(t, daemon.Trigger())
+	waitJobRunning(t, daemon)
+	assert.NoError(t, daemon.Cancel())
+	assert.Equal(t, ErrDaemonNoJob, daemon.Cancel())
+	close(preparing)
+	status := waitJobEnd(t, daemon)
+	assert.Empty(t, status.LastError)
+	mDaemonLogger.AssertExpectations(t)
+}
+
+func TestDaemonCancelClosesJob(t *testing.T) {
+	mLastSync := &mockLastSync{}
+	mLogger := &mockLogger{}
+	mDaemonLogger := &mockDaemonLogger{}
+	mRunLock := &mockRunLock{}
+	mRunHistory := &mockRunHistory{}
+	mMetricsExposer := &mockMetricsExposer{}
+	holder := LockHolder{Profile: "default", Command: "sync"}
+	mLastSync.On("GetLastSynchronizationMark").Return(time.Now())
+	mRunLock.On("TryAcquire", "sync").Return(false, nil)
+	mRunLock.On("Holder").Return(holder, true, nil)
+	waiting := make(chan bool, 1)
+	mLogger.On("LogWaitingForRunLock", holder).Run(func(mock.Arguments) {
+		select {
+		case waiting <- true:
+		default:
+		}
+	})
+	mDaemonLogger.On("LogJobStarted", "sync").Once()
+	mDaemonLogger.On("LogJobCancelled", "sync").Once()
+	mDaemonLogger.On("LogJobFinished", "sync", &RunLockedError{Holder: holder}).Once()
+	// the job is closed once Sync returns, recording it as interrupted
+	mRunHistory.On("Add", mock.MatchedBy(func(run SyncRun) bool {
+		return run.ExitReason == RunInterrupted
+	})).Return(nil).Once()
+
+	newCLIYams := func() *CLIYams {
+		cli := NewCLIYams(nil, nil, mLastSync, nil, mLogger, time.Now(), NewStats(mMetricsExposer), "")
+		cli.SetRunHistory(mRunHistory)
+		cli.SetRunLock(mRunLock, time.Minute)
+		return cli
+	}
+	daemon := NewDaemon(newCLIYams, nil, 5, 3, "dump", 0, mLastSync, nil, mDaemonLogger)
+	daemon.Start()
+
+	assert.NoError(t, daemon.Trigger())
+	// the job waits for the run lock until it is cancelled
+	<-waiting
+	assert.NoError(t, daemon.Close())
+	assert.False(t, daemon.Status().Running)
+	mDaemonLogger.AssertExpectations(t)
+	mRunHistory.AssertExpectations(t)
+}
@@ -0,0 +1,110 @@
+package interfaces
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// DeadImage is an image moved to the dead letter, it is not retried anymore
+// until it is requeued
+type DeadImage struct {
+	ImagePath      string
+	Reason         string
+	ErrorCounter   int
+	LastErrorClass string
+	LastHTTPStatus int
+	LastFailureAt  time.Time
+	DeadAt         time.Time
+}
+
+// SetDeadLetter enables the dead letter, images over the error tolerance are
+// moved out of the error marks at the end of each sync
+func (cli *CLIYams) SetDeadLetter(deadLetter DeadLetter) {
+	cli.deadLetter = deadLetter
+}
+
+// MoveToDeadLetter moves the images over maxErrorTolerance or parked by a non
+// retryable error to the dead letter. The buffered error marks are written
+// first, so the images failed in the last retry are moved too
+func (cli *CLIYams) MoveToDeadLetter(maxErrorTolerance int) error {
+	cli.flushErrorMarks()
+	count, err := cli.deadLetter.Move(maxErrorTolerance)
+	if err != nil {
+		return err
+	}
+	cli.logger.LogMovedToDeadLetter(count)
+	return nil
+}
+
+// GetDeadLetter gets the images in the dead letter with its reason
+func (cli *CLIYams) GetDeadLetter() error {
+	list, err := cli.deadLetter.List()
+	if err != nil {
+		return err
+	}
+	cli.logger.LogDeadLetterList(list)
+	return nil
+}
+
+// ExportDeadLetter gets the images in the dead letter in the dump file format,
+// dated by its last failure
+func (cli *CLIYams) ExportDeadLetter() error {
+	list, err := cli.deadLetter.List()
+	if err != nil {
+		return err
+	}
+	lines := make([]string, 0, len(list))
+	for _, image := range list {
+		lines = append(lines, fmt.Sprintf("%s %s",
+			image.LastFailureAt.Format(cli.dateLayout),
+			image.ImagePath,
+		))
+	}
+	cli.logger.LogDeadErrors(lines)
+	return nil
+}
+
+// ImportDeadLetter requeues every image listed in the dump file, they are
+// retried in the next sync. Lines out of the dump file format are skipped
+func (cli *CLIYams) ImportDeadLetter(imagesDumpYamsPath string) error {
+	file, err := cli.localImage.OpenFile(imagesDumpYamsPath)
+	if err != nil {
+		cli.logger.LogErrorGettingImagesList(imagesDumpYamsPath, err)
+		return err
+	}
+	defer file.Close() // nolint
+
+	requeued, skipped := 0, 0
+	scanner := cli.localImage.InitImageListScanner(file)
+	for scanner.Scan() {
+		tuple := strings.Split(scanner.Text(), " ")
+		if len(tuple) != 2 {
+			skipped++
+			continue
+		}
+		if _, e := time.Parse(cli.dateLayout, tuple[0]); e != nil {
+			skipped++
+			continue
+		}
+		if e := cli.deadLetter.Requeue(tuple[1]); e != nil {
+			cli.logger.LogErrorRequeuingImage(tuple[1], e)
+			skipped++
+			continue
+		}
+		requeued++
+	}
+	cli.logger.LogDeadLetterRequeued(requeued, skipped)
+	return scanner.Err()
+}
+
+// moveToDeadLetter moves the images over maxErrorTolerance to the dead
+// letter if it is enabled
+func (cli *CLIYams) moveToDeadLetter(maxErrorTolerance int) {
+	if cli.deadLetter == nil {
+		return
+	}
+	if err := cli.MoveToDeadLetter(maxErrorTolerance); err != nil {
+		cli.logger.LogErrorMovingToDeadLetter(err)
+	}
+}
@@ -0,0 +1,164 @@
+package interfaces
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type mockDeadLetter struct {
+	mock.Mock
+}
+
+func (m *mockDeadLetter) Move(maxErrorTolerance int) (int, error) {
+	args := m.Called(maxErrorTolerance)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *mockDeadLetter) List() ([]DeadImage, error) {
+	args := m.Called()
+	return args.Get(0).([]DeadImage), args.Error(1)
+}
+
+func (m *mockDeadLetter) Requeue(imagePath string) error {
+	args := m.Called(imagePath)
+	return args.Error(0)
+}
+
+func TestMoveToDeadLetter(t *testing.T) {
+	mDeadLetter := &mockDeadLetter{}
+	mLogger := &mockLogger{}
+	mDeadLetter.On("Move", 3).Return(2, nil)
+	mLogger.On("LogMovedToDeadLetter", 2)
+
+	cli := NewCLIYams(nil, nil, nil, nil, mLogger, time.Now(), NewStats(nil), "")
+	cli.SetDeadLetter(mDeadLetter)
+	err := cli.MoveToDeadLetter(3)
+	assert.NoError(t, err)
+	mDeadLetter.AssertExpectations(t)
+	mLogger.AssertExpectations(t)
+}
+
+func TestMoveToDeadLetterDisabled(t *testing.T) {
+	cli := NewCLIYams(nil, nil, nil, nil, nil, time.Now(), NewStats(nil), "")
+	cli.moveToDeadLetter(3)
+}
+
+func TestMoveToDeadLetterError(t *testing.T) {
+	mDeadLetter := &mockDeadLetter{}
+	mLogger := &mockLogger{}
+	mDeadLetter.On("Move", 3).Return(0, fmt.Errorf("err"))
+	mLogger.On("LogErrorMovingToDeadLetter", fmt.Errorf("err"))
+
+	cli := NewCLIYams(nil, nil, nil, nil, mLogger, time.Now(), NewStats(nil), "")
+	cli.SetDeadLetter(mDeadLetter)
+	cli.moveToDeadLetter(3)
+	mDeadLetter.AssertExpectations(t)
+	mLogger.AssertExpectations(t)
+}
+
+func TestGetDeadLetter(t *testing.T) {
+	mDeadLetter := &mockDeadLetter{}
+	mLogger := &mockLogger{}
+	list := []DeadImage{{ImagePath: "foo.jpg", Reason: "not retryable: bad image"}}
+	mDeadLetter.On("List").Return(list, nil)
+	mLogger.On("LogDeadLetterList", list)
+
+	cli := NewCLIYams(nil, nil, nil, nil, mLogger, time.Now(), NewStats(nil), "")
+	cli.SetDeadLetter(mDeadLetter)
+	err := cli.GetDeadLetter()
+	assert.NoError(t, err)
+	mDeadLetter.AssertExpectations(t)
+	mLogger.AssertExpectations(t)
+}
+
+func TestExportDeadLetter(t *testing.T) {
+	mDeadLetter := &mockDeadLetter{}
+	mLogger := &mockLogger{}
+	layout := "20060102T150405"
+	failedAt, _ := time.Parse(layout, "20190102T150405")
+	list := []DeadImage{
+		{ImagePath: "foo.jpg", LastFailureAt: failedAt},
+		{ImagePath: "bar.jpg", LastFailureAt: failedAt},
+	}
+	mDeadLetter.On("List").Return(list, nil)
+	mLogger.On("LogDeadErrors", []string{
+		"20190102T150405 foo.jpg",
+		"20190102T150405 bar.jpg",
+	})
+
+	cli := NewCLIYams(nil, nil, nil, nil, mLogger, time.Now(), NewStats(nil), layout)
+	cli.SetDeadLetter(mDeadLetter)
+	err := cli.ExportDeadLetter()
+	assert.NoError(t, err)
+	mDeadLetter.AssertExpectations(t)
+	mLogger.AssertExpectations(t)
+}
+
+func TestExportDeadLetterError(t *testing.T) {
+	mDeadLetter := &mockDeadLetter{}
+	mDeadLetter.On("List").Return([]DeadImage{}, fmt.Errorf("err"))
+
+	cli := NewCLIYams(nil, nil, nil, nil, nil, time.Now(), NewStats(nil), "")
+	cli.SetDeadLetter(mDeadLetter)
+	err := cli.ExportDeadLetter()
+	assert.Error(t, err)
+	mDeadLetter.AssertExpectations(t)
+}
+
+func TestImportDeadLetter(t *testing.T) {
+	mDeadLetter := &mockDeadLetter{}
+	mLogger := &mockLogger{}
+	mLocalImage := &mockLocalImage{}
+	mScanner := &mockScanner{}
+	mFile := &mockFile{}
+	layout := "20060102T150405"
+
+	mLocalImage.On("OpenFile", "dead.txt").Return(mFile, nil)
+	mLocalImage.On("InitImageListScanner", mFile).Return(mScanner)
+	mFile.On("Close").Return(nil)
+	lines := []string{
+		"20190102T150405 foo.jpg",
+		"INVALID ELEMENT",
+		"20190102T150405 bar.jpg",
+		"20190102T150405 baz.jpg",
+	}
+	for _, line := range lines {
+		mScanner.On("Scan").Return(true).Once()
+		mScanner.On("Text").Return(line).Once()
+	}
+	mScanner.On("Scan").Return(false).Once()
+	mScanner.On("Err").Return(nil)
+	mDeadLetter.On("Requeue", "foo.jpg").Return(nil)
+	mDeadLetter.On("Requeue", "bar.jpg").Return(fmt.Errorf("err"))
+	mDeadLetter.On("Requeue", "baz.jpg").Return(nil)
+	mLogger.On("LogErrorRequeuingImage", "bar.jpg", fmt.Errorf("err"))
+	mLogger.On("LogDeadLetterRequeued", 2, 2)
+
+	cli := NewCLIYams(nil, nil, nil, mLocalImage, mLogger, time.Now(), NewStats(nil), layout)
+	cli.SetDeadLetter(mDeadLetter)
+	err := cli.ImportDeadLetter("dead.txt")
+	assert.NoError(t, err)
+	mDeadLetter.AssertExpectations(t)
+	mLogger.AssertExpectations(t)
+	mLocalImage.AssertExpectations(t)
+	mScanner.AssertExpectations(t)
+	mFile.AssertExpectations(t)
+}
+
+func TestImportDeadLetterOpenError(t *testing.T) {
+	mLogger := &mockLogger{}
+	mLocalImage := &mockLocalImage{}
+	mLocalImage.On("OpenFile", "dead.txt").Return(&mockFile{}, fmt.Errorf("err"))
+	mLogger.On("LogErrorGettingImagesList", "dead.txt", fmt.Errorf("err"))
+
+	cli := NewCLIYams(nil, nil, nil, mLocalImage, mLogger, time.Now(), NewStats(nil), "")
+	cli.SetDeadLetter(&mockDeadLetter{})
+	err := cli.ImportDeadLetter("dead.txt")
+	assert.Error(t, err)
+	mLocalImage.AssertExpectations(t)
+	mLogger.AssertExpectations(t)
+}
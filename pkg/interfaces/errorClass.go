@@ -0,0 +1,63 @@
+package interfaces
+
+import (
+	"os"
+
+	"github.mpi-internal.com/Yapo/yams-dav-sync/pkg/usecases"
+)
+
+// yamsErrorKind returns the sentinel error of a yams repository error to be
+// compared with the ErrYams errors, any other error is returned as is
+func yamsErrorKind(err error) error {
+	if yamsErr, ok := err.(*usecases.YamsRepositoryError); ok {
+		return yamsErr.Kind()
+	}
+	return err
+}
+
+// errorClass returns the class of an error, used as metric label. Yams
+// repository errors are classified by its cause & any other error is
+// considered a local error reading the image
+func errorClass(err error) string {
+	switch yamsErrorKind(err) {
+	case usecases.ErrYamsDuplicate:
+		return "duplicate"
+	case usecases.ErrYamsInternal:
+		return "internal"
+	case usecases.ErrYamsImage:
+		return "image"
+	case usecases.ErrYamsConnection:
+		return "connection"
+	case usecases.ErrYamsTimeout:
+		return "timeout"
+	case usecases.ErrYamsUnauthorized:
+		return "unauthorized"
+	case usecases.ErrYamsBucketNotFound:
+		return "bucket_not_found"
+	case usecases.ErrYamsObjectNotFound:
+		return "object_not_found"
+	}
+	switch {
+	case os.IsNotExist(err):
+		return "local_not_found"
+	case os.IsPermission(err):
+		return "local_permission"
+	}
+	return "local_read"
+}
+
+// errorStatus returns the http status answered by yams for a yams repository
+// error, zero if there was no answer
+func errorStatus(err error) int {
+	if yamsErr, ok := err.(*usecases.YamsRepositoryError); ok && yamsErr != nil {
+		return yamsErr.StatusCode
+	}
+	return 0
+}
+
+// isRetryableClass returns false for the classes of errors that fail again
+// on every retry, as images missing in local storage. Failures opening or
+// reading an existing image, as too many open files, may pass on a retry
+func isRetryableClass(class string) bool {
+	return class != "local_not_found"
+}
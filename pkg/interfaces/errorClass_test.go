@@ -0,0 +1,63 @@
+package interfaces
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.mpi-internal.com/Yapo/yams-dav-sync/pkg/usecases"
+)
+
+func TestErrorClass(t *testing.T) {
+	testCases := map[string]error{
+		"duplicate":        usecases.ErrYamsDuplicate,
+		"internal":         usecases.ErrYamsInternal.WithStatus(503),
+		"image":            usecases.ErrYamsImage,
+		"connection":       usecases.ErrYamsConnection,
+		"timeout":          usecases.ErrYamsTimeout,
+		"unauthorized":     usecases.ErrYamsUnauthorized,
+		"bucket_not_found": usecases.ErrYamsBucketNotFound,
+		"object_not_found": usecases.ErrYamsObjectNotFound,
+		"local_not_found":  &os.PathError{Op: "open", Path: "foo.jpg", Err: os.ErrNotExist},
+		"local_permission": &os.PathError{Op: "open", Path: "foo.jpg", Err: os.ErrPermission},
+		"local_read":       fmt.Errorf("ImagePath too short: f"),
+	}
+	for expected, err := range testCases {
+		assert.Equal(t, expected, errorClass(err))
+	}
+}
+
+func TestErrorStatus(t *testing.T) {
+	testCases := map[int]error{
+		409: usecases.ErrYamsDuplicate.WithStatus(409),
+		400: usecases.ErrYamsInternal.WithStatus(400),
+		503: usecases.ErrYamsInternal.WithStatus(503),
+		403: usecases.ErrYamsUnauthorized.WithStatus(403),
+		0:   usecases.ErrYamsTimeout,
+	}
+	for expected, err := range testCases {
+		assert.Equal(t, expected, errorStatus(err))
+	}
+	assert.Equal(t, 0, errorStatus(fmt.Errorf("err")))
+}
+
+func TestNewErrorDetail(t *testing.T) {
+	assert.Equal(t, ErrorDetail{
+		Class:      "bucket_not_found",
+		HTTPStatus: 404,
+		Message:    "bucket not found",
+		Retryable:  true,
+	}, newErrorDetail(usecases.ErrYamsBucketNotFound.WithStatus(404)))
+	notFound := &os.PathError{Op: "open", Path: "foo.jpg", Err: os.ErrNotExist}
+	detail := newErrorDetail(notFound)
+	assert.Equal(t, "local_not_found", detail.Class)
+	assert.False(t, detail.Retryable)
+	// transient failures opening the image are retried
+	assert.True(t, newErrorDetail(usecases.ErrYamsImage).Retryable)
+	tooManyFiles := &os.PathError{Op: "open", Path: "foo.jpg", Err: syscall.EMFILE}
+	detail = newErrorDetail(tooManyFiles)
+	assert.Equal(t, "local_read", detail.Class)
+	assert.True(t, detail.Retryable)
+}
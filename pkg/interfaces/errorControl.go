@@ -0,0 +1,143 @@
+package interfaces
+
+import (
+	"errors"
+	"path"
+	"time"
+)
+
+// ErrErrorMarkNotFound is returned when the image has no error mark
+var ErrErrorMarkNotFound = errors.New("the image has no error mark")
+
+// SyncError is the error mark of an image that failed to be synchronized,
+// with the detail of its last failure
+type SyncError struct {
+	ImagePath      string    `json:"image_path"`
+	ImageName      string    `json:"image_name"`
+	ErrorCounter   int       `json:"error_counter"`
+	LastErrorClass string    `json:"last_error_class"`
+	LastHTTPStatus int       `json:"last_http_status"`
+	LastMessage    string    `json:"last_error_message"`
+	FirstFailureAt time.Time `json:"first_failure_at"`
+	LastFailureAt  time.Time `json:"last_failure_at"`
+	Attempts       int       `json:"attempts"`
+	Retryable      bool      `json:"retryable"`
+	NextRetryAt    time.Time `json:"next_retry_at"`
+}
+
+// ErrorDetail is the cause of a synchronization failure
+type ErrorDetail struct {
+	Class      string
+	HTTPStatus int
+	Message    string
+	Retryable  bool
+}
+
+// FailedErrorMark is an error mark write that could not be stored. Operation
+// is the failed write: clean_error_marks, set_error_counter or
+// increase_error_counter
+type FailedErrorMark struct {
+	ImagePath string
+	Operation string
+	Err       error
+}
+
+// ErrorMarksFlusher is implemented by the error control repositories
+// buffering their writes
+type ErrorMarksFlusher interface {
+	// FlushErrorMarks applies the pending writes, returning the writes failed
+	// since the previous call
+	FlushErrorMarks() []FailedErrorMark
+}
+
+// flushErrorMarks applies the buffered error marks writes, the failed ones
+// are counted like the writes failed while synchronizing
+func (cli *CLIYams) flushErrorMarks() {
+	flusher, ok := cli.errorControl.(ErrorMarksFlusher)
+	if !ok {
+		return
+	}
+	for _, failed := range flusher.FlushErrorMarks() {
+		switch failed.Operation {
+		case "clean_error_marks":
+			cli.logger.LogErrorCleaningMarks(failed.ImagePath, failed.Err)
+		case "set_error_counter":
+			// the image was deleted from yams & it is not marked to be
+			// uploaded again
+			cli.logger.LogErrorResetingErrorCounter(failed.ImagePath, failed.Err)
+			cli.countDBError(failed.Operation)
+			cli.addFailed(path.Base(failed.ImagePath))
+		default:
+			cli.logger.LogErrorIncreasingErrorCounter(failed.ImagePath, failed.Err)
+			cli.countDBError(failed.Operation)
+		}
+	}
+}
+
+// newErrorDetail describes err by its class, the http status answered by
+// yams & if the image should be retried
+func newErrorDetail(err error) ErrorDetail {
+	class := errorClass(err)
+	return ErrorDetail{
+		Class:      class,
+		HTTPStatus: errorStatus(err),
+		Message:    err.Error(),
+		Retryable:  isRetryableClass(class),
+	}
+}
+
+// ListErrors gets a page of error marks with counter equal or over minCounter
+func (cli *CLIYams) ListErrors(page, minCounter int) error {
+	if page < 1 {
+		page = 1
+	}
+	list, pages, err := cli.errorControl.ListErrors(page, minCounter)
+	if err != nil {
+		return err
+	}
+	cli.logger.LogErrorsList(list, page, pages)
+	return nil
+}
+
+// ShowError gets the error mark of an image
+func (cli *CLIYams) ShowError(imagePath string) error {
+	syncError, err := cli.errorControl.GetError(imagePath)
+	if err != nil {
+		return err
+	}
+	cli.logger.LogSyncError(syncError)
+	return nil
+}
+
+// ResetErrors resets the error counter of the image, or every error counter
+// if imagePath is empty, so they are retried in the next sync
+func (cli *CLIYams) ResetErrors(imagePath string) error {
+	count, err := cli.errorControl.ResetErrorCounters(imagePath)
+	if err != nil {
+		return err
+	}
+	cli.logger.LogErrorCountersReset(count)
+	return nil
+}
+
+// PurgeErrors deletes the error marks over maxErrorTolerance, those images
+// are not retried anymore
+func (cli *CLIYams) PurgeErrors(maxErrorTolerance int) error {
+	count, err := cli.errorControl.PurgeErrors(maxErrorTolerance)
+	if err != nil {
+		return err
+	}
+	cli.logger.LogErrorsPurged(count)
+	return nil
+}
+
+// ExportErrors gets the dead set, images over maxErrorTolerance, one per line
+// as in the dump file
+func (cli *CLIYams) ExportErrors(maxErrorTolerance int) error {
+	list, err := cli.errorControl.GetDeadErrors(maxErrorTolerance)
+	if err != nil {
+		return err
+	}
+	cli.logger.LogDeadErrors(list)
+	return nil
+}
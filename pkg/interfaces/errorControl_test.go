@@ -0,0 +1,149 @@
+package interfaces
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestListErrors(t *testing.T) {
+	mErrorControl := &mockErrorControl{}
+	mLogger := &mockLogger{}
+	list := []SyncError{{ImagePath: "foo.jpg", ErrorCounter: 4}}
+	mErrorControl.On("ListErrors", 1, 3).Return(list, 2, nil)
+	mLogger.On("LogErrorsList", list, 1, 2)
+
+	cli := NewCLIYams(nil, mErrorControl, nil, nil, mLogger, time.Now(), NewStats(nil), "")
+	err := cli.ListErrors(0, 3)
+	assert.NoError(t, err)
+	mErrorControl.AssertExpectations(t)
+	mLogger.AssertExpectations(t)
+}
+
+func TestListErrorsError(t *testing.T) {
+	mErrorControl := &mockErrorControl{}
+	mErrorControl.On("ListErrors", 2, 0).Return([]SyncError{}, 0, fmt.Errorf("err"))
+
+	cli := NewCLIYams(nil, mErrorControl, nil, nil, nil, time.Now(), NewStats(nil), "")
+	err := cli.ListErrors(2, 0)
+	assert.Error(t, err)
+	mErrorControl.AssertExpectations(t)
+}
+
+func TestShowError(t *testing.T) {
+	mErrorControl := &mockErrorControl{}
+	mLogger := &mockLogger{}
+	syncError := SyncError{ImagePath: "foo.jpg", ErrorCounter: 4}
+	mErrorControl.On("GetError", "foo.jpg").Return(syncError, nil)
+	mLogger.On("LogSyncError", syncError)
+
+	cli := NewCLIYams(nil, mErrorControl, nil, nil, mLogger, time.Now(), NewStats(nil), "")
+	err := cli.ShowError("foo.jpg")
+	assert.NoError(t, err)
+	mErrorControl.AssertExpectations(t)
+	mLogger.AssertExpectations(t)
+}
+
+func TestShowErrorNotFound(t *testing.T) {
+	mErrorControl := &mockErrorControl{}
+	mErrorControl.On("GetError", "foo.jpg").Return(SyncError{}, ErrErrorMarkNotFound)
+
+	cli := NewCLIYams(nil, mErrorControl, nil, nil, nil, time.Now(), NewStats(nil), "")
+	err := cli.ShowError("foo.jpg")
+	assert.Equal(t, ErrErrorMarkNotFound, err)
+	mErrorControl.AssertExpectations(t)
+}
+
+func TestResetErrors(t *testing.T) {
+	mErrorControl := &mockErrorControl{}
+	mLogger := &mockLogger{}
+	mErrorControl.On("ResetErrorCounters", "").Return(5, nil)
+	mLogger.On("LogErrorCountersReset", 5)
+
+	cli := NewCLIYams(nil, mErrorControl, nil, nil, mLogger, time.Now(), NewStats(nil), "")
+	err := cli.ResetErrors("")
+	assert.NoError(t, err)
+	mErrorControl.AssertExpectations(t)
+	mLogger.AssertExpectations(t)
+}
+
+func TestPurgeErrors(t *testing.T) {
+	mErrorControl := &mockErrorControl{}
+	mLogger := &mockLogger{}
+	mErrorControl.On("PurgeErrors", 3).Return(2, nil)
+	mLogger.On("LogErrorsPurged", 2)
+
+	cli := NewCLIYams(nil, mErrorControl, nil, nil, mLogger, time.Now(), NewStats(nil), "")
+	err := cli.PurgeErrors(3)
+	assert.NoError(t, err)
+	mErrorControl.AssertExpectations(t)
+	mLogger.AssertExpectations(t)
+}
+
+func TestPurgeErrorsError(t *testing.T) {
+	mErrorControl := &mockErrorControl{}
+	mErrorControl.On("PurgeErrors", 3).Return(0, fmt.Errorf("err"))
+
+	cli := NewCLIYams(nil, mErrorControl, nil, nil, nil, time.Now(), NewStats(nil), "")
+	err := cli.PurgeErrors(3)
+	assert.Error(t, err)
+	mErrorControl.AssertExpectations(t)
+}
+
+func TestExportErrors(t *testing.T) {
+	mErrorControl := &mockErrorControl{}
+	mLogger := &mockLogger{}
+	mErrorControl.On("GetDeadErrors", 3).Return([]string{"foo.jpg"}, nil)
+	mLogger.On("LogDeadErrors", []string{"foo.jpg"})
+
+	cli := NewCLIYams(nil, mErrorControl, nil, nil, mLogger, time.Now(), NewStats(nil), "")
+	err := cli.ExportErrors(3)
+	assert.NoError(t, err)
+	mErrorControl.AssertExpectations(t)
+	mLogger.AssertExpectations(t)
+}
+
+type mockErrorMarksFlusher struct {
+	mockErrorControl
+}
+
+func (m *mockErrorMarksFlusher) FlushErrorMarks() []FailedErrorMark {
+	args := m.Called()
+	return args.Get(0).([]FailedErrorMark)
+}
+
+func TestCloseCountsFailedErrorMarks(t *testing.T) {
+	mErrorControl := &mockErrorMarksFlusher{}
+	mLastSync := &mockLastSync{}
+	mLogger := &mockLogger{}
+	mMetricsExposer := &mockMetricsExposer{}
+	err := fmt.Errorf("err")
+	mErrorControl.On("FlushErrorMarks").Return([]FailedErrorMark{
+		{ImagePath: "a/a.jpg", Operation: "clean_error_marks", Err: err},
+		{ImagePath: "b/b.jpg", Operation: "set_error_counter", Err: err},
+		{ImagePath: "c/c.jpg", Operation: "increase_error_counter", Err: err},
+	}).Once()
+	// the mark isn't moved
+	mLastSync.On("GetLastSynchronizationMark").Return(time.Now().Add(time.Hour))
+	mLogger.On("LogErrorCleaningMarks", "a/a.jpg", err).Once()
+	mLogger.On("LogErrorResetingErrorCounter", "b/b.jpg", err).Once()
+	mLogger.On("LogErrorIncreasingErrorCounter", "c/c.jpg", err).Once()
+	mMetricsExposer.On("IncrementCounterWithLabel", mock.Anything, "set_error_counter").Once()
+	mMetricsExposer.On("IncrementCounterWithLabel", mock.Anything, "increase_error_counter").Once()
+
+	cli := NewCLIYams(nil, mErrorControl, mLastSync, nil, mLogger, time.Now(), NewStats(mMetricsExposer), "")
+	cli.StartRun("sync", nil)
+	cli.SetRunResult(nil)
+	cli.isSync = true
+	assert.NoError(t, cli.Close())
+	// lost marks are counted like the unbatched ones, making the run partial
+	assert.Equal(t, 2, cli.stats.Snapshot()["db_errors"])
+	assert.Equal(t, ExitPartial, cli.ExitCode())
+	assert.Equal(t, []string{"b.jpg"}, <-cli.failedNames)
+	mErrorControl.AssertExpectations(t)
+	mLogger.AssertExpectations(t)
+	mMetricsExposer.AssertExpectations(t)
+}
@@ -1,7 +1,9 @@
 package loggers
 
 import (
+	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.mpi-internal.com/Yapo/yams-dav-sync/pkg/interfaces"
@@ -57,6 +59,64 @@ func (l *cliYamsLogger) LogErrorSettingSyncMark(mark time.Time, err error) {
 	l.logger.Error("Error setting synchronization mark %+v error: %+v", mark, err)
 }
 
+func (l *cliYamsLogger) LogQuarantinedImage(imgName string, reason error) {
+	l.logger.Warn("Image %+v moved to quarantine, reason: %+v", imgName, reason)
+}
+
+func (l *cliYamsLogger) LogErrorQuarantiningImage(imgName string, err error) {
+	l.logger.Error("Error moving image %+v to quarantine, error: %+v", imgName, err)
+}
+
+func (l *cliYamsLogger) LogSyncPaused(resumeAt time.Time) {
+	if resumeAt.IsZero() {
+		l.logger.Info("Synchronization paused until resumed")
+		return
+	}
+	l.logger.Info("Synchronization paused out of allowed windows, resuming at %+v", resumeAt)
+}
+
+func (l *cliYamsLogger) LogSyncResumed() {
+	l.logger.Info("Synchronization resumed")
+}
+
+func (l *cliYamsLogger) LogWaitingForRunLock(holder interfaces.LockHolder) {
+	l.logger.Info("Waiting for the run lock held by %s (pid %d on %s)",
+		holder.Command, holder.PID, holder.Host)
+}
+
+func (l *cliYamsLogger) LogErrorReleasingRunLock(err error) {
+	l.logger.Error("Error releasing the run lock: %+v", err)
+}
+
+// LogRunLock logs the run holding the run lock
+func (l *cliYamsLogger) LogRunLock(holder interfaces.LockHolder, held bool) {
+	if !held {
+		fmt.Println("Run lock is free")
+		return
+	}
+	stale := ""
+	if holder.Stale {
+		stale = " (stale)"
+	}
+	fmt.Printf("Run lock of profile %s held by %s, pid %d on %s since %s, last heartbeat %s%s\n",
+		holder.Profile,
+		holder.Command,
+		holder.PID,
+		holder.Host,
+		holder.AcquiredAt.Format(time.RFC3339),
+		holder.HeartbeatAt.Format(time.RFC3339),
+		stale,
+	)
+}
+
+func (l *cliYamsLogger) LogRunLockBroken(broken bool) {
+	if broken {
+		l.logger.Info("Run lock broken")
+		return
+	}
+	l.logger.Info("Run lock was free")
+}
+
 func (l *cliYamsLogger) LogRetryPreviousFailedUploads() {
 	l.logger.Info("Retrying to upload previous failed uploads...")
 }
@@ -69,6 +129,14 @@ func (l *cliYamsLogger) LogUploadingNewImages() {
 	l.logger.Info("Uploading new images to yams...")
 }
 
+func (l *cliYamsLogger) LogProgress(progress interfaces.Progress) {
+	l.logger.Info("progress processed=%d total=%d sent=%d errors=%d elapsed=%.0fs "+
+		"images_per_sec=%.2f mb_per_sec=%.2f eta=%q in_progress=%q",
+		progress.Processed, progress.Total, progress.Sent, progress.Errors,
+		progress.ElapsedSeconds, progress.ImagesPerSecond, progress.MBPerSecond,
+		progress.ETA, strings.Join(progress.InProgress, ","))
+}
+
 func (l *cliYamsLogger) LogStats(timer int, stats *interfaces.Stats) {
 	sent := <-stats.Sent
 	errors := <-stats.Errors
@@ -77,6 +145,8 @@ func (l *cliYamsLogger) LogStats(timer int, stats *interfaces.Stats) {
 	skipped := <-stats.Skipped
 	notFound := <-stats.NotFound
 	recovered := <-stats.Recovered
+	quarantined := <-stats.Quarantined
+	dbErrors := <-stats.DBErrors
 
 	stats.Sent <- sent
 	stats.Errors <- errors
@@ -85,6 +155,8 @@ func (l *cliYamsLogger) LogStats(timer int, stats *interfaces.Stats) {
 	stats.Skipped <- skipped
 	stats.NotFound <- notFound
 	stats.Recovered <- recovered
+	stats.Quarantined <- quarantined
+	stats.DBErrors <- dbErrors
 
 	fmt.Printf("\r[ Timer: %ds ] ( \033[32mSent images: %d \033[0m "+
 		"\033[31m Errors: %d \033[0m "+
@@ -92,9 +164,11 @@ func (l *cliYamsLogger) LogStats(timer int, stats *interfaces.Stats) {
 		"\033[33m Processed: %d \033[0m "+
 		"\033[33m Skipped: %d \033[0m "+
 		"\033[33m Not Found: %d \033[0m "+
-		"\033[33m Recovered: %d \033[0m ) ",
+		"\033[33m Recovered: %d \033[0m "+
+		"\033[31m Quarantined: %d \033[0m "+
+		"\033[31m DB Errors: %d \033[0m ) ",
 		timer, sent, errors, duplicated, processed,
-		skipped, notFound, recovered)
+		skipped, notFound, recovered, quarantined, dbErrors)
 }
 
 // LogMarksList logs a list of marks
@@ -103,3 +177,135 @@ func (l *cliYamsLogger) LogMarksList(list []string) {
 		fmt.Printf("%d) %+v\n", len(list)-i, element)
 	}
 }
+
+// LogQuarantineList logs the list of quarantined images
+func (l *cliYamsLogger) LogQuarantineList(list []string) {
+	for i, element := range list {
+		fmt.Printf("%d) %+v\n", i+1, element)
+	}
+}
+
+// LogRunHistory logs the list of runs ordered by newer to older
+func (l *cliYamsLogger) LogRunHistory(runs []interfaces.SyncRun) {
+	for i, run := range runs {
+		fmt.Printf("%d) %s %s took %s, %s\n", len(runs)-i,
+			run.StartedAt.Format(time.RFC3339),
+			run.Command,
+			run.Duration().Truncate(time.Second),
+			run.ExitReason,
+		)
+		fmt.Printf("\tSent: %d Errors: %d Duplicated: %d Processed: %d "+
+			"Skipped: %d Not Found: %d Recovered: %d Quarantined: %d "+
+			"DB Errors: %d\n",
+			run.Stats["sent"], run.Stats["errors"], run.Stats["duplicated"],
+			run.Stats["processed"], run.Stats["skipped"], run.Stats["not_found"],
+			run.Stats["recovered"], run.Stats["quarantined"], run.Stats["db_errors"],
+		)
+		fmt.Printf("\tMark: %s -> %s Flags: %v\n",
+			run.MarkBefore.Format(time.RFC3339),
+			run.MarkAfter.Format(time.RFC3339),
+			run.Flags,
+		)
+	}
+}
+
+// LogRunHistoryJSON logs the list of runs as json
+func (l *cliYamsLogger) LogRunHistoryJSON(runs []interfaces.SyncRun) {
+	data, err := json.MarshalIndent(runs, "", "  ")
+	if err != nil {
+		l.logger.Error("Error encoding run history: %+v", err)
+		return
+	}
+	fmt.Println(string(data))
+}
+
+func (l *cliYamsLogger) LogErrorSavingRun(command string, err error) {
+	l.logger.Error("Error saving %s run in history: %+v", command, err)
+}
+
+func (l *cliYamsLogger) LogErrorWritingSummary(err error) {
+	l.logger.Error("Error writing run summary: %+v", err)
+}
+
+// LogErrorsList logs a page of error marks
+func (l *cliYamsLogger) LogErrorsList(list []interfaces.SyncError, page, pages int) {
+	for _, syncError := range list {
+		fmt.Printf("%s %d %s %d %s\n",
+			syncError.ImagePath,
+			syncError.ErrorCounter,
+			syncError.LastErrorClass,
+			syncError.LastHTTPStatus,
+			syncError.LastFailureAt.Format(time.RFC3339),
+		)
+	}
+	fmt.Printf("Page %d of %d\n", page, pages)
+}
+
+// LogSyncError logs the error mark of an image
+func (l *cliYamsLogger) LogSyncError(syncError interfaces.SyncError) {
+	fmt.Printf("Image: %s\n"+
+		"Name: %s\n"+
+		"Error counter: %d\n"+
+		"Attempts: %d\n"+
+		"Last error: %s (http status %d) %s\n"+
+		"First failure: %s\n"+
+		"Last failure: %s\n"+
+		"Retryable: %t\n"+
+		"Next retry: %s\n",
+		syncError.ImagePath,
+		syncError.ImageName,
+		syncError.ErrorCounter,
+		syncError.Attempts,
+		syncError.LastErrorClass,
+		syncError.LastHTTPStatus,
+		syncError.LastMessage,
+		syncError.FirstFailureAt.Format(time.RFC3339),
+		syncError.LastFailureAt.Format(time.RFC3339),
+		syncError.Retryable,
+		syncError.NextRetryAt.Format(time.RFC3339),
+	)
+}
+
+func (l *cliYamsLogger) LogErrorCountersReset(count int) {
+	l.logger.Info("%d error counters reset", count)
+}
+
+func (l *cliYamsLogger) LogErrorsPurged(count int) {
+	l.logger.Info("%d error marks purged", count)
+}
+
+// LogDeadErrors logs the images not retried anymore, one per line
+func (l *cliYamsLogger) LogDeadErrors(list []string) {
+	for _, imagePath := range list {
+		fmt.Println(imagePath)
+	}
+}
+
+func (l *cliYamsLogger) LogMovedToDeadLetter(count int) {
+	l.logger.Info("%d images moved to the dead letter", count)
+}
+
+func (l *cliYamsLogger) LogErrorMovingToDeadLetter(err error) {
+	l.logger.Error("Error moving images to the dead letter: %+v", err)
+}
+
+// LogDeadLetterList logs the images in the dead letter with its reason
+func (l *cliYamsLogger) LogDeadLetterList(list []interfaces.DeadImage) {
+	for i, image := range list {
+		fmt.Printf("%d) %s %s %s (%d errors, last failure %s)\n", i+1,
+			image.DeadAt.Format(time.RFC3339),
+			image.ImagePath,
+			image.Reason,
+			image.ErrorCounter,
+			image.LastFailureAt.Format(time.RFC3339),
+		)
+	}
+}
+
+func (l *cliYamsLogger) LogErrorRequeuingImage(imgName string, err error) {
+	l.logger.Error("Error requeuing image %s: %+v", imgName, err)
+}
+
+func (l *cliYamsLogger) LogDeadLetterRequeued(requeued, skipped int) {
+	l.logger.Info("%d images requeued from the dead letter, %d lines skipped", requeued, skipped)
+}
@@ -0,0 +1,36 @@
+package loggers
+
+import (
+	"github.mpi-internal.com/Yapo/yams-dav-sync/pkg/interfaces"
+)
+
+type daemonLogger struct {
+	logger Logger
+}
+
+func (l *daemonLogger) LogJobStarted(job string) {
+	l.logger.Info("Daemon: %s job started", job)
+}
+
+func (l *daemonLogger) LogJobFinished(job string, err error) {
+	if err != nil {
+		l.logger.Error("Daemon: %s job finished with error: %+v", job, err)
+		return
+	}
+	l.logger.Info("Daemon: %s job finished", job)
+}
+
+func (l *daemonLogger) LogJobCancelled(job string) {
+	l.logger.Warn("Daemon: %s job cancelled", job)
+}
+
+func (l *daemonLogger) LogErrorPreparingJob(job string, err error) {
+	l.logger.Error("Daemon: error preparing %s job: %+v", job, err)
+}
+
+// MakeDaemonLogger sets up a daemonLogger instrumented via the provided logger
+func MakeDaemonLogger(logger Logger) interfaces.DaemonLogger {
+	return &daemonLogger{
+		logger: logger,
+	}
+}
@@ -24,6 +24,10 @@ func (l *yamsRepoLogger) LogCannotDecodeErrorMessage(err error) {
 	l.logger.Error("> Error: Can not decode Yams error message: %+v", err)
 }
 
+func (l *yamsRepoLogger) LogAccessKeySwitch(from, to string) {
+	l.logger.Warn("> Access key %s was rejected by yams, switching to access key %s", from, to)
+}
+
 // MakeYamsRepoLogger sets up a SyncLogger instrumented via the provided logger
 func MakeYamsRepoLogger(logger Logger) repository.YamsRepositoryLogger {
 	return &yamsRepoLogger{
@@ -0,0 +1,48 @@
+package interfaces
+
+import (
+	"time"
+)
+
+// bytesPerMB is the number of bytes in a megabyte
+const bytesPerMB = 1024 * 1024
+
+// Progress is the progress of a synchronization
+type Progress struct {
+	Processed       int      `json:"processed"`
+	Total           int      `json:"total"`
+	Sent            int      `json:"sent"`
+	Errors          int      `json:"errors"`
+	ElapsedSeconds  float64  `json:"elapsed_seconds"`
+	ImagesPerSecond float64  `json:"images_per_second"`
+	MBPerSecond     float64  `json:"mb_per_second"`
+	ETA             string   `json:"eta,omitempty"`
+	InProgress      []string `json:"in_progress"`
+}
+
+// newProgress calculates throughput & ETA of a synchronization, ETA is empty
+// if total is unknown or nothing was processed yet
+func newProgress(processed, total, sent, errors int, sentBytes int64, elapsed time.Duration, inProgress []string) Progress {
+	progress := Progress{
+		Processed:      processed,
+		Total:          total,
+		Sent:           sent,
+		Errors:         errors,
+		ElapsedSeconds: elapsed.Seconds(),
+		InProgress:     inProgress,
+	}
+	if elapsed <= 0 {
+		return progress
+	}
+	progress.ImagesPerSecond = float64(processed) / elapsed.Seconds()
+	progress.MBPerSecond = float64(sentBytes) / bytesPerMB / elapsed.Seconds()
+	if total > 0 && processed > 0 {
+		remaining := total - processed
+		if remaining < 0 {
+			remaining = 0
+		}
+		eta := time.Duration(float64(remaining) / progress.ImagesPerSecond * float64(time.Second))
+		progress.ETA = eta.Truncate(time.Second).String()
+	}
+	return progress
+}
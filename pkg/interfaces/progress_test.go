@@ -0,0 +1,84 @@
+package interfaces
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestNewProgress(t *testing.T) {
+	progress := newProgress(20, 100, 18, 2, 4*bytesPerMB, 10*time.Second, []string{"foo.jpg"})
+	assert.Equal(t, Progress{
+		Processed:       20,
+		Total:           100,
+		Sent:            18,
+		Errors:          2,
+		ElapsedSeconds:  10,
+		ImagesPerSecond: 2,
+		MBPerSecond:     0.4,
+		ETA:             "40s",
+		InProgress:      []string{"foo.jpg"},
+	}, progress)
+}
+
+func TestNewProgressWithoutTotal(t *testing.T) {
+	progress := newProgress(20, 0, 20, 0, 0, 10*time.Second, []string{})
+	assert.Equal(t, 2.0, progress.ImagesPerSecond)
+	assert.Empty(t, progress.ETA)
+
+	progress = newProgress(0, 100, 0, 0, 0, 0, []string{})
+	assert.Zero(t, progress.ImagesPerSecond)
+	assert.Empty(t, progress.ETA)
+}
+
+func TestCLIYamsProgress(t *testing.T) {
+	mMetricsExposer := &mockMetricsExposer{}
+	layout := "20060102T150405"
+	cli := NewCLIYams(nil, nil, nil, nil, &mockLogger{}, time.Now(), NewStats(mMetricsExposer), layout)
+	cli.SetProgress(10, 0)
+	<-cli.startedAt
+	cli.startedAt <- time.Now().Add(-2 * time.Second)
+	<-cli.stats.Processed
+	cli.stats.Processed <- 4
+	<-cli.stats.Sent
+	cli.stats.Sent <- 3
+	<-cli.sentBytes
+	cli.sentBytes <- 2 * bytesPerMB
+	cli.inProgressNames <- append(<-cli.inProgressNames, "foo.jpg", "bar.jpg")
+	cli.inProgressNames <- removeName("foo.jpg", <-cli.inProgressNames)
+
+	progress := cli.Progress()
+	assert.Equal(t, 4, progress.Processed)
+	assert.Equal(t, 10, progress.Total)
+	assert.Equal(t, 3, progress.Sent)
+	assert.InDelta(t, 2, progress.ImagesPerSecond, 0.1)
+	assert.InDelta(t, 1, progress.MBPerSecond, 0.1)
+	assert.NotEmpty(t, progress.ETA)
+	assert.Equal(t, []string{"bar.jpg"}, progress.InProgress)
+}
+
+func TestShowStatsLogsProgress(t *testing.T) {
+	mMetricsExposer := &mockMetricsExposer{}
+	mLogger := &mockLogger{}
+	layout := "20060102T150405"
+	logged := make(chan bool, 1)
+	mLogger.On("LogStats", mock.AnythingOfType("int"), mock.AnythingOfType("*interfaces.Stats"))
+	mLogger.On("LogProgress", mock.AnythingOfType("interfaces.Progress")).Run(func(args mock.Arguments) {
+		select {
+		case logged <- true:
+		default:
+		}
+	})
+	cli := NewCLIYams(nil, nil, nil, nil, mLogger, time.Now(), NewStats(mMetricsExposer), layout)
+	cli.SetProgress(10, 1)
+	cli.showStats()
+	select {
+	case <-logged:
+	case <-time.After(3 * time.Second):
+		t.Fatal("progress was not logged")
+	}
+	close(cli.stop)
+	mLogger.AssertExpectations(t)
+}
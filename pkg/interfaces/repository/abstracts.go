@@ -1,6 +1,7 @@
 package repository
 
 import (
+	"errors"
 	"io"
 	"net/http"
 	"time"
@@ -9,14 +10,29 @@ import (
 	"github.mpi-internal.com/Yapo/yams-dav-sync/pkg/usecases"
 )
 
+// DbExecutor executes statements in the database
+type DbExecutor interface {
+	Insert(statement string, params ...interface{}) error
+	Update(statement string, params ...interface{}) error
+	Query(statement string, params ...interface{}) (DbResult, error)
+}
+
 // DbHandler represents a database connection handler
 // it provides basic database capabilities
 // after its use, the connection with the database must be closed
 type DbHandler interface {
 	io.Closer
-	Insert(statement string, params ...interface{}) error
-	Update(statement string, params ...interface{}) error
-	Query(statement string, params ...interface{}) (DbResult, error)
+	DbExecutor
+	// Begin starts a new transaction
+	Begin() (DbTx, error)
+}
+
+// DbTx represents a database transaction, its statements are applied
+// together on Commit or discarded on Rollback
+type DbTx interface {
+	DbExecutor
+	Commit() error
+	Rollback() error
 }
 
 // DbRepo contains an instance of a DBHandler
@@ -57,6 +73,11 @@ type HTTPHandler interface {
 	NewRequest() HTTPRequest
 }
 
+// ErrRequestRejected is returned by HTTPHandler implementations when the
+// request is not sent because yams is considered unavailable, e.g. while a
+// circuit breaker is open
+var ErrRequestRejected = errors.New("request rejected, yams is unavailable")
+
 // HTTPRepository struct that contains httpHandler and Path to connect with
 // external repositories
 type HTTPRepository struct {
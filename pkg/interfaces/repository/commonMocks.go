@@ -115,6 +115,40 @@ func (m *mockDbHandler) Update(statement string, params ...interface{}) error {
 	return args.Error(0)
 }
 
+func (m *mockDbHandler) Begin() (DbTx, error) {
+	args := m.Called()
+	return args.Get(0).(DbTx), args.Error(1)
+}
+
+type mockDbTx struct { // nolint: deadcode
+	mock.Mock
+}
+
+func (m *mockDbTx) Query(statement string, params ...interface{}) (DbResult, error) {
+	args := m.Called(statement, params)
+	return args.Get(0).(DbResult), args.Error(1)
+}
+
+func (m *mockDbTx) Insert(statement string, params ...interface{}) error {
+	args := m.Called(statement, params)
+	return args.Error(0)
+}
+
+func (m *mockDbTx) Update(statement string, params ...interface{}) error {
+	args := m.Called(statement, params)
+	return args.Error(0)
+}
+
+func (m *mockDbTx) Commit() error {
+	args := m.Called()
+	return args.Error(0)
+}
+
+func (m *mockDbTx) Rollback() error {
+	args := m.Called()
+	return args.Error(0)
+}
+
 type mockResult struct { // nolint: deadcode
 	mock.Mock
 }
@@ -0,0 +1,137 @@
+package repository
+
+import (
+	"fmt"
+	"path"
+
+	"github.mpi-internal.com/Yapo/yams-dav-sync/pkg/interfaces"
+)
+
+// deadLetterRepo repository to store images that are not retried anymore
+type deadLetterRepo struct {
+	db DbHandler
+}
+
+// NewDeadLetterRepo creates a new instance of DeadLetter repository
+func NewDeadLetterRepo(dbHandler DbHandler) interfaces.DeadLetter {
+	return &deadLetterRepo{
+		db: dbHandler,
+	}
+}
+
+// Move moves the error marks with counter over maxErrorTolerance or parked
+// to the dead letter with the reason of its last failure in a transaction,
+// returning the number of images moved
+func (repo *deadLetterRepo) Move(maxErrorTolerance int) (moved int, err error) {
+	tx, err := repo.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	err = tx.Insert(`
+		INSERT INTO
+			sync_dead_letter(image_path, reason, error_counter,
+				last_error_class, last_http_status, last_failure_at)
+		SELECT
+			image_path,
+			CASE WHEN retryable
+				THEN 'retries exhausted: '
+				ELSE 'not retryable: '
+			END || last_error_message,
+			error_counter, last_error_class, last_http_status, last_failure_at
+		FROM sync_error
+		WHERE error_counter > $1 OR NOT retryable
+		ON CONFLICT (image_path)
+			DO UPDATE SET
+			reason = EXCLUDED.reason,
+			error_counter = EXCLUDED.error_counter,
+			last_error_class = EXCLUDED.last_error_class,
+			last_http_status = EXCLUDED.last_http_status,
+			last_failure_at = EXCLUDED.last_failure_at,
+			dead_at = CURRENT_TIMESTAMP`,
+		maxErrorTolerance,
+	)
+	if err == nil {
+		// only the error marks already copied are deleted
+		moved, err = countRows(tx, `
+			DELETE
+			FROM sync_error
+			WHERE (error_counter > $1 OR NOT retryable)
+				AND image_path IN (SELECT image_path FROM sync_dead_letter)
+			RETURNING image_path`,
+			maxErrorTolerance,
+		)
+	}
+	if err != nil {
+		tx.Rollback() // nolint
+		return 0, err
+	}
+	return moved, tx.Commit()
+}
+
+// List gets the images in the dead letter ordered by older to newer
+func (repo *deadLetterRepo) List() (list []interfaces.DeadImage, err error) {
+	result, err := repo.db.Query(`
+		SELECT image_path, reason, error_counter, last_error_class,
+			last_http_status, last_failure_at, dead_at
+		FROM sync_dead_letter
+		ORDER BY sync_dead_letter_id`)
+	if err != nil {
+		return []interfaces.DeadImage{}, err
+	}
+	defer result.Close() // nolint
+	for result.Next() {
+		var image interfaces.DeadImage
+		err := result.Scan(
+			&image.ImagePath,
+			&image.Reason,
+			&image.ErrorCounter,
+			&image.LastErrorClass,
+			&image.LastHTTPStatus,
+			&image.LastFailureAt,
+			&image.DeadAt,
+		)
+		if err != nil {
+			return []interfaces.DeadImage{}, err
+		}
+		list = append(list, image)
+	}
+	return
+}
+
+// Requeue takes the image out of the dead letter creating a new error mark,
+// or resetting the existing one, so it is retried in the next sync
+func (repo *deadLetterRepo) Requeue(imagePath string) (err error) {
+	tx, err := repo.db.Begin()
+	if err == nil {
+		err = tx.Update(`
+			DELETE
+			FROM sync_dead_letter
+			WHERE image_path = $1`,
+			imagePath,
+		)
+		if err == nil {
+			err = tx.Insert(`
+				INSERT INTO
+					sync_error(image_path, image_name, error_counter)
+				VALUES
+					($1, $2, 0)
+				ON CONFLICT (image_path)
+					DO UPDATE SET
+					error_counter = 0,
+					retryable = TRUE,
+					next_retry_at = CURRENT_TIMESTAMP`,
+				imagePath,
+				path.Base(imagePath),
+			)
+		}
+		if err == nil {
+			err = tx.Commit()
+		} else {
+			tx.Rollback() // nolint
+		}
+	}
+	if err != nil {
+		err = fmt.Errorf("There was an error requeuing image: %+v", err)
+	}
+	return
+}
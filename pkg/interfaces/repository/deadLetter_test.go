@@ -0,0 +1,151 @@
+package repository
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.mpi-internal.com/Yapo/yams-dav-sync/pkg/interfaces"
+)
+
+func TestNewDeadLetterRepo(t *testing.T) {
+	var dbHandler DbHandler
+	expected := &deadLetterRepo{
+		db: dbHandler,
+	}
+	result := NewDeadLetterRepo(dbHandler)
+	assert.Equal(t, expected, result)
+}
+
+func TestDeadLetterMove(t *testing.T) {
+	mDbHandler := &mockDbHandler{}
+	mTx := &mockDbTx{}
+	mResult := &mockResult{}
+	repo := &deadLetterRepo{
+		db: mDbHandler,
+	}
+	mDbHandler.On("Begin").Return(mTx, nil)
+	mTx.On("Insert", mock.AnythingOfType("string"), []interface{}{3}).Return(nil)
+	mTx.On("Query", mock.AnythingOfType("string"), []interface{}{3}).Return(mResult, nil)
+	mTx.On("Commit").Return(nil)
+	mResult.On("Close").Return(nil)
+	mResult.On("Next").Return(true).Twice()
+	mResult.On("Next").Return(false).Once()
+
+	count, err := repo.Move(3)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+	mDbHandler.AssertExpectations(t)
+	mTx.AssertExpectations(t)
+	mResult.AssertExpectations(t)
+}
+
+func TestDeadLetterMoveError(t *testing.T) {
+	mDbHandler := &mockDbHandler{}
+	mTx := &mockDbTx{}
+	repo := &deadLetterRepo{
+		db: mDbHandler,
+	}
+	mDbHandler.On("Begin").Return(mTx, nil)
+	mTx.On("Insert", mock.AnythingOfType("string"),
+		mock.AnythingOfType("[]interface {}")).Return(nil)
+	mTx.On("Query", mock.AnythingOfType("string"),
+		mock.AnythingOfType("[]interface {}")).Return(&mockResult{}, fmt.Errorf("err"))
+	mTx.On("Rollback").Return(nil)
+
+	count, err := repo.Move(3)
+	assert.Error(t, err)
+	assert.Equal(t, 0, count)
+	mDbHandler.AssertExpectations(t)
+	mTx.AssertExpectations(t)
+}
+
+func TestDeadLetterMoveBeginError(t *testing.T) {
+	mDbHandler := &mockDbHandler{}
+	repo := &deadLetterRepo{
+		db: mDbHandler,
+	}
+	mDbHandler.On("Begin").Return(&mockDbTx{}, fmt.Errorf("err"))
+
+	count, err := repo.Move(3)
+	assert.Error(t, err)
+	assert.Equal(t, 0, count)
+	mDbHandler.AssertExpectations(t)
+}
+
+func TestDeadLetterList(t *testing.T) {
+	mDbHandler := &mockDbHandler{}
+	mResult := &mockResult{}
+	repo := &deadLetterRepo{
+		db: mDbHandler,
+	}
+	mDbHandler.On("Query", mock.AnythingOfType("string"), []interface{}(nil)).Return(mResult, nil)
+	mResult.On("Close").Return(nil)
+	mResult.On("Next").Return(true).Once()
+	mResult.On("Next").Return(false).Once()
+	mResult.On("Scan").Return(nil)
+
+	result, err := repo.List()
+	assert.Equal(t, []interfaces.DeadImage{{}}, result)
+	assert.NoError(t, err)
+	mDbHandler.AssertExpectations(t)
+	mResult.AssertExpectations(t)
+}
+
+func TestDeadLetterListError(t *testing.T) {
+	mDbHandler := &mockDbHandler{}
+	mResult := &mockResult{}
+	repo := &deadLetterRepo{
+		db: mDbHandler,
+	}
+	mDbHandler.On("Query", mock.AnythingOfType("string"), []interface{}(nil)).Return(mResult, nil)
+	mResult.On("Close").Return(nil)
+	mResult.On("Next").Return(true).Once()
+	mResult.On("Scan").Return(fmt.Errorf("err"))
+
+	result, err := repo.List()
+	assert.Equal(t, []interfaces.DeadImage{}, result)
+	assert.Error(t, err)
+	mDbHandler.AssertExpectations(t)
+	mResult.AssertExpectations(t)
+}
+
+func TestDeadLetterRequeue(t *testing.T) {
+	mDbHandler := &mockDbHandler{}
+	mTx := &mockDbTx{}
+	repo := &deadLetterRepo{
+		db: mDbHandler,
+	}
+	mDbHandler.On("Begin").Return(mTx, nil)
+	mTx.On("Update", mock.AnythingOfType("string"),
+		[]interface{}{"a/foo.jpg"}).Return(nil)
+	mTx.On("Insert", mock.AnythingOfType("string"),
+		[]interface{}{"a/foo.jpg", "foo.jpg"}).Return(nil)
+	mTx.On("Commit").Return(nil)
+
+	err := repo.Requeue("a/foo.jpg")
+	assert.NoError(t, err)
+	mDbHandler.AssertExpectations(t)
+	mTx.AssertExpectations(t)
+}
+
+func TestDeadLetterRequeueError(t *testing.T) {
+	mDbHandler := &mockDbHandler{}
+	mTx := &mockDbTx{}
+	repo := &deadLetterRepo{
+		db: mDbHandler,
+	}
+	mDbHandler.On("Begin").Return(mTx, nil)
+	mTx.On("Update", mock.AnythingOfType("string"),
+		mock.AnythingOfType("[]interface {}")).Return(nil)
+	mTx.On("Insert", mock.AnythingOfType("string"),
+		mock.AnythingOfType("[]interface {}")).Return(fmt.Errorf("err"))
+	mTx.On("Rollback").Return(nil)
+
+	err := repo.Requeue("foo.jpg")
+	assert.Error(t, err)
+	mDbHandler.AssertExpectations(t)
+	mTx.AssertExpectations(t)
+}
@@ -2,6 +2,9 @@ package repository
 
 import (
 	"fmt"
+	"path"
+	"strings"
+	"time"
 
 	"github.mpi-internal.com/Yapo/yams-dav-sync/pkg/interfaces"
 )
@@ -10,123 +13,333 @@ import (
 type errorControlRepo struct {
 	db             DbHandler
 	resultsPerPage int
+	backoffBase    time.Duration
+	backoffMax     time.Duration
 }
 
-// NewErrorControlRepo creates a new instance of ErrorControl repository
-func NewErrorControlRepo(dbHandler DbHandler, resultsPerPage int) interfaces.ErrorControl {
+// maxBackoffExponent bounds the exponential backoff growth
+const maxBackoffExponent = 30
+
+// NewErrorControlRepo creates a new instance of ErrorControl repository, failed
+// images are retried after an exponential backoff from backoffBase up to
+// backoffMax
+func NewErrorControlRepo(dbHandler DbHandler, resultsPerPage int, backoffBase, backoffMax time.Duration) interfaces.ErrorControl {
 	return &errorControlRepo{
 		db:             dbHandler,
 		resultsPerPage: resultsPerPage,
+		backoffBase:    backoffBase,
+		backoffMax:     backoffMax,
 	}
 }
 
-// GetPreviousErrors gets all error marks in repository using pagination
-func (repo *errorControlRepo) GetPreviousErrors(nPage, maxErrorTolerance int) (result []string, err error) {
+// GetPreviousErrors gets a page of retryable error marks due to be retried,
+// paged by sync_error_id after cursor. Returns the cursor of the next page
+func (repo *errorControlRepo) GetPreviousErrors(cursor, maxErrorTolerance int) (result []string, nextCursor int, err error) {
+	if repo.resultsPerPage < 1 {
+		return []string{}, cursor, nil
+	}
 	rows, err := repo.db.Query(`
-		SELECT image_path	
+		SELECT sync_error_id, image_path
 		FROM sync_error 
 		WHERE 
-			error_counter <= $1
+			sync_error_id > $1
+			AND error_counter <= $2
+			AND retryable
+			AND next_retry_at <= CURRENT_TIMESTAMP
 		ORDER BY
 			sync_error_id 
-		LIMIT 
-			$2 OFFSET $3*($4-1)`,
+		LIMIT $3`,
+		cursor,
 		maxErrorTolerance,
 		repo.resultsPerPage,
-		repo.resultsPerPage,
-		nPage,
 	)
-	defer rows.Close() // nolint
-
 	if err != nil {
-		return
+		return []string{}, cursor, err
 	}
+	defer rows.Close() // nolint
 
-	var imgPath string
+	nextCursor = cursor
 	for rows.Next() {
-		rows.Scan(&imgPath) // nolint
+		var imgPath string
+		if err := rows.Scan(&nextCursor, &imgPath); err != nil {
+			return []string{}, cursor, err
+		}
 		result = append(result, imgPath)
 	}
 	return
 }
 
-// GetErrorsPagesQty get the total pages number for pagination
-func (repo *errorControlRepo) GetErrorsPagesQty(maxErrorTolerance int) (nPages int) {
-	if repo.resultsPerPage < 1 {
-		return 0
+// CleanErrorMarks deletes the error mark for a specific image in repository
+func (repo *errorControlRepo) CleanErrorMarks(imgPath string) error {
+	return cleanErrorMarks(repo.db, []string{imgPath})
+}
+
+// SetErrorCounter sets the error counter in repository for a specific image, if
+// does not exist then create the error mark with a given counter. The image is
+// retried in the next sync
+func (repo *errorControlRepo) SetErrorCounter(imagePath string, count int) (err error) {
+	err = setErrorCounters(repo.db, []errorMark{{imagePath: imagePath, counter: count}})
+	if err != nil {
+		err = fmt.Errorf("There was an error creating errors sync: %+v", err)
 	}
+	return
+}
 
-	result, err := repo.db.Query(`
+// IncreaseErrorCounter creates an error mark for a specific image, if exists then
+// increases the error counter. The detail of the failure replaces the previous one
+// and the next retry is delayed doubling the backoff on each failure, images
+// failing by non retryable reasons are parked & never retried
+func (repo *errorControlRepo) IncreaseErrorCounter(imagePath string, detail interfaces.ErrorDetail) (err error) {
+	err = repo.increaseErrorCounters(repo.db, []errorMark{{imagePath: imagePath, detail: detail}})
+	if err != nil {
+		err = fmt.Errorf("There was an error increasing error counter: %+v", err)
+	}
+	return
+}
+
+// errorMark is a write over the error mark of an image
+type errorMark struct {
+	imagePath string
+	counter   int
+	detail    interfaces.ErrorDetail
+}
+
+// cleanErrorMarks deletes the error marks of every image in one statement
+func cleanErrorMarks(db DbExecutor, imagePaths []string) error {
+	placeholders := make([]string, 0, len(imagePaths))
+	params := make([]interface{}, 0, len(imagePaths))
+	for i, imagePath := range imagePaths {
+		placeholders = append(placeholders, fmt.Sprintf("$%d", i+1))
+		params = append(params, imagePath)
+	}
+	return db.Update(`
+		DELETE
+		FROM sync_error
+		WHERE image_path IN (`+strings.Join(placeholders, ", ")+`)`, // nolint: gosec
+		params...,
+	)
+}
+
+// setErrorCounters upserts the error marks with its counter in one statement,
+// every image must be unique
+func setErrorCounters(db DbExecutor, marks []errorMark) error {
+	rows := make([]string, 0, len(marks))
+	params := make([]interface{}, 0, 3*len(marks))
+	for _, mark := range marks {
+		n := len(params)
+		rows = append(rows, fmt.Sprintf("($%d, $%d, $%d)", n+1, n+2, n+3))
+		params = append(params, mark.imagePath, path.Base(mark.imagePath), mark.counter)
+	}
+	return db.Insert(`
+		INSERT INTO
+			sync_error(image_path, image_name, error_counter)
+		VALUES
+			`+strings.Join(rows, ",\n\t\t\t")+`
+		ON CONFLICT (image_path)
+			DO UPDATE SET
+			error_counter = EXCLUDED.error_counter,
+			next_retry_at = CURRENT_TIMESTAMP`, // nolint: gosec
+		params...,
+	)
+}
+
+// increaseErrorCounters upserts the error marks increasing its counter with
+// the detail of the failure in one statement, every image must be unique
+func (repo *errorControlRepo) increaseErrorCounters(db DbExecutor, marks []errorMark) error {
+	rows := make([]string, 0, len(marks))
+	params := []interface{}{
+		repo.backoffBase.Seconds(),
+		repo.backoffMax.Seconds(),
+		maxBackoffExponent,
+	}
+	for _, mark := range marks {
+		n := len(params)
+		rows = append(rows, fmt.Sprintf(
+			"($%d, $%d, 0, $%d, $%d, $%d, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP, 1, $%d, "+
+				"CURRENT_TIMESTAMP + (CAST($1 AS DOUBLE PRECISION)) * INTERVAL '1 second')",
+			n+1, n+2, n+3, n+4, n+5, n+6,
+		))
+		params = append(params,
+			mark.imagePath,
+			path.Base(mark.imagePath),
+			mark.detail.Class,
+			mark.detail.HTTPStatus,
+			mark.detail.Message,
+			mark.detail.Retryable,
+		)
+	}
+	return db.Insert(`
+		INSERT INTO
+			sync_error(image_path, image_name, error_counter,
+				last_error_class, last_http_status, last_error_message,
+				first_failure_at, last_failure_at, attempts, retryable,
+				next_retry_at)
+		VALUES
+			`+strings.Join(rows, ",\n\t\t\t")+`
+		ON CONFLICT (image_path)
+			DO UPDATE SET
+			error_counter = sync_error.error_counter + 1,
+			last_error_class = EXCLUDED.last_error_class,
+			last_http_status = EXCLUDED.last_http_status,
+			last_error_message = EXCLUDED.last_error_message,
+			last_failure_at = CURRENT_TIMESTAMP,
+			attempts = sync_error.attempts + 1,
+			retryable = EXCLUDED.retryable,
+			next_retry_at = CURRENT_TIMESTAMP + (LEAST(
+				CAST($1 AS DOUBLE PRECISION) * (1 << LEAST(sync_error.error_counter + 1, $3)),
+				CAST($2 AS DOUBLE PRECISION)
+			)) * INTERVAL '1 second'`, // nolint: gosec
+		params...,
+	)
+}
+
+// ListErrors gets a page of error marks with counter equal or over minCounter
+// ordered by higher counter, with the number of pages
+func (repo *errorControlRepo) ListErrors(page, minCounter int) (list []interfaces.SyncError, pages int, err error) {
+	if repo.resultsPerPage < 1 {
+		return []interfaces.SyncError{}, 0, nil
+	}
+	count, err := repo.db.Query(`
 		SELECT count(*)
 		FROM sync_error
-		WHERE error_counter <= $1`,
-		maxErrorTolerance,
+		WHERE error_counter >= $1`,
+		minCounter,
 	)
-	defer result.Close() // nolint
 	if err != nil {
-		return 0
+		return []interfaces.SyncError{}, 0, err
 	}
 	rows := 0
-	if result.Next() {
-		err = result.Scan(&rows)
+	if count.Next() {
+		err = count.Scan(&rows)
+	}
+	count.Close() // nolint
+	if err != nil {
+		return []interfaces.SyncError{}, 0, err
+	}
+	pages = (rows + repo.resultsPerPage - 1) / repo.resultsPerPage
+
+	result, err := repo.db.Query(`
+		SELECT `+syncErrorColumns+`
+		FROM sync_error
+		WHERE error_counter >= $1
+		ORDER BY error_counter DESC, sync_error_id
+		LIMIT $2 OFFSET $3`,
+		minCounter,
+		repo.resultsPerPage,
+		repo.resultsPerPage*(page-1),
+	)
+	if err != nil {
+		return []interfaces.SyncError{}, 0, err
+	}
+	defer result.Close() // nolint
+	for result.Next() {
+		syncError, err := scanSyncError(result)
 		if err != nil {
-			return 0
+			return []interfaces.SyncError{}, 0, err
 		}
-	}
-	nPages = rows / repo.resultsPerPage
-	if rows%repo.resultsPerPage > 0 && rows > 0 {
-		nPages++
+		list = append(list, syncError)
 	}
 	return
 }
 
-// CleanErrorMarks deletes the error mark for a specific image in repository
-func (repo *errorControlRepo) CleanErrorMarks(imgPath string) error {
+// GetError gets the error mark of an image
+func (repo *errorControlRepo) GetError(imagePath string) (syncError interfaces.SyncError, err error) {
 	result, err := repo.db.Query(`
-		DELETE  
+		SELECT `+syncErrorColumns+`
 		FROM sync_error
-		where image_path = $1`,
-		imgPath,
+		WHERE image_path = $1`,
+		imagePath,
 	)
-	result.Close() // nolint
-	return err
+	if err != nil {
+		return
+	}
+	defer result.Close() // nolint
+	if !result.Next() {
+		return syncError, interfaces.ErrErrorMarkNotFound
+	}
+	return scanSyncError(result)
 }
 
-// SetErrorCounter sets the error counter in repository for a specific image, if
-// does not exist then create the error mark with a given counter
-func (repo *errorControlRepo) SetErrorCounter(imagePath string, count int) (err error) {
-	row, err := repo.db.Query(`
-		INSERT INTO
-			sync_error(image_path, error_counter)
-		VALUES 
-			($1,$2)
-		ON CONFLICT ON CONSTRAINT image_path_unique
-			DO UPDATE SET error_counter = $3`,
+// ResetErrorCounters sets to zero the error counter of an image, or every
+// counter if imagePath is empty
+func (repo *errorControlRepo) ResetErrorCounters(imagePath string) (int, error) {
+	return countRows(repo.db, `
+		UPDATE sync_error
+		SET error_counter = 0, retryable = TRUE, next_retry_at = CURRENT_TIMESTAMP
+		WHERE $1 = '' OR image_path = $1
+		RETURNING image_path`,
 		imagePath,
-		count,
-		count,
 	)
-	row.Close() // nolint
+}
+
+// PurgeErrors deletes every error mark with counter over maxErrorTolerance or
+// parked
+func (repo *errorControlRepo) PurgeErrors(maxErrorTolerance int) (int, error) {
+	return countRows(repo.db, `
+		DELETE
+		FROM sync_error
+		WHERE error_counter > $1 OR NOT retryable
+		RETURNING image_path`,
+		maxErrorTolerance,
+	)
+}
 
+// GetDeadErrors gets every image with error counter over maxErrorTolerance or
+// parked
+func (repo *errorControlRepo) GetDeadErrors(maxErrorTolerance int) (list []string, err error) {
+	result, err := repo.db.Query(`
+		SELECT image_path
+		FROM sync_error
+		WHERE error_counter > $1 OR NOT retryable
+		ORDER BY sync_error_id`,
+		maxErrorTolerance,
+	)
 	if err != nil {
-		err = fmt.Errorf("There was an error creating errors sync: %+v", err)
+		return []string{}, err
+	}
+	defer result.Close() // nolint
+	for result.Next() {
+		var imagePath string
+		if err := result.Scan(&imagePath); err != nil {
+			return []string{}, err
+		}
+		list = append(list, imagePath)
 	}
 	return
 }
 
-// IncreaseErrorCounter creates an error mark for a specific image, if exists then
-// increases the error counter
-func (repo *errorControlRepo) IncreaseErrorCounter(imagePath string) (err error) {
-	row, err := repo.db.Query(`
-			INSERT INTO
-				sync_error(image_path, error_counter)
-			VALUES
-				($1, 0)
-			ON CONFLICT ON CONSTRAINT image_path_unique
-				DO UPDATE SET 
-				error_counter = sync_error.error_counter + 1`,
-		imagePath,
+// syncErrorColumns are the columns of an error mark read by scanSyncError
+const syncErrorColumns = `image_path, image_name, error_counter, last_error_class,
+			last_http_status, last_error_message, first_failure_at,
+			last_failure_at, attempts, retryable, next_retry_at`
+
+// scanSyncError reads the current row as an error mark
+func scanSyncError(result DbResult) (syncError interfaces.SyncError, err error) {
+	err = result.Scan(
+		&syncError.ImagePath,
+		&syncError.ImageName,
+		&syncError.ErrorCounter,
+		&syncError.LastErrorClass,
+		&syncError.LastHTTPStatus,
+		&syncError.LastMessage,
+		&syncError.FirstFailureAt,
+		&syncError.LastFailureAt,
+		&syncError.Attempts,
+		&syncError.Retryable,
+		&syncError.NextRetryAt,
 	)
-	row.Close() // nolint
+	return
+}
+
+// countRows executes the statement returning the number of rows it returns
+func countRows(db DbExecutor, statement string, params ...interface{}) (count int, err error) {
+	result, err := db.Query(statement, params...)
+	if err != nil {
+		return 0, err
+	}
+	defer result.Close() // nolint
+	for result.Next() {
+		count++
+	}
 	return
 }
@@ -0,0 +1,243 @@
+package repository
+
+import (
+	"sync"
+	"time"
+
+	"github.mpi-internal.com/Yapo/yams-dav-sync/pkg/interfaces"
+)
+
+// kinds of writes buffered by the batch
+const (
+	cleanWrite = iota
+	setWrite
+	increaseWrite
+)
+
+// writeOperations names the kinds of writes
+var writeOperations = map[int]string{
+	cleanWrite:    "clean_error_marks",
+	setWrite:      "set_error_counter",
+	increaseWrite: "increase_error_counter",
+}
+
+// errorMarkWrite is a write buffered by the batch
+type errorMarkWrite struct {
+	kind int
+	mark errorMark
+}
+
+// ErrorControlBatch is an ErrorControl repository buffering the error marks
+// writes, they are applied together in a transaction when size writes are
+// pending, every interval & on Close. Reads apply the pending writes first
+type ErrorControlBatch struct {
+	repo     *errorControlRepo
+	size     int
+	mutex    sync.Mutex
+	pending  []errorMarkWrite
+	images   map[string]bool
+	failed   []interfaces.FailedErrorMark
+	quit     chan bool
+	stopOnce sync.Once
+}
+
+// NewErrorControlBatch creates a new instance of ErrorControl repository
+// with batched writes. When a batch fails its writes are retried one by one,
+// the failed ones are kept until FlushErrorMarks returns them
+func NewErrorControlBatch(dbHandler DbHandler, resultsPerPage int, backoffBase, backoffMax time.Duration,
+	size int, interval time.Duration) *ErrorControlBatch {
+	batch := &ErrorControlBatch{
+		repo: &errorControlRepo{
+			db:             dbHandler,
+			resultsPerPage: resultsPerPage,
+			backoffBase:    backoffBase,
+			backoffMax:     backoffMax,
+		},
+		size:   size,
+		images: make(map[string]bool),
+		quit:   make(chan bool),
+	}
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		go func() {
+			for {
+				select {
+				case <-ticker.C:
+					batch.Flush() // nolint
+				case <-batch.quit:
+					ticker.Stop()
+					return
+				}
+			}
+		}()
+	}
+	return batch
+}
+
+// Close stops the periodic flush & applies the pending writes
+func (batch *ErrorControlBatch) Close() error {
+	batch.stopOnce.Do(func() {
+		close(batch.quit)
+	})
+	return batch.Flush()
+}
+
+// Flush applies the pending writes
+func (batch *ErrorControlBatch) Flush() error {
+	batch.mutex.Lock()
+	defer batch.mutex.Unlock()
+	return batch.flush()
+}
+
+// FlushErrorMarks applies the pending writes, returning the writes failed
+// since the previous call
+func (batch *ErrorControlBatch) FlushErrorMarks() []interfaces.FailedErrorMark {
+	batch.mutex.Lock()
+	defer batch.mutex.Unlock()
+	batch.flush() // nolint
+	failed := batch.failed
+	batch.failed = nil
+	return failed
+}
+
+// flush applies the pending writes in a transaction, if it fails each write
+// is applied by itself so one failed write does not discard the others.
+// The mutex must be locked
+func (batch *ErrorControlBatch) flush() error {
+	if len(batch.pending) == 0 {
+		return nil
+	}
+	pending := batch.pending
+	batch.pending = nil
+	batch.images = make(map[string]bool)
+
+	err := batch.apply(pending)
+	if err == nil {
+		return nil
+	}
+	for _, write := range pending {
+		if e := batch.apply([]errorMarkWrite{write}); e != nil {
+			batch.failed = append(batch.failed, interfaces.FailedErrorMark{
+				ImagePath: write.mark.imagePath,
+				Operation: writeOperations[write.kind],
+				Err:       e,
+			})
+		}
+	}
+	return err
+}
+
+// apply applies the writes in a transaction with one statement by kind
+func (batch *ErrorControlBatch) apply(writes []errorMarkWrite) error {
+	var cleans []string
+	var sets, increases []errorMark
+	for _, write := range writes {
+		switch write.kind {
+		case cleanWrite:
+			cleans = append(cleans, write.mark.imagePath)
+		case setWrite:
+			sets = append(sets, write.mark)
+		case increaseWrite:
+			increases = append(increases, write.mark)
+		}
+	}
+
+	tx, err := batch.repo.db.Begin()
+	if err != nil {
+		return err
+	}
+	if len(cleans) > 0 {
+		err = cleanErrorMarks(tx, cleans)
+	}
+	if err == nil && len(sets) > 0 {
+		err = setErrorCounters(tx, sets)
+	}
+	if err == nil && len(increases) > 0 {
+		err = batch.repo.increaseErrorCounters(tx, increases)
+	}
+	if err != nil {
+		tx.Rollback() // nolint
+		return err
+	}
+	return tx.Commit()
+}
+
+// add buffers the write, a pending write of the same image is applied first
+// to keep the order. Failed writes are kept for FlushErrorMarks
+func (batch *ErrorControlBatch) add(write errorMarkWrite) {
+	batch.mutex.Lock()
+	defer batch.mutex.Unlock()
+	if batch.images[write.mark.imagePath] {
+		batch.flush() // nolint
+	}
+	batch.pending = append(batch.pending, write)
+	batch.images[write.mark.imagePath] = true
+	if len(batch.pending) >= batch.size {
+		batch.flush() // nolint
+	}
+}
+
+// CleanErrorMarks buffers the deletion of the image error mark
+func (batch *ErrorControlBatch) CleanErrorMarks(imgPath string) error {
+	batch.add(errorMarkWrite{
+		kind: cleanWrite,
+		mark: errorMark{imagePath: imgPath},
+	})
+	return nil
+}
+
+// SetErrorCounter buffers the update of the image error counter
+func (batch *ErrorControlBatch) SetErrorCounter(imagePath string, count int) error {
+	batch.add(errorMarkWrite{
+		kind: setWrite,
+		mark: errorMark{imagePath: imagePath, counter: count},
+	})
+	return nil
+}
+
+// IncreaseErrorCounter buffers the increase of the image error counter
+func (batch *ErrorControlBatch) IncreaseErrorCounter(imagePath string, detail interfaces.ErrorDetail) error {
+	batch.add(errorMarkWrite{
+		kind: increaseWrite,
+		mark: errorMark{imagePath: imagePath, detail: detail},
+	})
+	return nil
+}
+
+// GetPreviousErrors gets a page of retryable error marks due to be retried
+func (batch *ErrorControlBatch) GetPreviousErrors(cursor, maxErrorTolerance int) ([]string, int, error) {
+	batch.Flush() // nolint
+	return batch.repo.GetPreviousErrors(cursor, maxErrorTolerance)
+}
+
+// ListErrors gets a page of error marks with counter equal or over minCounter
+func (batch *ErrorControlBatch) ListErrors(page, minCounter int) ([]interfaces.SyncError, int, error) {
+	batch.Flush() // nolint
+	return batch.repo.ListErrors(page, minCounter)
+}
+
+// GetError gets the error mark of the image
+func (batch *ErrorControlBatch) GetError(imagePath string) (interfaces.SyncError, error) {
+	batch.Flush() // nolint
+	return batch.repo.GetError(imagePath)
+}
+
+// ResetErrorCounters resets the counter of the image error mark, or every
+// counter if imagePath is empty
+func (batch *ErrorControlBatch) ResetErrorCounters(imagePath string) (int, error) {
+	batch.Flush() // nolint
+	return batch.repo.ResetErrorCounters(imagePath)
+}
+
+// PurgeErrors deletes the error marks with counter over maxErrorTolerance or
+// parked
+func (batch *ErrorControlBatch) PurgeErrors(maxErrorTolerance int) (int, error) {
+	batch.Flush() // nolint
+	return batch.repo.PurgeErrors(maxErrorTolerance)
+}
+
+// GetDeadErrors gets the images with counter over maxErrorTolerance or parked
+func (batch *ErrorControlBatch) GetDeadErrors(maxErrorTolerance int) ([]string, error) {
+	batch.Flush() // nolint
+	return batch.repo.GetDeadErrors(maxErrorTolerance)
+}
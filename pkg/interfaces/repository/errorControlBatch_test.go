@@ -0,0 +1,144 @@
+package repository
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.mpi-internal.com/Yapo/yams-dav-sync/pkg/interfaces"
+)
+
+func TestErrorControlBatchFlushOnSize(t *testing.T) {
+	mDbHandler := &mockDbHandler{}
+	mTx := &mockDbTx{}
+	batch := NewErrorControlBatch(mDbHandler, 10, time.Minute, time.Hour, 3, 0)
+	detail := interfaces.ErrorDetail{Class: "internal", HTTPStatus: 500, Message: "err", Retryable: true}
+
+	assert.NoError(t, batch.CleanErrorMarks("a.jpg"))
+	assert.NoError(t, batch.SetErrorCounter("b.jpg", 0))
+	// nothing is written until the batch is full
+	mDbHandler.AssertExpectations(t)
+
+	mDbHandler.On("Begin").Return(mTx, nil).Once()
+	mTx.On("Update", mock.AnythingOfType("string"), []interface{}{"a.jpg"}).Return(nil).Once()
+	mTx.On("Insert", mock.AnythingOfType("string"),
+		[]interface{}{"b.jpg", "b.jpg", 0}).Return(nil).Once()
+	mTx.On("Insert", mock.AnythingOfType("string"),
+		[]interface{}{60.0, 3600.0, maxBackoffExponent, "c/c.jpg", "c.jpg",
+			"internal", 500, "err", true}).Return(nil).Once()
+	mTx.On("Commit").Return(nil).Once()
+
+	assert.NoError(t, batch.IncreaseErrorCounter("c/c.jpg", detail))
+	mDbHandler.AssertExpectations(t)
+	mTx.AssertExpectations(t)
+}
+
+func TestErrorControlBatchSameImage(t *testing.T) {
+	mDbHandler := &mockDbHandler{}
+	mTx := &mockDbTx{}
+	batch := NewErrorControlBatch(mDbHandler, 10, time.Minute, time.Hour, 10, 0)
+
+	mDbHandler.On("Begin").Return(mTx, nil).Twice()
+	mTx.On("Insert", mock.AnythingOfType("string"),
+		[]interface{}{"a.jpg", "a.jpg", 0}).Return(nil).Once()
+	mTx.On("Update", mock.AnythingOfType("string"), []interface{}{"a.jpg"}).Return(nil).Once()
+	mTx.On("Commit").Return(nil).Twice()
+
+	assert.NoError(t, batch.SetErrorCounter("a.jpg", 0))
+	// the pending write of the image is applied before the new one
+	assert.NoError(t, batch.CleanErrorMarks("a.jpg"))
+	assert.NoError(t, batch.Close())
+	mDbHandler.AssertExpectations(t)
+	mTx.AssertExpectations(t)
+}
+
+func TestErrorControlBatchFailedFlush(t *testing.T) {
+	mDbHandler := &mockDbHandler{}
+	mTx := &mockDbTx{}
+	batch := NewErrorControlBatch(mDbHandler, 10, time.Minute, time.Hour, 10, 0)
+
+	mDbHandler.On("Begin").Return(mTx, nil).Times(3)
+	// the batch fails & each write is applied by itself
+	mTx.On("Update", mock.AnythingOfType("string"),
+		[]interface{}{"a.jpg", "b.jpg"}).Return(fmt.Errorf("err")).Once()
+	mTx.On("Rollback").Return(nil).Twice()
+	mTx.On("Update", mock.AnythingOfType("string"), []interface{}{"a.jpg"}).Return(nil).Once()
+	mTx.On("Commit").Return(nil).Once()
+	mTx.On("Update", mock.AnythingOfType("string"),
+		[]interface{}{"b.jpg"}).Return(fmt.Errorf("err b")).Once()
+
+	assert.NoError(t, batch.CleanErrorMarks("a.jpg"))
+	assert.NoError(t, batch.CleanErrorMarks("b.jpg"))
+	err := batch.Flush()
+	assert.Error(t, err)
+	assert.Equal(t, []interfaces.FailedErrorMark{
+		{ImagePath: "b.jpg", Operation: "clean_error_marks", Err: fmt.Errorf("err b")},
+	}, batch.FlushErrorMarks())
+	// failures are returned once
+	assert.Empty(t, batch.FlushErrorMarks())
+	mDbHandler.AssertExpectations(t)
+	mTx.AssertExpectations(t)
+}
+
+func TestErrorControlBatchBeginError(t *testing.T) {
+	mDbHandler := &mockDbHandler{}
+	batch := NewErrorControlBatch(mDbHandler, 10, time.Minute, time.Hour, 10, 0)
+	mDbHandler.On("Begin").Return(&mockDbTx{}, fmt.Errorf("err"))
+
+	assert.NoError(t, batch.SetErrorCounter("a.jpg", 0))
+	assert.Error(t, batch.Close())
+	failed := batch.FlushErrorMarks()
+	if assert.Len(t, failed, 1) {
+		assert.Equal(t, "a.jpg", failed[0].ImagePath)
+		assert.Equal(t, "set_error_counter", failed[0].Operation)
+	}
+	mDbHandler.AssertExpectations(t)
+}
+
+func TestErrorControlBatchReadFlushes(t *testing.T) {
+	mDbHandler := &mockDbHandler{}
+	mTx := &mockDbTx{}
+	mResult := &mockResult{}
+	batch := NewErrorControlBatch(mDbHandler, 10, time.Minute, time.Hour, 10, 0)
+
+	mDbHandler.On("Begin").Return(mTx, nil).Once()
+	mTx.On("Update", mock.AnythingOfType("string"), []interface{}{"a.jpg"}).Return(nil).Once()
+	mTx.On("Commit").Return(nil).Once()
+	mDbHandler.On("Query", mock.AnythingOfType("string"),
+		[]interface{}{0, 3, 10}).Return(mResult, nil).Once()
+	mResult.On("Next").Return(false).Once()
+	mResult.On("Close").Return(nil).Once()
+
+	assert.NoError(t, batch.CleanErrorMarks("a.jpg"))
+	_, _, err := batch.GetPreviousErrors(0, 3)
+	assert.NoError(t, err)
+	mDbHandler.AssertExpectations(t)
+	mTx.AssertExpectations(t)
+	mResult.AssertExpectations(t)
+}
+
+func TestErrorControlBatchFlushOnInterval(t *testing.T) {
+	mDbHandler := &mockDbHandler{}
+	mTx := &mockDbTx{}
+	batch := NewErrorControlBatch(mDbHandler, 10, time.Minute, time.Hour, 10, 10*time.Millisecond)
+	committed := make(chan bool, 1)
+
+	mDbHandler.On("Begin").Return(mTx, nil).Once()
+	mTx.On("Update", mock.AnythingOfType("string"), []interface{}{"a.jpg"}).Return(nil).Once()
+	mTx.On("Commit").Return(nil).Once().Run(func(args mock.Arguments) {
+		committed <- true
+	})
+
+	assert.NoError(t, batch.CleanErrorMarks("a.jpg"))
+	select {
+	case <-committed:
+	case <-time.After(time.Second):
+		t.Error("pending writes were not flushed on interval")
+	}
+	assert.NoError(t, batch.Close())
+	mDbHandler.AssertExpectations(t)
+	mTx.AssertExpectations(t)
+}
@@ -3,17 +3,22 @@ package repository
 import (
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+
+	"github.mpi-internal.com/Yapo/yams-dav-sync/pkg/interfaces"
 )
 
 func TestNewErrorControlRepo(t *testing.T) {
 	var dbHandler DbHandler
 	errorControlRepo := &errorControlRepo{
-		db: dbHandler,
+		db:          dbHandler,
+		backoffBase: time.Minute,
+		backoffMax:  time.Hour,
 	}
-	result := NewErrorControlRepo(dbHandler, 0)
+	result := NewErrorControlRepo(dbHandler, 0, time.Minute, time.Hour)
 	assert.Equal(t, errorControlRepo, result)
 }
 
@@ -21,157 +26,385 @@ func TestGetPreviousErrors(t *testing.T) {
 	mDbHandler := &mockDbHandler{}
 	mResult := &mockResult{}
 	errCtrlRepo := &errorControlRepo{
-		db: mDbHandler,
+		db:             mDbHandler,
+		resultsPerPage: 10,
 	}
 	expected := []string{""}
 
 	mDbHandler.On("Query", mock.AnythingOfType("string"),
-		mock.AnythingOfType("[]interface {}")).Return(mResult, nil)
+		[]interface{}{5, 1, 10}).Return(mResult, nil)
 	mResult.On("Close").Return(nil)
 	mResult.On("Next").Return(true).Once()
 	mResult.On("Next").Return(false).Once()
 
-	mResult.On("Scan", mock.AnythingOfType("string")).Return(nil)
+	mResult.On("Scan").Return(nil)
 
-	result, err := errCtrlRepo.GetPreviousErrors(1, 1)
+	result, cursor, err := errCtrlRepo.GetPreviousErrors(5, 1)
 
 	assert.Equal(t, expected, result)
+	assert.Equal(t, 5, cursor)
 	assert.NoError(t, err)
 	mDbHandler.AssertExpectations(t)
 	mResult.AssertExpectations(t)
 }
 
-func TestGetPreviousErrorsCloseError(t *testing.T) {
+func TestGetPreviousErrorsQueryError(t *testing.T) {
 	mDbHandler := &mockDbHandler{}
-	mResult := &mockResult{}
 	errCtrlRepo := &errorControlRepo{
-		db: mDbHandler,
+		db:             mDbHandler,
+		resultsPerPage: 10,
 	}
 	mDbHandler.On("Query", mock.AnythingOfType("string"),
-		mock.AnythingOfType("[]interface {}")).Return(mResult, fmt.Errorf("err"))
-	mResult.On("Close").Return(nil)
+		mock.AnythingOfType("[]interface {}")).Return(&mockResult{}, fmt.Errorf("err"))
 
-	_, err := errCtrlRepo.GetPreviousErrors(1, 1)
+	result, cursor, err := errCtrlRepo.GetPreviousErrors(1, 1)
 
 	assert.Error(t, err)
+	assert.Equal(t, []string{}, result)
+	assert.Equal(t, 1, cursor)
 	mDbHandler.AssertExpectations(t)
-	mResult.AssertExpectations(t)
 }
 
-func TestGetErrorsPagesQty(t *testing.T) {
+func TestGetPreviousErrorsScanError(t *testing.T) {
 	mDbHandler := &mockDbHandler{}
 	mResult := &mockResult{}
 	errCtrlRepo := &errorControlRepo{
 		db:             mDbHandler,
-		resultsPerPage: 1,
+		resultsPerPage: 10,
 	}
 	mDbHandler.On("Query", mock.AnythingOfType("string"),
 		mock.AnythingOfType("[]interface {}")).Return(mResult, nil)
 	mResult.On("Close").Return(nil)
 	mResult.On("Next").Return(true).Once()
-	expected := 0
+	mResult.On("Scan").Return(fmt.Errorf("err"))
 
-	mResult.On("Scan", mock.AnythingOfType("string")).Return(nil)
+	result, _, err := errCtrlRepo.GetPreviousErrors(0, 1)
 
-	result := errCtrlRepo.GetErrorsPagesQty(expected)
-	assert.Equal(t, expected, result)
+	assert.Error(t, err)
+	assert.Equal(t, []string{}, result)
 	mDbHandler.AssertExpectations(t)
 	mResult.AssertExpectations(t)
-
 }
 
-func TestGetErrorsPagesQtyErr(t *testing.T) {
+func TestGetPreviousErrorsWithoutPageSize(t *testing.T) {
 	errCtrlRepo := &errorControlRepo{
 		resultsPerPage: 0,
 	}
-	expected := 0
-	result := errCtrlRepo.GetErrorsPagesQty(expected)
-	assert.Equal(t, expected, result)
+	result, cursor, err := errCtrlRepo.GetPreviousErrors(0, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{}, result)
+	assert.Equal(t, 0, cursor)
+}
+
+// syncErrorTable is an in memory sync_error table answering the statements
+// of GetPreviousErrors & CleanErrorMarks
+type syncErrorTable struct {
+	ids   []int
+	paths []string
+}
+
+func (table *syncErrorTable) Close() error { return nil }
+
+func (table *syncErrorTable) Insert(statement string, params ...interface{}) error { return nil }
+
+func (table *syncErrorTable) Update(statement string, params ...interface{}) error {
+	for i, path := range table.paths {
+		if path == params[0] {
+			table.ids = append(table.ids[:i], table.ids[i+1:]...)
+			table.paths = append(table.paths[:i], table.paths[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+func (table *syncErrorTable) Begin() (DbTx, error) { return nil, fmt.Errorf("not supported") }
+
+func (table *syncErrorTable) Query(statement string, params ...interface{}) (DbResult, error) {
+	result := &syncErrorRows{index: -1}
+	cursor, limit := params[0].(int), params[2].(int)
+	for i, id := range table.ids {
+		if id > cursor && len(result.ids) < limit {
+			result.ids = append(result.ids, id)
+			result.paths = append(result.paths, table.paths[i])
+		}
+	}
+	return result, nil
+}
+
+type syncErrorRows struct {
+	ids   []int
+	paths []string
+	index int
+}
+
+func (rows *syncErrorRows) Next() bool {
+	rows.index++
+	return rows.index < len(rows.ids)
+}
+
+func (rows *syncErrorRows) Scan(dest ...interface{}) error {
+	*dest[0].(*int) = rows.ids[rows.index]
+	*dest[1].(*string) = rows.paths[rows.index]
+	return nil
+}
+
+func (rows *syncErrorRows) Close() error { return nil }
+
+// Marks cleaned while iterating must not shift the next pages
+func TestGetPreviousErrorsWhileCleaning(t *testing.T) {
+	table := &syncErrorTable{}
+	expected := []string{}
+	for i := 1; i <= 25; i++ {
+		path := fmt.Sprintf("%d.jpg", i)
+		table.ids = append(table.ids, i)
+		table.paths = append(table.paths, path)
+		expected = append(expected, path)
+	}
+	errCtrlRepo := &errorControlRepo{
+		db:             table,
+		resultsPerPage: 10,
+	}
+
+	visited := []string{}
+	cursor := 0
+	for {
+		result, nextCursor, err := errCtrlRepo.GetPreviousErrors(cursor, 3)
+		assert.NoError(t, err)
+		if len(result) == 0 {
+			break
+		}
+		cursor = nextCursor
+		for _, imagePath := range result {
+			visited = append(visited, imagePath)
+			assert.NoError(t, errCtrlRepo.CleanErrorMarks(imagePath))
+		}
+	}
+	assert.Equal(t, expected, visited)
+	assert.Empty(t, table.paths)
+}
+
+func TestCleanErrorMarks(t *testing.T) {
+	mDbHandler := &mockDbHandler{}
+	errCtrlRepo := &errorControlRepo{
+		db: mDbHandler,
+	}
+	mDbHandler.On("Update", mock.AnythingOfType("string"),
+		[]interface{}{"fotito.jpg"}).Return(nil)
+
+	err := errCtrlRepo.CleanErrorMarks("fotito.jpg")
+	assert.NoError(t, err)
+	mDbHandler.AssertExpectations(t)
+}
+
+func TestSetErrorCounter(t *testing.T) {
+	mDbHandler := &mockDbHandler{}
+	errCtrlRepo := &errorControlRepo{
+		db: mDbHandler,
+	}
+	mDbHandler.On("Insert", mock.AnythingOfType("string"),
+		[]interface{}{"ab/abcdefghijklmnopqrstuvwxyz.jpg", "abcdefghijklmnopqrstuvwxyz.jpg", 0}).Return(nil)
+
+	err := errCtrlRepo.SetErrorCounter("ab/abcdefghijklmnopqrstuvwxyz.jpg", 0)
+	assert.NoError(t, err)
+	mDbHandler.AssertExpectations(t)
+}
+
+func TestSetErrorCounterError(t *testing.T) {
+	mDbHandler := &mockDbHandler{}
+	errCtrlRepo := &errorControlRepo{
+		db: mDbHandler,
+	}
+	mDbHandler.On("Insert", mock.AnythingOfType("string"),
+		mock.AnythingOfType("[]interface {}")).Return(fmt.Errorf("err"))
+
+	err := errCtrlRepo.SetErrorCounter("fotito.jpg", 0)
+	assert.Error(t, err)
+	mDbHandler.AssertExpectations(t)
+}
+
+func TestIncreaseErrorCounter(t *testing.T) {
+	mDbHandler := &mockDbHandler{}
+	errCtrlRepo := &errorControlRepo{
+		db: mDbHandler,
+	}
+	mDbHandler.On("Insert", mock.AnythingOfType("string"),
+		mock.AnythingOfType("[]interface {}")).Return(fmt.Errorf("err"))
+
+	err := errCtrlRepo.IncreaseErrorCounter("fotito.jpg", interfaces.ErrorDetail{})
+	assert.Error(t, err)
+	mDbHandler.AssertExpectations(t)
 }
 
-func TestGetErrorsPagesQtyResultQueryError(t *testing.T) {
+func TestListErrors(t *testing.T) {
 	mDbHandler := &mockDbHandler{}
+	mCount := &mockResult{}
 	mResult := &mockResult{}
 	errCtrlRepo := &errorControlRepo{
 		db:             mDbHandler,
-		resultsPerPage: 1,
+		resultsPerPage: 10,
 	}
-	mDbHandler.On("Query", mock.AnythingOfType("string"),
-		mock.AnythingOfType("[]interface {}")).Return(mResult, fmt.Errorf("err"))
+	mDbHandler.On("Query", mock.AnythingOfType("string"), []interface{}{3}).Return(mCount, nil)
+	mCount.On("Next").Return(true).Once()
+	mCount.On("Scan").Return(nil)
+	mCount.On("Close").Return(nil)
+	mDbHandler.On("Query", mock.AnythingOfType("string"), []interface{}{3, 10, 10}).Return(mResult, nil)
+	mResult.On("Next").Return(true).Once()
+	mResult.On("Next").Return(false).Once()
+	mResult.On("Scan").Return(nil)
 	mResult.On("Close").Return(nil)
-	expected := 0
 
-	result := errCtrlRepo.GetErrorsPagesQty(expected)
-	assert.Equal(t, expected, result)
+	list, pages, err := errCtrlRepo.ListErrors(2, 3)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, pages)
+	assert.Len(t, list, 1)
 	mDbHandler.AssertExpectations(t)
+	mCount.AssertExpectations(t)
 	mResult.AssertExpectations(t)
 }
 
-func TestGetErrorsPagesQtyResultScanError(t *testing.T) {
+func TestListErrorsCountError(t *testing.T) {
 	mDbHandler := &mockDbHandler{}
+	errCtrlRepo := &errorControlRepo{
+		db:             mDbHandler,
+		resultsPerPage: 10,
+	}
+	mDbHandler.On("Query", mock.AnythingOfType("string"), []interface{}{0}).
+		Return(&mockResult{}, fmt.Errorf("err"))
+
+	list, pages, err := errCtrlRepo.ListErrors(1, 0)
+	assert.Error(t, err)
+	assert.Equal(t, 0, pages)
+	assert.Equal(t, []interfaces.SyncError{}, list)
+	mDbHandler.AssertExpectations(t)
+}
+
+func TestListErrorsScanError(t *testing.T) {
+	mDbHandler := &mockDbHandler{}
+	mCount := &mockResult{}
 	mResult := &mockResult{}
 	errCtrlRepo := &errorControlRepo{
 		db:             mDbHandler,
-		resultsPerPage: 1,
+		resultsPerPage: 10,
 	}
-	mDbHandler.On("Query", mock.AnythingOfType("string"),
-		mock.AnythingOfType("[]interface {}")).Return(mResult, nil)
-	mResult.On("Close").Return(nil)
+	mDbHandler.On("Query", mock.AnythingOfType("string"), []interface{}{0}).Return(mCount, nil)
+	mCount.On("Next").Return(true).Once()
+	mCount.On("Scan").Return(nil)
+	mCount.On("Close").Return(nil)
+	mDbHandler.On("Query", mock.AnythingOfType("string"), []interface{}{0, 10, 0}).Return(mResult, nil)
 	mResult.On("Next").Return(true).Once()
-	expected := 0
-
-	mResult.On("Scan", mock.AnythingOfType("string")).Return(fmt.Errorf("err"))
+	mResult.On("Scan").Return(fmt.Errorf("err"))
+	mResult.On("Close").Return(nil)
 
-	result := errCtrlRepo.GetErrorsPagesQty(expected)
-	assert.Equal(t, expected, result)
+	list, _, err := errCtrlRepo.ListErrors(1, 0)
+	assert.Error(t, err)
+	assert.Equal(t, []interfaces.SyncError{}, list)
 	mDbHandler.AssertExpectations(t)
 	mResult.AssertExpectations(t)
 }
 
-func TestCleanErrorMarks(t *testing.T) {
+func TestGetErrorMark(t *testing.T) {
 	mDbHandler := &mockDbHandler{}
 	mResult := &mockResult{}
 	errCtrlRepo := &errorControlRepo{
 		db: mDbHandler,
 	}
-	mDbHandler.On("Query", mock.AnythingOfType("string"),
-		mock.AnythingOfType("[]interface {}")).Return(mResult, nil)
+	mDbHandler.On("Query", mock.AnythingOfType("string"), []interface{}{"foo.jpg"}).Return(mResult, nil)
+	mResult.On("Next").Return(true).Once()
+	mResult.On("Scan").Return(nil)
 	mResult.On("Close").Return(nil)
 
-	err := errCtrlRepo.CleanErrorMarks("fotito.jpg")
+	_, err := errCtrlRepo.GetError("foo.jpg")
 	assert.NoError(t, err)
 	mDbHandler.AssertExpectations(t)
 	mResult.AssertExpectations(t)
 }
 
-func TestSetErrorCounter(t *testing.T) {
+func TestGetErrorMarkNotFound(t *testing.T) {
 	mDbHandler := &mockDbHandler{}
 	mResult := &mockResult{}
 	errCtrlRepo := &errorControlRepo{
 		db: mDbHandler,
 	}
-	mDbHandler.On("Query", mock.AnythingOfType("string"),
-		mock.AnythingOfType("[]interface {}")).Return(mResult, fmt.Errorf("err"))
+	mDbHandler.On("Query", mock.AnythingOfType("string"), []interface{}{"foo.jpg"}).Return(mResult, nil)
+	mResult.On("Next").Return(false).Once()
 	mResult.On("Close").Return(nil)
 
-	err := errCtrlRepo.SetErrorCounter("fotito.jpg", 0)
-	assert.Error(t, err)
+	_, err := errCtrlRepo.GetError("foo.jpg")
+	assert.Equal(t, interfaces.ErrErrorMarkNotFound, err)
 	mDbHandler.AssertExpectations(t)
 	mResult.AssertExpectations(t)
 }
 
-func TestIncreaseErrorCounter(t *testing.T) {
+func TestResetErrorCounters(t *testing.T) {
 	mDbHandler := &mockDbHandler{}
 	mResult := &mockResult{}
 	errCtrlRepo := &errorControlRepo{
 		db: mDbHandler,
 	}
-	mDbHandler.On("Query", mock.AnythingOfType("string"),
-		mock.AnythingOfType("[]interface {}")).Return(mResult, fmt.Errorf("err"))
+	mDbHandler.On("Query", mock.AnythingOfType("string"), []interface{}{""}).Return(mResult, nil)
+	mResult.On("Next").Return(true).Twice()
+	mResult.On("Next").Return(false).Once()
 	mResult.On("Close").Return(nil)
 
-	err := errCtrlRepo.IncreaseErrorCounter("fotito.jpg")
+	count, err := errCtrlRepo.ResetErrorCounters("")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+	mDbHandler.AssertExpectations(t)
+	mResult.AssertExpectations(t)
+}
+
+func TestPurgeErrorsError(t *testing.T) {
+	mDbHandler := &mockDbHandler{}
+	errCtrlRepo := &errorControlRepo{
+		db: mDbHandler,
+	}
+	mDbHandler.On("Query", mock.AnythingOfType("string"), []interface{}{3}).
+		Return(&mockResult{}, fmt.Errorf("err"))
+
+	count, err := errCtrlRepo.PurgeErrors(3)
 	assert.Error(t, err)
+	assert.Equal(t, 0, count)
+	mDbHandler.AssertExpectations(t)
+}
+
+func TestGetDeadErrors(t *testing.T) {
+	mDbHandler := &mockDbHandler{}
+	mResult := &mockResult{}
+	errCtrlRepo := &errorControlRepo{
+		db: mDbHandler,
+	}
+	mDbHandler.On("Query", mock.AnythingOfType("string"), []interface{}{3}).Return(mResult, nil)
+	mResult.On("Next").Return(true).Once()
+	mResult.On("Next").Return(false).Once()
+	mResult.On("Scan").Return(nil)
+	mResult.On("Close").Return(nil)
+
+	list, err := errCtrlRepo.GetDeadErrors(3)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{""}, list)
 	mDbHandler.AssertExpectations(t)
 	mResult.AssertExpectations(t)
 }
+
+func TestIncreaseErrorCounterDetail(t *testing.T) {
+	mDbHandler := &mockDbHandler{}
+	errCtrlRepo := &errorControlRepo{
+		db:          mDbHandler,
+		backoffBase: time.Minute,
+		backoffMax:  time.Hour,
+	}
+	detail := interfaces.ErrorDetail{
+		Class:      "unauthorized",
+		HTTPStatus: 401,
+		Message:    "unauthorized error",
+		Retryable:  true,
+	}
+	mDbHandler.On("Insert", mock.AnythingOfType("string"),
+		[]interface{}{60.0, 3600.0, maxBackoffExponent, "fo/fotito.jpg", "fotito.jpg",
+			"unauthorized", 401, "unauthorized error", true}).Return(nil)
+
+	err := errCtrlRepo.IncreaseErrorCounter("fo/fotito.jpg", detail)
+	assert.NoError(t, err)
+	mDbHandler.AssertExpectations(t)
+}
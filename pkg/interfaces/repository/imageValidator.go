@@ -0,0 +1,138 @@
+package repository
+
+import (
+	"bytes"
+	"image"
+	_ "image/gif"  // nolint: golint
+	_ "image/jpeg" // nolint: golint
+	_ "image/png"  // nolint: golint
+	"io"
+
+	"github.mpi-internal.com/Yapo/yams-dav-sync/pkg/domain"
+	"github.mpi-internal.com/Yapo/yams-dav-sync/pkg/interfaces"
+	"github.mpi-internal.com/Yapo/yams-dav-sync/pkg/usecases"
+)
+
+// trailerSize is the number of bytes kept from the end of the image to
+// check the format end marker, jpeg files may have data after it
+const trailerSize = 4096
+
+var (
+	// jpegEOI is the jpeg end of image marker
+	jpegEOI = []byte{0xFF, 0xD9}
+	// pngIEND is the png final chunk type followed by its crc
+	pngIEND = []byte{'I', 'E', 'N', 'D', 0xAE, 0x42, 0x60, 0x82}
+	// gifTrailer is the gif trailer byte
+	gifTrailer = []byte{0x3B}
+)
+
+// imageValidator validates local images decoding its headers before upload
+type imageValidator struct {
+	// fileSystemView allows operations in local storage
+	fileSystemView FileSystemView
+	// minWidth is the minimum width in pixels accepted
+	minWidth int
+	// minHeight is the minimum height in pixels accepted
+	minHeight int
+}
+
+// NewImageValidator creates a new instance of ImageValidator
+func NewImageValidator(fileSystemView FileSystemView, minWidth, minHeight int) interfaces.ImageValidator {
+	return &imageValidator{
+		fileSystemView: fileSystemView,
+		minWidth:       minWidth,
+		minHeight:      minHeight,
+	}
+}
+
+// Validate decodes the image header checking format and dimensions, then
+// reads the image until the end to check it is not truncated. Failures
+// opening or reading the file are returned as they are, they are not a
+// problem of the image
+func (v *imageValidator) Validate(img domain.Image) error {
+	f, err := v.fileSystemView.Open(img.FilePath)
+	if err != nil {
+		return err
+	}
+	defer f.Close() // nolint:errcheck,gosec
+
+	source := &readErrorRecorder{reader: f}
+	trailer := &trailerWriter{size: trailerSize}
+	reader := io.TeeReader(source, trailer)
+
+	config, format, err := image.DecodeConfig(reader)
+	if err != nil {
+		if source.err != nil {
+			return source.err
+		}
+		if trailer.total == 0 {
+			return usecases.ErrImageEmpty
+		}
+		return usecases.ErrImageUnknownFormat
+	}
+	if config.Width < v.minWidth || config.Height < v.minHeight {
+		return usecases.ErrImageDimensions
+	}
+	if _, err := io.Copy(trailer, source); err != nil {
+		return err
+	}
+	if !hasEndMarker(format, trailer.bytes()) {
+		return usecases.ErrImageTruncated
+	}
+	return nil
+}
+
+// hasEndMarker checks if the given trailer ends with the format end marker,
+// ignoring zero padding written by some encoders. The jpeg end of image may be
+// followed by trailers, padding or a new line so it is searched in the whole
+// trailer
+func hasEndMarker(format string, trailer []byte) bool {
+	trailer = bytes.TrimRight(trailer, "\x00")
+	switch format {
+	case "jpeg":
+		return bytes.Contains(trailer, jpegEOI)
+	case "png":
+		return bytes.HasSuffix(trailer, pngIEND)
+	case "gif":
+		return bytes.HasSuffix(trailer, gifTrailer)
+	}
+	return true
+}
+
+// readErrorRecorder keeps the error reading the file, other than EOF, to tell
+// it apart from decoding errors
+type readErrorRecorder struct {
+	reader io.Reader
+	err    error
+}
+
+// Read reads from the file recording the error, if any
+func (r *readErrorRecorder) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	if err != nil && err != io.EOF {
+		r.err = err
+	}
+	return n, err
+}
+
+// trailerWriter keeps the last written bytes discarding the rest
+type trailerWriter struct {
+	size  int
+	buf   []byte
+	total int64
+}
+
+// Write appends p to the buffer keeping only the last size bytes
+func (w *trailerWriter) Write(p []byte) (int, error) {
+	w.total += int64(len(p))
+	w.buf = append(w.buf, p...)
+	if len(w.buf) > w.size {
+		w.buf = append(w.buf[:0], w.buf[len(w.buf)-w.size:]...)
+	}
+	return len(p), nil
+}
+
+// bytes returns the last written bytes
+func (w *trailerWriter) bytes() []byte {
+	return w.buf
+}
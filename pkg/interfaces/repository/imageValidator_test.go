@@ -0,0 +1,106 @@
+package repository
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.mpi-internal.com/Yapo/yams-dav-sync/pkg/domain"
+	"github.mpi-internal.com/Yapo/yams-dav-sync/pkg/usecases"
+)
+
+func encodeTestImage(format string, width, height int) []byte {
+	var buf bytes.Buffer
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	switch format {
+	case "jpeg":
+		jpeg.Encode(&buf, img, nil) // nolint
+	case "png":
+		png.Encode(&buf, img) // nolint
+	}
+	return buf.Bytes()
+}
+
+func TestNewImageValidator(t *testing.T) {
+	var fileSystemView FileSystemView
+	expected := &imageValidator{
+		fileSystemView: fileSystemView,
+		minWidth:       1,
+		minHeight:      2,
+	}
+	result := NewImageValidator(fileSystemView, 1, 2)
+	assert.Equal(t, expected, result)
+}
+
+func TestImageValidatorValidate(t *testing.T) {
+	validJPEG := encodeTestImage("jpeg", 10, 10)
+	validPNG := encodeTestImage("png", 10, 10)
+	testCases := []struct {
+		content  []byte
+		expected error
+	}{
+		{content: validJPEG, expected: nil},
+		{content: append(validJPEG[:len(validJPEG):len(validJPEG)], 0, 0), expected: nil},
+		{content: append(validJPEG[:len(validJPEG):len(validJPEG)], '\n'), expected: nil},
+		{content: append(validJPEG[:len(validJPEG):len(validJPEG)], bytes.Repeat([]byte("trailer"), 200)...), expected: nil},
+		{content: validPNG, expected: nil},
+		{content: []byte{}, expected: usecases.ErrImageEmpty},
+		{content: []byte("not an image"), expected: usecases.ErrImageUnknownFormat},
+		{content: validJPEG[:len(validJPEG)-10], expected: usecases.ErrImageTruncated},
+		{content: validPNG[:len(validPNG)-4], expected: usecases.ErrImageTruncated},
+		{content: encodeTestImage("jpeg", 10, 1), expected: usecases.ErrImageDimensions},
+	}
+	for _, testCase := range testCases {
+		mFileSystem := &mockFileSystemView{}
+		validator := &imageValidator{
+			fileSystemView: mFileSystem,
+			minWidth:       5,
+			minHeight:      5,
+		}
+		mFileSystem.On("Open", "fo/foo.jpg").
+			Return(ioutil.NopCloser(bytes.NewReader(testCase.content)), nil).Once()
+
+		err := validator.Validate(domain.Image{FilePath: "fo/foo.jpg"})
+		assert.Equal(t, testCase.expected, err)
+		mFileSystem.AssertExpectations(t)
+	}
+}
+
+func TestImageValidatorValidateOpenError(t *testing.T) {
+	mFileSystem := &mockFileSystemView{}
+	mFile := &mockFile{}
+	validator := &imageValidator{
+		fileSystemView: mFileSystem,
+	}
+	mFileSystem.On("Open", mock.AnythingOfType("string")).Return(mFile, fmt.Errorf("err"))
+
+	err := validator.Validate(domain.Image{})
+	assert.Equal(t, fmt.Errorf("err"), err)
+	mFileSystem.AssertExpectations(t)
+	mFile.AssertExpectations(t)
+}
+
+type failingReader struct{}
+
+func (failingReader) Read(p []byte) (int, error) { return 0, fmt.Errorf("input/output error") }
+
+func TestImageValidatorValidateReadError(t *testing.T) {
+	mFileSystem := &mockFileSystemView{}
+	validator := &imageValidator{
+		fileSystemView: mFileSystem,
+	}
+	mFileSystem.On("Open", mock.AnythingOfType("string")).
+		Return(ioutil.NopCloser(failingReader{}), nil)
+
+	err := validator.Validate(domain.Image{})
+	assert.Equal(t, fmt.Errorf("input/output error"), err)
+	_, invalid := err.(*usecases.ImageValidationError)
+	assert.False(t, invalid)
+	mFileSystem.AssertExpectations(t)
+}
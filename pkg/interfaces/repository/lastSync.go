@@ -6,6 +6,9 @@ import (
 	"github.mpi-internal.com/Yapo/yams-dav-sync/pkg/interfaces"
 )
 
+// markLayout is the layout of the synchronization marks sent to the database
+const markLayout = "2006-01-02 15:04:05"
+
 // lastSyncRepo repository to save current synchronization date mark
 type lastSyncRepo struct {
 	db          DbHandler
@@ -43,26 +46,30 @@ func (repo *lastSyncRepo) GetLastSynchronizationMark() (lastSyncDate time.Time)
 	return lastSyncDate
 }
 
-// SetLastSynchronizationMark saves a new synchronization date mark
+// SetLastSynchronizationMark saves a new synchronization date mark, the mark
+// keeps the precision of the date layout and is stored in a format every
+// database driver parses
 func (repo *lastSyncRepo) SetLastSynchronizationMark(date time.Time) (err error) {
+	mark, err := time.Parse(repo.dateLayout, date.Format(repo.dateLayout))
+	if err != nil {
+		return err
+	}
 	return repo.db.Insert(`
 		INSERT INTO last_sync(last_sync_date)
 		VALUES ($1)`,
-		date.Format(repo.dateLayout),
+		mark.Format(markLayout),
 	)
 }
 
 // Reset deletes the last synchronization date mark to run the process again from the last
 // checkpoint
-func (repo *lastSyncRepo) Reset() (err error) {
-	result, err := repo.db.Query(`
+func (repo *lastSyncRepo) Reset() error {
+	return repo.db.Update(`
 		DELETE FROM last_sync
 		WHERE last_sync_id
 		IN (SELECT last_sync_id FROM last_sync
 			ORDER BY last_sync_id DESC LIMIT 1)
 		`)
-	result.Close() // nolint
-	return
 }
 
 // Get gets a list of synchronization marks order by newer to older
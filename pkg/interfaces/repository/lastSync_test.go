@@ -90,13 +90,15 @@ func TestGetLastSynchronizationMarkErrScan(t *testing.T) {
 func TestSetLastSynchronizationMark(t *testing.T) {
 	mDbHandler := &mockDbHandler{}
 	lastSyncRepo := &lastSyncRepo{
-		db: mDbHandler,
+		db:         mDbHandler,
+		dateLayout: "20060102T150405",
 	}
 
 	mDbHandler.On("Insert", mock.AnythingOfType("string"),
-		mock.AnythingOfType("[]interface {}")).Return(nil)
+		[]interface{}{"2019-03-04 05:06:07"}).Return(nil)
 
-	err := lastSyncRepo.SetLastSynchronizationMark(time.Now())
+	err := lastSyncRepo.SetLastSynchronizationMark(
+		time.Date(2019, 3, 4, 5, 6, 7, 890, time.Local))
 
 	assert.NoError(t, err)
 	mDbHandler.AssertExpectations(t)
@@ -170,18 +172,30 @@ func TestGetQueryError(t *testing.T) {
 
 func TestReset(t *testing.T) {
 	mDbHandler := &mockDbHandler{}
-	mResult := &mockResult{}
 	lastSyncRepo := &lastSyncRepo{
 		db:          mDbHandler,
 		defaultDate: time.Time{},
 	}
 
-	mDbHandler.On("Query", mock.AnythingOfType("string"),
-		mock.AnythingOfType("[]interface {}")).Return(mResult, nil)
-	mResult.On("Close").Return(nil)
+	mDbHandler.On("Update", mock.AnythingOfType("string"),
+		mock.AnythingOfType("[]interface {}")).Return(nil)
 
 	err := lastSyncRepo.Reset()
 	assert.NoError(t, err)
 	mDbHandler.AssertExpectations(t)
-	mResult.AssertExpectations(t)
+}
+
+func TestResetError(t *testing.T) {
+	mDbHandler := &mockDbHandler{}
+	lastSyncRepo := &lastSyncRepo{
+		db:          mDbHandler,
+		defaultDate: time.Time{},
+	}
+
+	mDbHandler.On("Update", mock.AnythingOfType("string"),
+		mock.AnythingOfType("[]interface {}")).Return(fmt.Errorf("err"))
+
+	err := lastSyncRepo.Reset()
+	assert.Error(t, err)
+	mDbHandler.AssertExpectations(t)
 }
@@ -58,6 +58,7 @@ func (repo *LocalImageRepo) GetLocalImage(imagePath string) (domain.Image, error
 
 	image := domain.Image{
 		FilePath: filePath,
+		Path:     imagePath,
 		Metadata: domain.ImageMetadata{
 			ImageName: fileInfo.Name(),
 			Size:      fileInfo.Size(),
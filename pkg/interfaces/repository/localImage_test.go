@@ -73,6 +73,7 @@ func TestGetLocalImageOK(t *testing.T) {
 			Checksum: "d41d8cd98f00b204e9800998ecf8427e",
 		},
 		FilePath: "fo/foto-sexy.jpg",
+		Path:     "foto-sexy.jpg",
 	}
 
 	result, err := imgRepo.GetLocalImage("foto-sexy.jpg")
@@ -0,0 +1,60 @@
+package repository
+
+import (
+	"fmt"
+
+	"github.mpi-internal.com/Yapo/yams-dav-sync/pkg/interfaces"
+)
+
+// quarantineRepo repository to store images rejected by validation
+type quarantineRepo struct {
+	db DbHandler
+}
+
+// NewQuarantineRepo creates a new instance of Quarantine repository
+func NewQuarantineRepo(dbHandler DbHandler) interfaces.Quarantine {
+	return &quarantineRepo{
+		db: dbHandler,
+	}
+}
+
+// Add stores the image in quarantine with the reason of its rejection, if
+// the image was already in quarantine then updates the reason
+func (repo *quarantineRepo) Add(imagePath, reason string) (err error) {
+	err = repo.db.Insert(`
+		INSERT INTO
+			sync_quarantine(image_path, reason)
+		VALUES
+			($1, $2)
+		ON CONFLICT (image_path)
+			DO UPDATE SET
+			reason = $2,
+			quarantined_at = CURRENT_TIMESTAMP`,
+		imagePath,
+		reason,
+	)
+	if err != nil {
+		err = fmt.Errorf("There was an error quarantining image: %+v", err)
+	}
+	return
+}
+
+// List gets the quarantined images with its reason ordered by newer to older
+func (repo *quarantineRepo) List() (list []string, err error) {
+	result, err := repo.db.Query(`
+		SELECT image_path, reason, quarantined_at
+		FROM sync_quarantine
+		ORDER BY quarantined_at DESC`)
+	if err != nil {
+		return []string{}, err
+	}
+	defer result.Close() // nolint
+	for result.Next() {
+		var imagePath, reason, date string
+		if err := result.Scan(&imagePath, &reason, &date); err != nil {
+			return []string{}, err
+		}
+		list = append(list, fmt.Sprintf("%s %s %s", date, imagePath, reason))
+	}
+	return
+}
@@ -0,0 +1,95 @@
+package repository
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestNewQuarantineRepo(t *testing.T) {
+	var dbHandler DbHandler
+	expected := &quarantineRepo{
+		db: dbHandler,
+	}
+	result := NewQuarantineRepo(dbHandler)
+	assert.Equal(t, expected, result)
+}
+
+func TestQuarantineAdd(t *testing.T) {
+	mDbHandler := &mockDbHandler{}
+	repo := &quarantineRepo{
+		db: mDbHandler,
+	}
+	mDbHandler.On("Insert", mock.AnythingOfType("string"),
+		[]interface{}{"foo.jpg", "truncated image"}).Return(nil)
+
+	err := repo.Add("foo.jpg", "truncated image")
+	assert.NoError(t, err)
+	mDbHandler.AssertExpectations(t)
+}
+
+func TestQuarantineAddError(t *testing.T) {
+	mDbHandler := &mockDbHandler{}
+	repo := &quarantineRepo{
+		db: mDbHandler,
+	}
+	mDbHandler.On("Insert", mock.AnythingOfType("string"),
+		mock.AnythingOfType("[]interface {}")).Return(fmt.Errorf("err"))
+
+	err := repo.Add("foo.jpg", "truncated image")
+	assert.Error(t, err)
+	mDbHandler.AssertExpectations(t)
+}
+
+func TestQuarantineList(t *testing.T) {
+	mDbHandler := &mockDbHandler{}
+	mResult := &mockResult{}
+	repo := &quarantineRepo{
+		db: mDbHandler,
+	}
+	mDbHandler.On("Query", mock.AnythingOfType("string"), []interface{}(nil)).Return(mResult, nil)
+	mResult.On("Close").Return(nil)
+	mResult.On("Next").Return(true).Once()
+	mResult.On("Next").Return(false).Once()
+	mResult.On("Scan").Return(nil)
+
+	result, err := repo.List()
+	assert.Equal(t, []string{"  "}, result)
+	assert.NoError(t, err)
+	mDbHandler.AssertExpectations(t)
+	mResult.AssertExpectations(t)
+}
+
+func TestQuarantineListError(t *testing.T) {
+	mDbHandler := &mockDbHandler{}
+	mResult := &mockResult{}
+	repo := &quarantineRepo{
+		db: mDbHandler,
+	}
+	mDbHandler.On("Query", mock.AnythingOfType("string"), []interface{}(nil)).Return(mResult, fmt.Errorf("err"))
+
+	result, err := repo.List()
+	assert.Equal(t, []string{}, result)
+	assert.Error(t, err)
+	mDbHandler.AssertExpectations(t)
+}
+
+func TestQuarantineListScanError(t *testing.T) {
+	mDbHandler := &mockDbHandler{}
+	mResult := &mockResult{}
+	repo := &quarantineRepo{
+		db: mDbHandler,
+	}
+	mDbHandler.On("Query", mock.AnythingOfType("string"), []interface{}(nil)).Return(mResult, nil)
+	mResult.On("Close").Return(nil)
+	mResult.On("Next").Return(true).Once()
+	mResult.On("Scan").Return(fmt.Errorf("err"))
+
+	result, err := repo.List()
+	assert.Equal(t, []string{}, result)
+	assert.Error(t, err)
+	mDbHandler.AssertExpectations(t)
+	mResult.AssertExpectations(t)
+}
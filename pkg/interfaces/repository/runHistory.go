@@ -0,0 +1,105 @@
+package repository
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.mpi-internal.com/Yapo/yams-dav-sync/pkg/interfaces"
+)
+
+// runHistoryRepo repository to store the record of each command execution
+type runHistoryRepo struct {
+	db DbHandler
+}
+
+// NewRunHistoryRepo creates a new instance of RunHistory repository
+func NewRunHistoryRepo(dbHandler DbHandler) interfaces.RunHistory {
+	return &runHistoryRepo{
+		db: dbHandler,
+	}
+}
+
+// Add stores the run with its flags encoded as json
+func (repo *runHistoryRepo) Add(run interfaces.SyncRun) (err error) {
+	flags, err := json.Marshal(run.Flags)
+	if err != nil {
+		return fmt.Errorf("There was an error encoding run flags: %+v", err)
+	}
+	err = repo.db.Insert(`
+		INSERT INTO
+			sync_run(command, flags, started_at, finished_at, sent, errors,
+				duplicated, processed, skipped, not_found, recovered,
+				quarantined, mark_before, mark_after, exit_reason, db_errors)
+		VALUES
+			($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15,
+				$16)`,
+		run.Command,
+		string(flags),
+		run.StartedAt,
+		run.FinishedAt,
+		run.Stats["sent"],
+		run.Stats["errors"],
+		run.Stats["duplicated"],
+		run.Stats["processed"],
+		run.Stats["skipped"],
+		run.Stats["not_found"],
+		run.Stats["recovered"],
+		run.Stats["quarantined"],
+		run.MarkBefore,
+		run.MarkAfter,
+		run.ExitReason,
+		run.Stats["db_errors"],
+	)
+	if err != nil {
+		err = fmt.Errorf("There was an error saving run: %+v", err)
+	}
+	return
+}
+
+// List gets the latest runs ordered by newer to older
+func (repo *runHistoryRepo) List(limit int) (runs []interfaces.SyncRun, err error) {
+	result, err := repo.db.Query(`
+		SELECT command, flags, started_at, finished_at, sent, errors,
+			duplicated, processed, skipped, not_found, recovered,
+			quarantined, mark_before, mark_after, exit_reason, db_errors
+		FROM sync_run
+		ORDER BY started_at DESC
+		LIMIT $1`,
+		limit,
+	)
+	if err != nil {
+		return []interfaces.SyncRun{}, err
+	}
+	defer result.Close() // nolint
+	for result.Next() {
+		var run interfaces.SyncRun
+		var flags string
+		var sent, errors, duplicated, processed, skipped, notFound, recovered, quarantined, dbErrors int
+		err := result.Scan(&run.Command, &flags, &run.StartedAt, &run.FinishedAt,
+			&sent, &errors, &duplicated, &processed, &skipped, &notFound,
+			&recovered, &quarantined, &run.MarkBefore, &run.MarkAfter,
+			&run.ExitReason, &dbErrors)
+		if err != nil {
+			return []interfaces.SyncRun{}, err
+		}
+		run.Flags = map[string]string{}
+		if flags != "" {
+			if err := json.Unmarshal([]byte(flags), &run.Flags); err != nil {
+				return []interfaces.SyncRun{}, err
+			}
+		}
+		run.Stats = map[string]int{
+			"sent":        sent,
+			"errors":      errors,
+			"duplicated":  duplicated,
+			"processed":   processed,
+			"skipped":     skipped,
+			"not_found":   notFound,
+			"recovered":   recovered,
+			"quarantined": quarantined,
+			"db_errors":   dbErrors,
+		}
+		runs = append(runs, run)
+	}
+	return
+}
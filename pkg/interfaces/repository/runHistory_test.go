@@ -0,0 +1,113 @@
+package repository
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.mpi-internal.com/Yapo/yams-dav-sync/pkg/interfaces"
+)
+
+func TestNewRunHistoryRepo(t *testing.T) {
+	var dbHandler DbHandler
+	expected := &runHistoryRepo{
+		db: dbHandler,
+	}
+	result := NewRunHistoryRepo(dbHandler)
+	assert.Equal(t, expected, result)
+}
+
+func TestRunHistoryAdd(t *testing.T) {
+	mDbHandler := &mockDbHandler{}
+	repo := &runHistoryRepo{
+		db: mDbHandler,
+	}
+	start := time.Date(2019, 1, 1, 10, 0, 0, 0, time.UTC)
+	end := start.Add(time.Minute)
+	run := interfaces.SyncRun{
+		Command:    "sync",
+		Flags:      map[string]string{"threads": "5"},
+		StartedAt:  start,
+		FinishedAt: end,
+		Stats:      map[string]int{"sent": 3, "errors": 1, "processed": 4, "db_errors": 2},
+		MarkBefore: start,
+		MarkAfter:  end,
+		ExitReason: interfaces.RunCompleted,
+	}
+	mDbHandler.On("Insert", mock.AnythingOfType("string"),
+		[]interface{}{"sync", `{"threads":"5"}`, start, end, 3, 1, 0, 4, 0, 0, 0, 0,
+			start, end, "completed", 2}).Return(nil)
+
+	err := repo.Add(run)
+	assert.NoError(t, err)
+	mDbHandler.AssertExpectations(t)
+}
+
+func TestRunHistoryAddError(t *testing.T) {
+	mDbHandler := &mockDbHandler{}
+	repo := &runHistoryRepo{
+		db: mDbHandler,
+	}
+	mDbHandler.On("Insert", mock.AnythingOfType("string"),
+		mock.AnythingOfType("[]interface {}")).Return(fmt.Errorf("err"))
+
+	err := repo.Add(interfaces.SyncRun{Command: "sync"})
+	assert.Error(t, err)
+	mDbHandler.AssertExpectations(t)
+}
+
+func TestRunHistoryList(t *testing.T) {
+	mDbHandler := &mockDbHandler{}
+	mResult := &mockResult{}
+	repo := &runHistoryRepo{
+		db: mDbHandler,
+	}
+	mDbHandler.On("Query", mock.AnythingOfType("string"), []interface{}{10}).Return(mResult, nil)
+	mResult.On("Close").Return(nil)
+	mResult.On("Next").Return(true).Once()
+	mResult.On("Next").Return(false).Once()
+	mResult.On("Scan").Return(nil)
+
+	result, err := repo.List(10)
+	assert.NoError(t, err)
+	assert.Len(t, result, 1)
+	assert.Equal(t, map[string]string{}, result[0].Flags)
+	assert.Equal(t, 0, result[0].Stats["sent"])
+	mDbHandler.AssertExpectations(t)
+	mResult.AssertExpectations(t)
+}
+
+func TestRunHistoryListError(t *testing.T) {
+	mDbHandler := &mockDbHandler{}
+	mResult := &mockResult{}
+	repo := &runHistoryRepo{
+		db: mDbHandler,
+	}
+	mDbHandler.On("Query", mock.AnythingOfType("string"), []interface{}{10}).Return(mResult, fmt.Errorf("err"))
+
+	result, err := repo.List(10)
+	assert.Equal(t, []interfaces.SyncRun{}, result)
+	assert.Error(t, err)
+	mDbHandler.AssertExpectations(t)
+}
+
+func TestRunHistoryListScanError(t *testing.T) {
+	mDbHandler := &mockDbHandler{}
+	mResult := &mockResult{}
+	repo := &runHistoryRepo{
+		db: mDbHandler,
+	}
+	mDbHandler.On("Query", mock.AnythingOfType("string"), []interface{}{10}).Return(mResult, nil)
+	mResult.On("Close").Return(nil)
+	mResult.On("Next").Return(true).Once()
+	mResult.On("Scan").Return(fmt.Errorf("err"))
+
+	result, err := repo.List(10)
+	assert.Equal(t, []interfaces.SyncRun{}, result)
+	assert.Error(t, err)
+	mDbHandler.AssertExpectations(t)
+	mResult.AssertExpectations(t)
+}
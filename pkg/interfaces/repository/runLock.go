@@ -0,0 +1,164 @@
+package repository
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.mpi-internal.com/Yapo/yams-dav-sync/pkg/interfaces"
+)
+
+// runLockRepo keeps the run lock of a profile in the sync_lock table. The
+// holder refreshes its heartbeat while it runs, a lock without heartbeat for
+// staleAfter is stale and the next run takes it over
+type runLockRepo struct {
+	db         DbHandler
+	profile    string
+	host       string
+	pid        int
+	staleAfter time.Duration
+	heartbeat  time.Duration
+	mutex      sync.Mutex
+	quit       chan bool
+}
+
+// NewRunLockRepo creates a new instance of RunLock repository on the lock
+// table, identifying this process by host & pid. The heartbeat must be shorter
+// than staleAfter, otherwise the lock of a live holder is taken over
+func NewRunLockRepo(dbHandler DbHandler, profile, host string, pid int, staleAfter, heartbeat time.Duration) (interfaces.RunLock, error) {
+	if heartbeat <= 0 || heartbeat >= staleAfter {
+		return nil, fmt.Errorf("Invalid run lock heartbeat %v, it must be positive & shorter than the stale time %v",
+			heartbeat, staleAfter)
+	}
+	return &runLockRepo{
+		db:         dbHandler,
+		profile:    profile,
+		host:       host,
+		pid:        pid,
+		staleAfter: staleAfter,
+		heartbeat:  heartbeat,
+	}, nil
+}
+
+// TryAcquire takes the lock if it is free or stale, refreshing its heartbeat
+// until it is released
+func (repo *runLockRepo) TryAcquire(command string) (bool, error) {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+	if repo.quit != nil {
+		// this process is running with the lock
+		return false, nil
+	}
+	acquired, err := countRows(repo.db, `
+		INSERT INTO
+			sync_lock(profile, command, host, pid, acquired_at, heartbeat_at)
+		VALUES
+			($1, $2, $3, $4, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)
+		ON CONFLICT (profile)
+			DO UPDATE SET
+			command = EXCLUDED.command,
+			host = EXCLUDED.host,
+			pid = EXCLUDED.pid,
+			acquired_at = CURRENT_TIMESTAMP,
+			heartbeat_at = CURRENT_TIMESTAMP
+			WHERE sync_lock.heartbeat_at <
+				CURRENT_TIMESTAMP - (CAST($5 AS DOUBLE PRECISION)) * INTERVAL '1 second'
+		RETURNING profile`,
+		repo.profile,
+		command,
+		repo.host,
+		repo.pid,
+		repo.staleAfter.Seconds(),
+	)
+	if err != nil || acquired == 0 {
+		return false, err
+	}
+	repo.quit = make(chan bool)
+	if repo.heartbeat > 0 {
+		go repo.keepAlive(repo.quit)
+	}
+	return true, nil
+}
+
+// keepAlive refreshes the heartbeat of the lock until quit is closed, a
+// failed refresh is retried in the next beat
+func (repo *runLockRepo) keepAlive(quit chan bool) {
+	ticker := time.NewTicker(repo.heartbeat)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			repo.db.Update(`
+				UPDATE sync_lock
+				SET heartbeat_at = CURRENT_TIMESTAMP
+				WHERE profile = $1 AND host = $2 AND pid = $3`,
+				repo.profile,
+				repo.host,
+				repo.pid,
+			) // nolint
+		case <-quit:
+			return
+		}
+	}
+}
+
+// Release frees the lock if it is still held by this process
+func (repo *runLockRepo) Release() error {
+	repo.mutex.Lock()
+	defer repo.mutex.Unlock()
+	if repo.quit == nil {
+		return nil
+	}
+	close(repo.quit)
+	repo.quit = nil
+	return repo.db.Update(`
+		DELETE
+		FROM sync_lock
+		WHERE profile = $1 AND host = $2 AND pid = $3`,
+		repo.profile,
+		repo.host,
+		repo.pid,
+	)
+}
+
+// Holder gets the run holding the lock, false if it is free
+func (repo *runLockRepo) Holder() (holder interfaces.LockHolder, held bool, err error) {
+	result, err := repo.db.Query(`
+		SELECT profile, command, host, pid, acquired_at, heartbeat_at,
+			heartbeat_at <
+				CURRENT_TIMESTAMP - (CAST($2 AS DOUBLE PRECISION)) * INTERVAL '1 second'
+		FROM sync_lock
+		WHERE profile = $1`,
+		repo.profile,
+		repo.staleAfter.Seconds(),
+	)
+	if err != nil {
+		return
+	}
+	defer result.Close() // nolint
+	if !result.Next() {
+		return
+	}
+	err = result.Scan(
+		&holder.Profile,
+		&holder.Command,
+		&holder.Host,
+		&holder.PID,
+		&holder.AcquiredAt,
+		&holder.HeartbeatAt,
+		&holder.Stale,
+	)
+	return holder, err == nil, err
+}
+
+// Break frees the lock whoever holds it
+func (repo *runLockRepo) Break() (bool, error) {
+	count, err := countRows(repo.db, `
+		DELETE
+		FROM sync_lock
+		WHERE profile = $1
+		RETURNING profile`,
+		repo.profile,
+	)
+	return count > 0, err
+}
@@ -0,0 +1,131 @@
+package repository
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestNewRunLockRepo(t *testing.T) {
+	var dbHandler DbHandler
+	expected := &runLockRepo{
+		db:         dbHandler,
+		profile:    "default",
+		host:       "host",
+		pid:        10,
+		staleAfter: time.Minute,
+		heartbeat:  time.Second,
+	}
+	result, err := NewRunLockRepo(dbHandler, "default", "host", 10, time.Minute, time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, result)
+
+	for _, heartbeat := range []time.Duration{0, time.Minute, time.Hour} {
+		_, err = NewRunLockRepo(dbHandler, "default", "host", 10, time.Minute, heartbeat)
+		assert.Error(t, err, "heartbeat %v", heartbeat)
+	}
+}
+
+func TestRunLockAcquireAndRelease(t *testing.T) {
+	mDbHandler := &mockDbHandler{}
+	mResult := &mockResult{}
+	repo := &runLockRepo{
+		db:         mDbHandler,
+		profile:    "default",
+		host:       "host",
+		pid:        10,
+		staleAfter: time.Minute,
+	}
+	mDbHandler.On("Query", mock.AnythingOfType("string"),
+		[]interface{}{"default", "sync", "host", 10, float64(60)}).Return(mResult, nil)
+	mDbHandler.On("Update", mock.AnythingOfType("string"),
+		[]interface{}{"default", "host", 10}).Return(nil).Once()
+	mResult.On("Close").Return(nil)
+	mResult.On("Next").Return(true).Once()
+	mResult.On("Next").Return(false).Once()
+
+	acquired, err := repo.TryAcquire("sync")
+	assert.NoError(t, err)
+	assert.True(t, acquired)
+	// held by this process
+	acquired, err = repo.TryAcquire("sync")
+	assert.NoError(t, err)
+	assert.False(t, acquired)
+
+	assert.NoError(t, repo.Release())
+	assert.NoError(t, repo.Release())
+	mDbHandler.AssertExpectations(t)
+	mResult.AssertExpectations(t)
+}
+
+func TestRunLockAcquireHeld(t *testing.T) {
+	mDbHandler := &mockDbHandler{}
+	mResult := &mockResult{}
+	repo := &runLockRepo{
+		db: mDbHandler,
+	}
+	mDbHandler.On("Query", mock.AnythingOfType("string"),
+		mock.AnythingOfType("[]interface {}")).Return(mResult, nil).Once()
+	mDbHandler.On("Query", mock.AnythingOfType("string"),
+		mock.AnythingOfType("[]interface {}")).Return(mResult, fmt.Errorf("err")).Once()
+	mResult.On("Close").Return(nil)
+	mResult.On("Next").Return(false).Once()
+
+	acquired, err := repo.TryAcquire("sync")
+	assert.NoError(t, err)
+	assert.False(t, acquired)
+	acquired, err = repo.TryAcquire("sync")
+	assert.Error(t, err)
+	assert.False(t, acquired)
+	assert.Nil(t, repo.quit)
+	mDbHandler.AssertExpectations(t)
+	mResult.AssertExpectations(t)
+}
+
+func TestRunLockHolder(t *testing.T) {
+	mDbHandler := &mockDbHandler{}
+	mResult := &mockResult{}
+	repo := &runLockRepo{
+		db:         mDbHandler,
+		profile:    "default",
+		staleAfter: time.Minute,
+	}
+	mDbHandler.On("Query", mock.AnythingOfType("string"),
+		[]interface{}{"default", float64(60)}).Return(mResult, nil)
+	mResult.On("Close").Return(nil)
+	mResult.On("Next").Return(true).Once()
+	mResult.On("Next").Return(false).Once()
+	mResult.On("Scan").Return(nil)
+
+	_, held, err := repo.Holder()
+	assert.NoError(t, err)
+	assert.True(t, held)
+	_, held, err = repo.Holder()
+	assert.NoError(t, err)
+	assert.False(t, held)
+	mDbHandler.AssertExpectations(t)
+	mResult.AssertExpectations(t)
+}
+
+func TestRunLockBreak(t *testing.T) {
+	mDbHandler := &mockDbHandler{}
+	mResult := &mockResult{}
+	repo := &runLockRepo{
+		db:      mDbHandler,
+		profile: "default",
+	}
+	mDbHandler.On("Query", mock.AnythingOfType("string"),
+		[]interface{}{"default"}).Return(mResult, nil)
+	mResult.On("Close").Return(nil)
+	mResult.On("Next").Return(true).Once()
+	mResult.On("Next").Return(false).Once()
+
+	broken, err := repo.Break()
+	assert.NoError(t, err)
+	assert.True(t, broken)
+	mDbHandler.AssertExpectations(t)
+	mResult.AssertExpectations(t)
+}
@@ -3,7 +3,9 @@ package repository
 import (
 	"encoding/json"
 	"fmt"
+	"net"
 	"strconv"
+	"sync"
 	"time"
 
 	jwt "github.com/dgrijalva/jwt-go"
@@ -40,6 +42,19 @@ type YamsRepository struct {
 	yamsErrorControlValue string
 	// logger logs yams repository events
 	logger YamsRepositoryLogger
+	// secondaryJWTSigner validates each request with the secondary key when
+	// the primary key is rejected by yams
+	secondaryJWTSigner Signer
+	// secondaryAccessKeyID is the user secondary accesskey connected to yams server
+	secondaryAccessKeyID string
+	// metrics exposes the active access key
+	metrics interfaces.MetricsExposer
+	// keyMutex guards the active access key
+	keyMutex sync.RWMutex
+	// activeKey is the access key used to sign requests, primary or secondary
+	activeKey int
+	// tracer traces signing & sending of each request
+	tracer interfaces.Tracer
 }
 
 // Signer allows methods to validate each request to yams server
@@ -47,10 +62,13 @@ type Signer interface {
 	GenerateTokenString(claims jwt.Claims) string
 }
 
-// NewYamsRepository creates a new instance of YamsRepository
+// NewYamsRepository creates a new instance of YamsRepository. The secondary key
+// is optional, if it is set then requests rejected as unauthorized are retried
+// with the other key
 func NewYamsRepository(jwtSigner Signer, mgmtURL, accessKeyID, tenantID,
 	domainID, bucketID string, localImageRepo interfaces.LocalImage, logger YamsRepositoryLogger, handler HTTPHandler,
-	timeOut int, yamsErrorControlHeader, yamsErrorControlValue string, maxConcurrentThreads int) *YamsRepository {
+	timeOut int, yamsErrorControlHeader, yamsErrorControlValue string, maxConcurrentThreads int,
+	secondaryJWTSigner Signer, secondaryAccessKeyID string, metrics interfaces.MetricsExposer) *YamsRepository {
 	return &YamsRepository{
 		jwtSigner:   jwtSigner,
 		mgmtURL:     mgmtURL,
@@ -67,15 +85,24 @@ func NewYamsRepository(jwtSigner Signer, mgmtURL, accessKeyID, tenantID,
 		yamsErrorControlValue:  yamsErrorControlValue,
 		maxConcurrentThreads:   maxConcurrentThreads,
 		localImageRepo:         localImageRepo,
+		secondaryJWTSigner:     secondaryJWTSigner,
+		secondaryAccessKeyID:   secondaryAccessKeyID,
+		metrics:                metrics,
 	}
 }
 
+// SetTracer enables tracing of yams requests
+func (repo *YamsRepository) SetTracer(tracer interfaces.Tracer) {
+	repo.tracer = tracer
+}
+
 // YamsRepositoryLogger allows methods to log yams repository events
 type YamsRepositoryLogger interface {
 	LogRequestURI(url string)
 	LogStatus(statusCode int)
 	LogResponse(body string, err error)
 	LogCannotDecodeErrorMessage(err error)
+	LogAccessKeySwitch(from, to string)
 }
 
 // GetMaxConcurrency gets the max number of concurrent connections to yams
@@ -83,8 +110,45 @@ func (repo *YamsRepository) GetMaxConcurrency() int {
 	return repo.maxConcurrentThreads
 }
 
+// activeCredentials returns the active access key with its signer and access key id
+func (repo *YamsRepository) activeCredentials() (int, Signer, string) {
+	repo.keyMutex.RLock()
+	defer repo.keyMutex.RUnlock()
+	if repo.activeKey == domain.SecondaryAccessKey {
+		return domain.SecondaryAccessKey, repo.secondaryJWTSigner, repo.secondaryAccessKeyID
+	}
+	return domain.PrimaryAccessKey, repo.jwtSigner, repo.accessKeyID
+}
+
+// rotateKey switches the active access key when the key rejected by yams is
+// still the active one. Returns true if the request should be retried with the
+// new active key, that is only possible when a secondary key is configured
+func (repo *YamsRepository) rotateKey(rejectedKey int) bool {
+	if repo.secondaryJWTSigner == nil {
+		return false
+	}
+	repo.keyMutex.Lock()
+	defer repo.keyMutex.Unlock()
+	if repo.activeKey != rejectedKey {
+		// another request already switched the key
+		return true
+	}
+	from, to := repo.accessKeyID, repo.secondaryAccessKeyID
+	repo.activeKey = domain.SecondaryAccessKey
+	if rejectedKey == domain.SecondaryAccessKey {
+		from, to = to, from
+		repo.activeKey = domain.PrimaryAccessKey
+	}
+	repo.logger.LogAccessKeySwitch(from, to)
+	if repo.metrics != nil {
+		repo.metrics.SetGauge(domain.ActiveAccessKey, float64(repo.activeKey))
+	}
+	return true
+}
+
 // GetDomains gets domains from yams, domains belongs to the repo tenant
 func (repo *YamsRepository) GetDomains() string {
+	_, jwtSigner, accessKeyID := repo.activeCredentials()
 
 	type MyCustomClaims struct {
 		jwt.StandardClaims
@@ -101,14 +165,14 @@ func (repo *YamsRepository) GetDomains() string {
 		"GET\\" + path,
 	}
 
-	tokenString := repo.jwtSigner.GenerateTokenString(claims)
+	tokenString := jwtSigner.GenerateTokenString(claims)
 
 	requestURI := repo.mgmtURL + path
 	repo.logger.LogRequestURI(requestURI)
 
 	queryParams := map[string]string{
 		"jwt":         tokenString,
-		"AccessKeyId": repo.accessKeyID,
+		"AccessKeyId": accessKeyID,
 	}
 
 	request := repo.http.Handler.
@@ -126,7 +190,33 @@ func (repo *YamsRepository) GetDomains() string {
 }
 
 // Send puts a image in yams repository
-func (repo *YamsRepository) Send(image domain.Image) (checksum string, e *usecases.YamsRepositoryError) {
+func (repo *YamsRepository) Send(image domain.Image) (string, *usecases.YamsRepositoryError) {
+	return repo.put(image,
+		repo.startSpan("yams.put", map[string]string{"image.name": image.Metadata.ImageName}))
+}
+
+// SendTraced puts a image in yams repository tracing the request as a child
+// of the parent span
+func (repo *YamsRepository) SendTraced(parent interfaces.Span, image domain.Image) (string, *usecases.YamsRepositoryError) {
+	return repo.put(image,
+		parent.StartChild("yams.put", map[string]string{"image.name": image.Metadata.ImageName}))
+}
+
+// put puts a image in yams repository retrying once with the other access
+// key if it is rejected, the span is ended once it is done
+func (repo *YamsRepository) put(image domain.Image, span interfaces.Span) (string, *usecases.YamsRepositoryError) {
+	key, checksum, err := repo.send(image, span)
+	if err.Kind() == usecases.ErrYamsUnauthorized && repo.rotateKey(key) {
+		_, checksum, err = repo.send(image, span)
+	}
+	span.End(spanError(err))
+	return checksum, err
+}
+
+// send puts a image in yams repository using the active access key, returns
+// the access key used
+func (repo *YamsRepository) send(image domain.Image, span interfaces.Span) (int, string, *usecases.YamsRepositoryError) {
+	key, jwtSigner, accessKeyID := repo.activeCredentials()
 	type PutMetadata struct {
 		ObjectID string `json:"oid"`
 	}
@@ -160,18 +250,18 @@ func (repo *YamsRepository) Send(image domain.Image) (checksum string, e *usecas
 		},
 	}
 
-	tokenString := repo.jwtSigner.GenerateTokenString(claims)
+	tokenString := repo.sign(span, jwtSigner, claims)
 
 	requestURI := repo.mgmtURL + path
 
 	queryParams := map[string]string{
 		"jwt":         tokenString,
-		"AccessKeyId": repo.accessKeyID,
+		"AccessKeyId": accessKeyID,
 	}
 
 	imageFile, err := repo.localImageRepo.OpenFile(image.FilePath)
 	if err != nil {
-		return "", usecases.ErrYamsImage
+		return key, "", usecases.ErrYamsImage
 	}
 
 	request := repo.http.Handler.
@@ -185,39 +275,69 @@ func (repo *YamsRepository) Send(image domain.Image) (checksum string, e *usecas
 			repo.yamsErrorControlHeader: repo.yamsErrorControlValue,
 		})
 
-	resp, err := repo.http.Handler.Send(request)
+	resp, err := repo.sendRequest(span, "POST", request)
 
 	imageFile.Close() // nolint
 	repo.logger.LogStatus(resp.Code)
 	body := fmt.Sprintf("%s", resp.Body)
 	repo.logger.LogResponse(body, err)
+	if e := transportError(err); e != nil {
+		return key, "", e
+	}
 
 	switch resp.Code {
 	case 400: // Bad Request
-		return "", usecases.ErrYamsInternal
+		return key, "", usecases.ErrYamsInternal.WithStatus(resp.Code)
 	case 401:
 		fallthrough
 	case 403:
-		return "", usecases.ErrYamsUnauthorized
+		return key, "", usecases.ErrYamsUnauthorized.WithStatus(resp.Code)
 	case 404:
-		return "", usecases.ErrYamsBucketNotFound
+		return key, "", usecases.ErrYamsBucketNotFound.WithStatus(resp.Code)
 	case 409: // Duplicated image
 		errorInfo := PutError{}
 		if e := json.Unmarshal([]byte(body), &errorInfo); e != nil {
 			repo.logger.LogCannotDecodeErrorMessage(e)
 		}
-		return errorInfo.AdditionalInfo.Etag, usecases.ErrYamsDuplicate
+		return key, errorInfo.AdditionalInfo.Etag, usecases.ErrYamsDuplicate.WithStatus(resp.Code)
 	case 500: // Server error
-		return "", usecases.ErrYamsInternal
+		return key, "", usecases.ErrYamsInternal.WithStatus(resp.Code)
 	case 503: // Service temporarily unavailable
-		return "", usecases.ErrYamsInternal
+		return key, "", usecases.ErrYamsInternal.WithStatus(resp.Code)
 	}
 
-	return image.Metadata.Checksum, nil
+	return key, image.Metadata.Checksum, nil
 }
 
 // RemoteDelete deletes a specific image of yams repository
 func (repo *YamsRepository) RemoteDelete(imageName string, immediateRemoval bool) *usecases.YamsRepositoryError {
+	return repo.delete(imageName, immediateRemoval,
+		repo.startSpan("yams.delete", map[string]string{"image.name": imageName}))
+}
+
+// RemoteDeleteTraced deletes a specific image of yams repository tracing the
+// request as a child of the parent span
+func (repo *YamsRepository) RemoteDeleteTraced(parent interfaces.Span, imageName string,
+	immediateRemoval bool) *usecases.YamsRepositoryError {
+	return repo.delete(imageName, immediateRemoval,
+		parent.StartChild("yams.delete", map[string]string{"image.name": imageName}))
+}
+
+// delete deletes a specific image of yams repository retrying once with the
+// other access key if it is rejected, the span is ended once it is done
+func (repo *YamsRepository) delete(imageName string, immediateRemoval bool, span interfaces.Span) *usecases.YamsRepositoryError {
+	key, err := repo.remoteDelete(imageName, immediateRemoval, span)
+	if err.Kind() == usecases.ErrYamsUnauthorized && repo.rotateKey(key) {
+		_, err = repo.remoteDelete(imageName, immediateRemoval, span)
+	}
+	span.End(spanError(err))
+	return err
+}
+
+// remoteDelete deletes a specific image of yams repository using the active
+// access key, returns the access key used
+func (repo *YamsRepository) remoteDelete(imageName string, immediateRemoval bool, span interfaces.Span) (int, *usecases.YamsRepositoryError) {
+	key, jwtSigner, accessKeyID := repo.activeCredentials()
 
 	type DeleteMetadata struct {
 		ObjectID              string `json:"oid"`
@@ -247,7 +367,7 @@ func (repo *YamsRepository) RemoteDelete(imageName string, immediateRemoval bool
 		},
 	}
 
-	tokenString := repo.jwtSigner.GenerateTokenString(claims)
+	tokenString := repo.sign(span, jwtSigner, claims)
 
 	requestURI := repo.mgmtURL + path
 
@@ -255,7 +375,7 @@ func (repo *YamsRepository) RemoteDelete(imageName string, immediateRemoval bool
 
 	queryParams := map[string]string{
 		"jwt":         tokenString,
-		"AccessKeyId": repo.accessKeyID,
+		"AccessKeyId": accessKeyID,
 	}
 
 	request := repo.http.Handler.
@@ -265,34 +385,50 @@ func (repo *YamsRepository) RemoteDelete(imageName string, immediateRemoval bool
 		SetQueryParams(queryParams).
 		SetTimeOut(repo.http.TimeOut)
 
-	resp, err := repo.http.Handler.Send(request)
+	resp, err := repo.sendRequest(span, "DELETE", request)
 	repo.logger.LogStatus(resp.Code)
 	body := fmt.Sprintf("%s", resp.Body)
 
 	repo.logger.LogResponse(body, err)
+	if e := transportError(err); e != nil {
+		return key, e
+	}
 
 	switch resp.Code {
 	case 202: // All good, object deleted
-		return nil
+		return key, nil
 	case 400: // Bad Request
-		return usecases.ErrYamsInternal
+		return key, usecases.ErrYamsInternal.WithStatus(resp.Code)
 	case 401:
 		fallthrough
 	case 403:
-		return usecases.ErrYamsUnauthorized
+		return key, usecases.ErrYamsUnauthorized.WithStatus(resp.Code)
 	case 404:
-		return usecases.ErrYamsObjectNotFound
+		return key, usecases.ErrYamsObjectNotFound.WithStatus(resp.Code)
 	case 500: // Server error
-		return usecases.ErrYamsInternal
+		return key, usecases.ErrYamsInternal.WithStatus(resp.Code)
 	case 503: // Service temporarily unavailable
-		return usecases.ErrYamsInternal
+		return key, usecases.ErrYamsInternal.WithStatus(resp.Code)
 	default: // Unknown error
-		return usecases.ErrYamsInternal
+		return key, usecases.ErrYamsInternal.WithStatus(resp.Code)
 	}
 }
 
 // GetRemoteChecksum gets an object metadata.
 func (repo *YamsRepository) GetRemoteChecksum(imageName string) (string, *usecases.YamsRepositoryError) {
+	span := repo.startSpan("yams.head", map[string]string{"image.name": imageName})
+	key, checksum, err := repo.getRemoteChecksum(imageName, span)
+	if err.Kind() == usecases.ErrYamsUnauthorized && repo.rotateKey(key) {
+		_, checksum, err = repo.getRemoteChecksum(imageName, span)
+	}
+	span.End(spanError(err))
+	return checksum, err
+}
+
+// getRemoteChecksum gets an object metadata using the active access key,
+// returns the access key used
+func (repo *YamsRepository) getRemoteChecksum(imageName string, span interfaces.Span) (int, string, *usecases.YamsRepositoryError) {
+	key, jwtSigner, accessKeyID := repo.activeCredentials()
 	type InfoClaims struct {
 		jwt.StandardClaims
 		Rqs string `json:"rqs"`
@@ -311,7 +447,7 @@ func (repo *YamsRepository) GetRemoteChecksum(imageName string) (string, *usecas
 		"HEAD\\" + path,
 	}
 
-	tokenString := repo.jwtSigner.GenerateTokenString(claims)
+	tokenString := repo.sign(span, jwtSigner, claims)
 
 	requestURI := repo.mgmtURL + path
 
@@ -319,7 +455,7 @@ func (repo *YamsRepository) GetRemoteChecksum(imageName string) (string, *usecas
 
 	queryParams := map[string]string{
 		"jwt":         tokenString,
-		"AccessKeyId": repo.accessKeyID,
+		"AccessKeyId": accessKeyID,
 	}
 
 	request := repo.http.Handler.
@@ -329,35 +465,52 @@ func (repo *YamsRepository) GetRemoteChecksum(imageName string) (string, *usecas
 		SetQueryParams(queryParams).
 		SetTimeOut(repo.http.TimeOut)
 
-	resp, err := repo.http.Handler.Send(request)
+	resp, err := repo.sendRequest(span, "HEAD", request)
 	repo.logger.LogStatus(resp.Code)
 	body := fmt.Sprintf("%s", resp.Body)
 
 	hashResponse := resp.Headers.Get("Content-Md5")
 
 	repo.logger.LogResponse(body, err)
+	if e := transportError(err); e != nil {
+		return key, hashResponse, e
+	}
 
 	switch resp.Code {
 	case 200: // Headers are set and returned
-		return hashResponse, nil
+		return key, hashResponse, nil
 	case 401:
 		fallthrough
 	case 403:
-		return hashResponse, usecases.ErrYamsUnauthorized
+		return key, hashResponse, usecases.ErrYamsUnauthorized.WithStatus(resp.Code)
 	case 404:
-		return hashResponse, usecases.ErrYamsObjectNotFound
+		return key, hashResponse, usecases.ErrYamsObjectNotFound.WithStatus(resp.Code)
 	case 500: // Server error
-		return hashResponse, usecases.ErrYamsInternal
+		return key, hashResponse, usecases.ErrYamsInternal.WithStatus(resp.Code)
 	case 503: // Service temporarily unavailable
-		return hashResponse, usecases.ErrYamsInternal
+		return key, hashResponse, usecases.ErrYamsInternal.WithStatus(resp.Code)
 	default: // Unkown error
-		return hashResponse, usecases.ErrYamsInternal
+		return key, hashResponse, usecases.ErrYamsInternal.WithStatus(resp.Code)
 	}
 }
 
 // List gets a list of available images in yams repository
 func (repo *YamsRepository) List(continuationToken string, step int) (
 	[]usecases.YamsObject, string, *usecases.YamsRepositoryError) {
+	span := repo.startSpan("yams.list", nil)
+	key, images, newContinuationToken, err := repo.list(continuationToken, step, span)
+	if err.Kind() == usecases.ErrYamsUnauthorized && repo.rotateKey(key) {
+		_, images, newContinuationToken, err = repo.list(continuationToken, step, span)
+	}
+	span.End(spanError(err))
+	return images, newContinuationToken, err
+}
+
+// list gets a list of available images in yams repository using the active
+// access key, returns the access key used
+func (repo *YamsRepository) list(continuationToken string, step int, span interfaces.Span) (
+	int, []usecases.YamsObject, string, *usecases.YamsRepositoryError) {
+	key, jwtSigner, accessKeyID := repo.activeCredentials()
 	type InfoClaims struct {
 		jwt.StandardClaims
 		Rqs string `json:"rqs"`
@@ -376,7 +529,7 @@ func (repo *YamsRepository) List(continuationToken string, step int) (
 		"GET\\" + path,
 	}
 
-	tokenString := repo.jwtSigner.GenerateTokenString(claims)
+	tokenString := repo.sign(span, jwtSigner, claims)
 
 	requestURI := repo.mgmtURL + path
 
@@ -384,7 +537,7 @@ func (repo *YamsRepository) List(continuationToken string, step int) (
 
 	queryParams := map[string]string{
 		"jwt":         tokenString,
-		"AccessKeyId": repo.accessKeyID,
+		"AccessKeyId": accessKeyID,
 	}
 
 	if continuationToken != "" {
@@ -400,31 +553,83 @@ func (repo *YamsRepository) List(continuationToken string, step int) (
 		SetPath(requestURI).
 		SetQueryParams(queryParams).
 		SetTimeOut(repo.http.TimeOut)
-	resp, err := repo.http.Handler.Send(request)
+	resp, err := repo.sendRequest(span, "GET", request)
 
 	body := fmt.Sprintf("%s", resp.Body)
 
 	repo.logger.LogResponse(body, err)
+	if e := transportError(err); e != nil {
+		return key, nil, "", e
+	}
 
 	var response usecases.YamsGetResponse
 	err = json.Unmarshal([]byte(body), &response)
 	if err != nil {
-		return nil, "", usecases.ErrYamsInternal
+		return key, nil, "", usecases.ErrYamsInternal.WithStatus(resp.Code)
 	}
 	switch resp.Code {
 	case 200: // Headers are set and returned
-		return response.Images, response.ContinuationToken, nil
+		return key, response.Images, response.ContinuationToken, nil
 	case 401:
 		fallthrough
 	case 403:
-		return nil, "", usecases.ErrYamsUnauthorized
+		return key, nil, "", usecases.ErrYamsUnauthorized.WithStatus(resp.Code)
 	case 404:
-		return nil, "", usecases.ErrYamsObjectNotFound
+		return key, nil, "", usecases.ErrYamsObjectNotFound.WithStatus(resp.Code)
 	case 500: // Server error
-		return nil, "", usecases.ErrYamsInternal
+		return key, nil, "", usecases.ErrYamsInternal.WithStatus(resp.Code)
 	case 503: // Service temporarily unavailable
-		return nil, "", usecases.ErrYamsInternal
+		return key, nil, "", usecases.ErrYamsInternal.WithStatus(resp.Code)
 	default: // Unkown error
-		return nil, response.ContinuationToken, usecases.ErrYamsInternal
+		return key, nil, response.ContinuationToken, usecases.ErrYamsInternal.WithStatus(resp.Code)
+	}
+}
+
+// startSpan starts a new trace if tracing is enabled
+func (repo *YamsRepository) startSpan(name string, attributes map[string]string) interfaces.Span {
+	if repo.tracer == nil {
+		return interfaces.NoopTracer.StartSpan(name, attributes)
+	}
+	return repo.tracer.StartSpan(name, attributes)
+}
+
+// sign generates the jwt token of the claims inside a child span
+func (repo *YamsRepository) sign(span interfaces.Span, signer Signer, claims jwt.Claims) string {
+	signSpan := span.StartChild("jwt.sign", nil)
+	defer signSpan.End(nil)
+	return signer.GenerateTokenString(claims)
+}
+
+// sendRequest sends the request to yams inside a child span
+func (repo *YamsRepository) sendRequest(span interfaces.Span, method string, request HTTPRequest) (HTTPResponse, error) {
+	sendSpan := span.StartChild("http.send", map[string]string{"http.method": method})
+	resp, err := repo.http.Handler.Send(request)
+	sendSpan.SetAttribute("http.status_code", strconv.Itoa(resp.Code))
+	sendSpan.End(err)
+	return resp, err
+}
+
+// spanError returns the yams error as error, nil if there is no error
+func spanError(err *usecases.YamsRepositoryError) error {
+	if err == nil {
+		return nil
+	}
+	return err
+}
+
+// transportError classifies errors sending a request to yams: timeouts,
+// connection errors & requests rejected without being sent. It returns nil
+// if yams answered the request
+func transportError(err error) *usecases.YamsRepositoryError {
+	if err == ErrRequestRejected {
+		return usecases.ErrYamsConnection
+	}
+	netErr, ok := err.(net.Error)
+	if !ok {
+		return nil
+	}
+	if netErr.Timeout() {
+		return usecases.ErrYamsTimeout
 	}
+	return usecases.ErrYamsConnection
 }
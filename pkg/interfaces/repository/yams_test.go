@@ -3,6 +3,7 @@ package repository
 import (
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"testing"
 	"time"
@@ -44,6 +45,35 @@ func (m *MockYamsRepoLogger) LogCannotDecodeErrorMessage(err error) {
 	m.Called(err)
 }
 
+func (m *MockYamsRepoLogger) LogAccessKeySwitch(from, to string) {
+	m.Called(from, to)
+}
+
+type mockMetricsExposer struct {
+	mock.Mock
+}
+
+func (m *mockMetricsExposer) IncrementCounter(metric int) {
+	m.Called(metric)
+}
+
+func (m *mockMetricsExposer) IncrementCounterWithLabel(metric int, label string) {
+	m.Called(metric, label)
+}
+
+func (m *mockMetricsExposer) SetGauge(metric int, value float64) {
+	m.Called(metric, value)
+}
+
+func (m *mockMetricsExposer) SetGaugeWithLabel(metric int, label string, value float64) {
+	m.Called(metric, label, value)
+}
+
+func (m *mockMetricsExposer) Close() error {
+	args := m.Called()
+	return args.Error(0)
+}
+
 func TestNewYamsRepository(t *testing.T) {
 	var jwtSigner Signer
 	var logger YamsRepositoryLogger
@@ -64,7 +94,7 @@ func TestNewYamsRepository(t *testing.T) {
 	}
 	result := NewYamsRepository(yamsRepo.jwtSigner, yamsRepo.mgmtURL, yamsRepo.accessKeyID,
 		yamsRepo.tenantID, yamsRepo.domainID, yamsRepo.bucketID, nil, yamsRepo.logger, http, 0,
-		"", "", yamsRepo.maxConcurrentThreads)
+		"", "", yamsRepo.maxConcurrentThreads, nil, "", nil)
 	assert.Equal(t, &yamsRepo, result)
 }
 
@@ -274,7 +304,7 @@ func TestSend(t *testing.T) {
 			mHandler.On("Send", &mRequest).Return(response, nil).Once()
 			remoteChecksum, resp := yamsRepo.Send(domain.Image{})
 			assert.Equal(t, expected, remoteChecksum)
-			assert.Equal(t, usecases.ErrYamsInternal, resp)
+			assert.Equal(t, usecases.ErrYamsInternal.WithStatus(400), resp)
 
 		case 2: // 403 Unauthorized error
 			response := HTTPResponse{
@@ -283,7 +313,7 @@ func TestSend(t *testing.T) {
 			mHandler.On("Send", &mRequest).Return(response, nil).Once()
 			remoteChecksum, resp := yamsRepo.Send(domain.Image{})
 			assert.Equal(t, expected, remoteChecksum)
-			assert.Equal(t, usecases.ErrYamsUnauthorized, resp)
+			assert.Equal(t, usecases.ErrYamsUnauthorized.WithStatus(403), resp)
 		case 3: // 404 Bucket Not Found error
 			response := HTTPResponse{
 				Code: 404,
@@ -291,7 +321,7 @@ func TestSend(t *testing.T) {
 			mHandler.On("Send", &mRequest).Return(response, nil).Once()
 			remoteChecksum, resp := yamsRepo.Send(domain.Image{})
 			assert.Equal(t, expected, remoteChecksum)
-			assert.Equal(t, usecases.ErrYamsBucketNotFound, resp)
+			assert.Equal(t, usecases.ErrYamsBucketNotFound.WithStatus(404), resp)
 		case 4: // 409 object duplicated error
 			response := HTTPResponse{
 				Code: 409,
@@ -299,7 +329,7 @@ func TestSend(t *testing.T) {
 			mHandler.On("Send", &mRequest).Return(response, nil).Once()
 			remoteChecksum, resp := yamsRepo.Send(domain.Image{})
 			assert.Equal(t, expected, remoteChecksum)
-			assert.Equal(t, usecases.ErrYamsDuplicate, resp)
+			assert.Equal(t, usecases.ErrYamsDuplicate.WithStatus(409), resp)
 		case 5: // 500 Internal Server error
 			response := HTTPResponse{
 				Code: 500,
@@ -307,7 +337,7 @@ func TestSend(t *testing.T) {
 			mHandler.On("Send", &mRequest).Return(response, nil).Once()
 			remoteChecksum, resp := yamsRepo.Send(domain.Image{})
 			assert.Equal(t, expected, remoteChecksum)
-			assert.Equal(t, usecases.ErrYamsInternal, resp)
+			assert.Equal(t, usecases.ErrYamsInternal.WithStatus(500), resp)
 		case 6: // 503 Yams internal error
 			response := HTTPResponse{
 				Code: 503,
@@ -315,7 +345,7 @@ func TestSend(t *testing.T) {
 			mHandler.On("Send", &mRequest).Return(response, nil).Once()
 			remoteChecksum, resp := yamsRepo.Send(domain.Image{})
 			assert.Equal(t, expected, remoteChecksum)
-			assert.Equal(t, usecases.ErrYamsInternal, resp)
+			assert.Equal(t, usecases.ErrYamsInternal.WithStatus(503), resp)
 		}
 	}
 
@@ -370,42 +400,42 @@ func TestRemoteDelete(t *testing.T) {
 			}
 			mHandler.On("Send", &mRequest).Return(response, nil).Once()
 			resp := yamsRepo.RemoteDelete("foto-sexy.jpg", domain.YAMSForceRemoval)
-			assert.Equal(t, resp, usecases.ErrYamsInternal)
+			assert.Equal(t, resp, usecases.ErrYamsInternal.WithStatus(400))
 		case 2: // 403 yams Unauthorized error
 			response := HTTPResponse{
 				Code: 403,
 			}
 			mHandler.On("Send", &mRequest).Return(response, nil).Once()
 			resp := yamsRepo.RemoteDelete("foto-sexy.jpg", domain.YAMSForceRemoval)
-			assert.Equal(t, resp, usecases.ErrYamsUnauthorized)
+			assert.Equal(t, resp, usecases.ErrYamsUnauthorized.WithStatus(403))
 		case 3: // 404 object not found error
 			response := HTTPResponse{
 				Code: 404,
 			}
 			mHandler.On("Send", &mRequest).Return(response, nil).Once()
 			resp := yamsRepo.RemoteDelete("foto-sexy.jpg", domain.YAMSForceRemoval)
-			assert.Equal(t, resp, usecases.ErrYamsObjectNotFound)
+			assert.Equal(t, resp, usecases.ErrYamsObjectNotFound.WithStatus(404))
 		case 4: // 500 server error
 			response := HTTPResponse{
 				Code: 500,
 			}
 			mHandler.On("Send", &mRequest).Return(response, nil).Once()
 			resp := yamsRepo.RemoteDelete("foto-sexy.jpg", domain.YAMSForceRemoval)
-			assert.Equal(t, resp, usecases.ErrYamsInternal)
+			assert.Equal(t, resp, usecases.ErrYamsInternal.WithStatus(500))
 		case 5: // 503 Service temporarily unavailable
 			response := HTTPResponse{
 				Code: 503,
 			}
 			mHandler.On("Send", &mRequest).Return(response, nil).Once()
 			resp := yamsRepo.RemoteDelete("foto-sexy.jpg", domain.YAMSForceRemoval)
-			assert.Equal(t, resp, usecases.ErrYamsInternal)
+			assert.Equal(t, resp, usecases.ErrYamsInternal.WithStatus(503))
 		default: // Unknown error
 			response := HTTPResponse{
 				Code: 999,
 			}
 			mHandler.On("Send", &mRequest).Return(response, nil).Once()
 			resp := yamsRepo.RemoteDelete("foto-sexy.jpg", domain.YAMSForceRemoval)
-			assert.Equal(t, resp, usecases.ErrYamsInternal)
+			assert.Equal(t, resp, usecases.ErrYamsInternal.WithStatus(999))
 		}
 	}
 	mLogger.AssertExpectations(t)
@@ -414,6 +444,86 @@ func TestRemoteDelete(t *testing.T) {
 	mRequest.AssertExpectations(t)
 }
 
+func TestRemoteDeleteKeyRotation(t *testing.T) {
+	mLogger := MockYamsRepoLogger{}
+	mSigner := mockSigner{}
+	mSecondarySigner := mockSigner{}
+	mHandler := mockHTTPHandler{}
+	mRequest := mockRequest{}
+	mMetrics := mockMetricsExposer{}
+
+	yamsRepo := YamsRepository{
+		jwtSigner:            &mSigner,
+		accessKeyID:          "primary",
+		secondaryJWTSigner:   &mSecondarySigner,
+		secondaryAccessKeyID: "secondary",
+		metrics:              &mMetrics,
+		logger:               &mLogger,
+		http: &HTTPRepository{
+			Handler: &mHandler,
+		},
+	}
+
+	mHandler.On("NewRequest").Return(&mRequest, nil)
+	mRequest.On("SetMethod", mock.AnythingOfType("string")).Return(&mRequest)
+	mRequest.On("SetPath", mock.AnythingOfType("string")).Return(&mRequest)
+	mRequest.On("SetTimeOut", mock.AnythingOfType("int")).Return(&mRequest)
+	mRequest.On("SetQueryParams", mock.AnythingOfType("map[string]string")).Return(&mRequest)
+
+	mLogger.On("LogStatus", mock.AnythingOfType("int"))
+	mLogger.On("LogRequestURI", mock.AnythingOfType("string"))
+	mLogger.On("LogResponse", mock.AnythingOfType("string"), nil)
+	mSigner.On("GenerateTokenString", mock.AnythingOfType("DeleteClaims")).Return("claims")
+	mSecondarySigner.On("GenerateTokenString", mock.AnythingOfType("DeleteClaims")).Return("claims")
+
+	// primary key rejected, request retried with secondary key
+	mHandler.On("Send", &mRequest).Return(HTTPResponse{Code: 401}, nil).Once()
+	mHandler.On("Send", &mRequest).Return(HTTPResponse{Code: 202}, nil).Once()
+	mLogger.On("LogAccessKeySwitch", "primary", "secondary").Once()
+	mMetrics.On("SetGauge", domain.ActiveAccessKey, float64(domain.SecondaryAccessKey)).Once()
+
+	resp := yamsRepo.RemoteDelete("foto-sexy.jpg", domain.YAMSForceRemoval)
+	assert.Nil(t, resp)
+	assert.Equal(t, domain.SecondaryAccessKey, yamsRepo.activeKey)
+
+	// both keys rejected, request is retried only once
+	mHandler.On("Send", &mRequest).Return(HTTPResponse{Code: 403}, nil).Twice()
+	mLogger.On("LogAccessKeySwitch", "secondary", "primary").Once()
+	mMetrics.On("SetGauge", domain.ActiveAccessKey, float64(domain.PrimaryAccessKey)).Once()
+
+	resp = yamsRepo.RemoteDelete("foto-sexy.jpg", domain.YAMSForceRemoval)
+	assert.Equal(t, usecases.ErrYamsUnauthorized.WithStatus(403), resp)
+	assert.Equal(t, domain.PrimaryAccessKey, yamsRepo.activeKey)
+
+	mLogger.AssertExpectations(t)
+	mSigner.AssertExpectations(t)
+	mSecondarySigner.AssertExpectations(t)
+	mHandler.AssertExpectations(t)
+	mRequest.AssertExpectations(t)
+	mMetrics.AssertExpectations(t)
+}
+
+func TestRotateKeyWithoutSecondaryKey(t *testing.T) {
+	yamsRepo := YamsRepository{}
+	assert.False(t, yamsRepo.rotateKey(domain.PrimaryAccessKey))
+	assert.Equal(t, domain.PrimaryAccessKey, yamsRepo.activeKey)
+}
+
+// timeoutError is a net.Error returned by timed out requests
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestTransportError(t *testing.T) {
+	assert.Nil(t, transportError(nil))
+	assert.Nil(t, transportError(fmt.Errorf("Internal server error")))
+	assert.Equal(t, usecases.ErrYamsTimeout, transportError(timeoutError{}))
+	assert.Equal(t, usecases.ErrYamsConnection, transportError(&net.OpError{Op: "dial", Err: fmt.Errorf("refused")}))
+	assert.Equal(t, usecases.ErrYamsConnection, transportError(ErrRequestRejected))
+}
+
 func TestGetRemoteChecksum(t *testing.T) {
 	mLogger := MockYamsRepoLogger{}
 	mSigner := mockSigner{}
@@ -462,7 +572,7 @@ func TestGetRemoteChecksum(t *testing.T) {
 			}
 			mHandler.On("Send", &mRequest).Return(response, nil).Once()
 			_, err := yamsRepo.GetRemoteChecksum("foto-sexy.jpg")
-			assert.Equal(t, usecases.ErrYamsObjectNotFound, err)
+			assert.Equal(t, usecases.ErrYamsObjectNotFound.WithStatus(404), err)
 
 		case 2: // 500 server error
 			response := HTTPResponse{
@@ -470,21 +580,21 @@ func TestGetRemoteChecksum(t *testing.T) {
 			}
 			mHandler.On("Send", &mRequest).Return(response, nil).Once()
 			_, err := yamsRepo.GetRemoteChecksum("foto-sexy.jpg")
-			assert.Equal(t, usecases.ErrYamsInternal, err)
+			assert.Equal(t, usecases.ErrYamsInternal.WithStatus(500), err)
 		case 3: // 503 Service temporarily unavailable
 			response := HTTPResponse{
 				Code: 503,
 			}
 			mHandler.On("Send", &mRequest).Return(response, nil).Once()
 			_, err := yamsRepo.GetRemoteChecksum("foto-sexy.jpg")
-			assert.Equal(t, usecases.ErrYamsInternal, err)
+			assert.Equal(t, usecases.ErrYamsInternal.WithStatus(503), err)
 		default: // Unknown error
 			response := HTTPResponse{
 				Code: 999,
 			}
 			mHandler.On("Send", &mRequest).Return(response, nil).Once()
 			_, err := yamsRepo.GetRemoteChecksum("foto-sexy.jpg")
-			assert.Equal(t, usecases.ErrYamsInternal, err)
+			assert.Equal(t, usecases.ErrYamsInternal.WithStatus(999), err)
 		}
 	}
 	mLogger.AssertExpectations(t)
@@ -551,7 +661,7 @@ func TestGetLocalImages(t *testing.T) {
 			mHandler.On("Send", &mRequest).Return(response, nil).Once()
 			_, continuationToken, err := yamsRepo.List("", 1)
 			assert.Equal(t, expToken, continuationToken)
-			assert.Equal(t, usecases.ErrYamsObjectNotFound, err)
+			assert.Equal(t, usecases.ErrYamsObjectNotFound.WithStatus(404), err)
 
 		case 2: // 500 object not found error
 			response := HTTPResponse{
@@ -561,7 +671,7 @@ func TestGetLocalImages(t *testing.T) {
 			mHandler.On("Send", &mRequest).Return(response, nil).Once()
 			_, continuationToken, err := yamsRepo.List("123", 1)
 			assert.Equal(t, expToken, continuationToken)
-			assert.Equal(t, usecases.ErrYamsInternal, err)
+			assert.Equal(t, usecases.ErrYamsInternal.WithStatus(500), err)
 		case 3: // 503 Service temporarily unavailable
 			response := HTTPResponse{
 				Code: 503,
@@ -570,7 +680,7 @@ func TestGetLocalImages(t *testing.T) {
 			mHandler.On("Send", &mRequest).Return(response, nil).Once()
 			_, continuationToken, err := yamsRepo.List("123", 1)
 			assert.Equal(t, expToken, continuationToken)
-			assert.Equal(t, usecases.ErrYamsInternal, err)
+			assert.Equal(t, usecases.ErrYamsInternal.WithStatus(503), err)
 		case 4: // Unmarshal error
 			response := HTTPResponse{
 				Body: "+++++++",
@@ -578,7 +688,7 @@ func TestGetLocalImages(t *testing.T) {
 			mHandler.On("Send", &mRequest).Return(response, nil).Once()
 			_, continuationToken, err := yamsRepo.List("123", 1)
 			assert.Equal(t, expToken, continuationToken)
-			assert.Equal(t, usecases.ErrYamsInternal, err)
+			assert.Equal(t, usecases.ErrYamsInternal.WithStatus(0), err)
 		default: // Unknown error
 			response := HTTPResponse{
 				Code: 999,
@@ -587,7 +697,7 @@ func TestGetLocalImages(t *testing.T) {
 			mHandler.On("Send", &mRequest).Return(response, nil).Once()
 			_, continuationToken, err := yamsRepo.List("123", 1)
 			assert.Equal(t, expToken, continuationToken)
-			assert.Equal(t, usecases.ErrYamsInternal, err)
+			assert.Equal(t, usecases.ErrYamsInternal.WithStatus(999), err)
 		}
 	}
 	mLogger.AssertExpectations(t)
@@ -595,3 +705,131 @@ func TestGetLocalImages(t *testing.T) {
 	mHandler.AssertExpectations(t)
 	mRequest.AssertExpectations(t)
 }
+
+type recordedSpan struct {
+	name       string
+	attributes map[string]string
+	children   []*recordedSpan
+	ended      bool
+	err        error
+}
+
+func (s *recordedSpan) StartChild(name string, attributes map[string]string) interfaces.Span {
+	child := &recordedSpan{name: name, attributes: map[string]string{}}
+	for key, value := range attributes {
+		child.attributes[key] = value
+	}
+	s.children = append(s.children, child)
+	return child
+}
+
+func (s *recordedSpan) SetAttribute(key, value string) {
+	s.attributes[key] = value
+}
+
+func (s *recordedSpan) End(err error) {
+	s.ended, s.err = true, err
+}
+
+type recordingTracer struct {
+	spans []*recordedSpan
+}
+
+func (t *recordingTracer) StartSpan(name string, attributes map[string]string) interfaces.Span {
+	root := &recordedSpan{}
+	span := root.StartChild(name, attributes).(*recordedSpan)
+	t.spans = append(t.spans, span)
+	return span
+}
+
+func TestSendTraced(t *testing.T) {
+	mLogger := MockYamsRepoLogger{}
+	mSigner := mockSigner{}
+	mHandler := mockHTTPHandler{}
+	mRequest := mockRequest{}
+	mFileSystemView := mockFileSystemView{}
+	mFile := mockFile{}
+	tracer := &recordingTracer{}
+
+	yamsRepo := YamsRepository{
+		jwtSigner: &mSigner,
+		logger:    &mLogger,
+		http: &HTTPRepository{
+			Handler: &mHandler,
+		},
+		localImageRepo: NewLocalImageRepo("", &mFileSystemView),
+	}
+	yamsRepo.SetTracer(tracer)
+
+	mHandler.On("NewRequest").Return(&mRequest, nil)
+	mFileSystemView.On("Open", mock.AnythingOfType("string")).Return(&mFile, nil)
+	mRequest.On("SetMethod", mock.AnythingOfType("string")).Return(&mRequest)
+	mRequest.On("SetPath", mock.AnythingOfType("string")).Return(&mRequest)
+	mRequest.On("SetQueryParams", mock.AnythingOfType("map[string]string")).Return(&mRequest)
+	mRequest.On("SetImgBody", mock.AnythingOfType("*repository.mockFile")).Return(&mRequest)
+	mRequest.On("SetTimeOut", mock.AnythingOfType("int")).Return(&mRequest)
+	mRequest.On("SetHeaders", mock.AnythingOfType("map[string]string")).Return(&mRequest)
+	mFile.On("Close").Return(nil)
+	mLogger.On("LogStatus", mock.AnythingOfType("int"))
+	mLogger.On("LogResponse", mock.AnythingOfType("string"), nil)
+	mSigner.On("GenerateTokenString", mock.AnythingOfType("PutClaims")).Return("claims")
+	mHandler.On("Send", &mRequest).Return(HTTPResponse{Code: 500}, nil).Once()
+
+	_, err := yamsRepo.Send(domain.Image{Metadata: domain.ImageMetadata{ImageName: "foo.jpg"}})
+	assert.Equal(t, usecases.ErrYamsInternal.WithStatus(500), err)
+
+	assert.Len(t, tracer.spans, 1)
+	put := tracer.spans[0]
+	assert.Equal(t, "yams.put", put.name)
+	assert.Equal(t, "foo.jpg", put.attributes["image.name"])
+	assert.True(t, put.ended)
+	assert.Equal(t, usecases.ErrYamsInternal.WithStatus(500), put.err)
+	assert.Len(t, put.children, 2)
+	assert.Equal(t, "jwt.sign", put.children[0].name)
+	assert.True(t, put.children[0].ended)
+	assert.Equal(t, "http.send", put.children[1].name)
+	assert.Equal(t, "POST", put.children[1].attributes["http.method"])
+	assert.Equal(t, "500", put.children[1].attributes["http.status_code"])
+	assert.True(t, put.children[1].ended)
+}
+
+func TestRemoteDeleteTracedAsChild(t *testing.T) {
+	mLogger := MockYamsRepoLogger{}
+	mSigner := mockSigner{}
+	mHandler := mockHTTPHandler{}
+	mRequest := mockRequest{}
+	tracer := &recordingTracer{}
+	parent := &recordedSpan{name: "image.delete", attributes: map[string]string{}}
+
+	yamsRepo := YamsRepository{
+		jwtSigner: &mSigner,
+		logger:    &mLogger,
+		http: &HTTPRepository{
+			Handler: &mHandler,
+		},
+		localImageRepo: NewLocalImageRepo("", nil),
+	}
+	yamsRepo.SetTracer(tracer)
+
+	mHandler.On("NewRequest").Return(&mRequest, nil)
+	mRequest.On("SetMethod", mock.AnythingOfType("string")).Return(&mRequest)
+	mRequest.On("SetPath", mock.AnythingOfType("string")).Return(&mRequest)
+	mRequest.On("SetQueryParams", mock.AnythingOfType("map[string]string")).Return(&mRequest)
+	mRequest.On("SetTimeOut", mock.AnythingOfType("int")).Return(&mRequest)
+	mLogger.On("LogStatus", mock.AnythingOfType("int"))
+	mLogger.On("LogRequestURI", mock.AnythingOfType("string"))
+	mLogger.On("LogResponse", mock.AnythingOfType("string"), nil)
+	mSigner.On("GenerateTokenString", mock.AnythingOfType("DeleteClaims")).Return("claims")
+	mHandler.On("Send", &mRequest).Return(HTTPResponse{Code: 202}, nil).Once()
+
+	assert.Nil(t, yamsRepo.RemoteDeleteTraced(parent, "foo.jpg", domain.YAMSForceRemoval))
+
+	// no new trace is started, the request is traced in the parent one
+	assert.Empty(t, tracer.spans)
+	assert.Len(t, parent.children, 1)
+	remove := parent.children[0]
+	assert.Equal(t, "yams.delete", remove.name)
+	assert.Equal(t, "foo.jpg", remove.attributes["image.name"])
+	assert.True(t, remove.ended)
+	assert.Len(t, remove.children, 2)
+}
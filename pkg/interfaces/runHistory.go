@@ -0,0 +1,42 @@
+package interfaces
+
+import (
+	"fmt"
+	"time"
+)
+
+// defaultHistoryLimit is the number of runs listed when no limit is given
+const defaultHistoryLimit = 20
+
+// Exit reasons of a run
+const (
+	RunCompleted   = "completed"
+	RunInterrupted = "interrupted"
+	RunFailed      = "failed"
+)
+
+// SyncRun is the record of a command execution with its final stats and the
+// synchronization marks before & after it
+type SyncRun struct {
+	Command    string            `json:"command"`
+	Flags      map[string]string `json:"flags"`
+	StartedAt  time.Time         `json:"started_at"`
+	FinishedAt time.Time         `json:"finished_at"`
+	Stats      map[string]int    `json:"stats"`
+	MarkBefore time.Time         `json:"mark_before"`
+	MarkAfter  time.Time         `json:"mark_after"`
+	ExitReason string            `json:"exit_reason"`
+}
+
+// Duration returns how long the run took
+func (run SyncRun) Duration() time.Duration {
+	return run.FinishedAt.Sub(run.StartedAt)
+}
+
+// runExitReason returns the exit reason of a run finished with err
+func runExitReason(err error) string {
+	if err != nil {
+		return fmt.Sprintf("%s: %s", RunFailed, err)
+	}
+	return RunCompleted
+}
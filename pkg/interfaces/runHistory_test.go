@@ -0,0 +1,125 @@
+package interfaces
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type mockRunHistory struct {
+	mock.Mock
+}
+
+func (m *mockRunHistory) Add(run SyncRun) error {
+	args := m.Called(run)
+	return args.Error(0)
+}
+
+func (m *mockRunHistory) List(limit int) ([]SyncRun, error) {
+	args := m.Called(limit)
+	return args.Get(0).([]SyncRun), args.Error(1)
+}
+
+func TestRunExitReason(t *testing.T) {
+	assert.Equal(t, "completed", runExitReason(nil))
+	assert.Equal(t, "failed: err", runExitReason(fmt.Errorf("err")))
+}
+
+func TestStartRunWithoutHistory(t *testing.T) {
+	cli := NewCLIYams(nil, nil, nil, nil, nil, time.Now(), NewStats(nil), "")
+	cli.StartRun("sync", nil)
+	cli.SetRunResult(nil)
+	assert.NoError(t, cli.Close())
+}
+
+func TestCloseSavesRun(t *testing.T) {
+	mLastSync := &mockLastSync{}
+	mRunHistory := &mockRunHistory{}
+	before := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	after := before.Add(time.Hour)
+	mLastSync.On("GetLastSynchronizationMark").Return(before).Once()
+	mLastSync.On("GetLastSynchronizationMark").Return(after).Once()
+	mRunHistory.On("Add", mock.MatchedBy(func(run SyncRun) bool {
+		return run.Command == "reset" &&
+			run.Flags["threads"] == "5" &&
+			run.ExitReason == RunCompleted &&
+			run.MarkBefore.Equal(before) &&
+			run.MarkAfter.Equal(after) &&
+			!run.FinishedAt.Before(run.StartedAt) &&
+			run.Stats["sent"] == 0
+	})).Return(nil)
+
+	cli := NewCLIYams(nil, nil, mLastSync, nil, nil, time.Now(), NewStats(nil), "")
+	cli.SetRunHistory(mRunHistory)
+	cli.StartRun("reset", map[string]string{"threads": "5"})
+	cli.SetRunResult(nil)
+	err := cli.Close()
+	assert.NoError(t, err)
+	mLastSync.AssertExpectations(t)
+	mRunHistory.AssertExpectations(t)
+}
+
+func TestCloseSavesInterruptedRun(t *testing.T) {
+	mLastSync := &mockLastSync{}
+	mRunHistory := &mockRunHistory{}
+	mLogger := &mockLogger{}
+	mLastSync.On("GetLastSynchronizationMark").Return(time.Time{})
+	mRunHistory.On("Add", mock.MatchedBy(func(run SyncRun) bool {
+		return run.ExitReason == RunInterrupted
+	})).Return(fmt.Errorf("err"))
+	mLogger.On("LogErrorSavingRun", "delete", fmt.Errorf("err"))
+
+	cli := NewCLIYams(nil, nil, mLastSync, nil, mLogger, time.Now(), NewStats(nil), "")
+	cli.SetRunHistory(mRunHistory)
+	cli.StartRun("delete", nil)
+	err := cli.Close()
+	assert.Error(t, err)
+	mRunHistory.AssertExpectations(t)
+	mLogger.AssertExpectations(t)
+}
+
+func TestCloseSavesFailedRun(t *testing.T) {
+	mLastSync := &mockLastSync{}
+	mRunHistory := &mockRunHistory{}
+	mLastSync.On("GetLastSynchronizationMark").Return(time.Time{})
+	mRunHistory.On("Add", mock.MatchedBy(func(run SyncRun) bool {
+		return run.ExitReason == "failed: missing params"
+	})).Return(nil)
+
+	cli := NewCLIYams(nil, nil, mLastSync, nil, nil, time.Now(), NewStats(nil), "")
+	cli.SetRunHistory(mRunHistory)
+	cli.StartRun("sync", nil)
+	cli.SetRunResult(fmt.Errorf("missing params"))
+	assert.NoError(t, cli.Close())
+	mRunHistory.AssertExpectations(t)
+}
+
+func TestGetHistory(t *testing.T) {
+	mRunHistory := &mockRunHistory{}
+	mLogger := &mockLogger{}
+	runs := []SyncRun{{Command: "sync"}}
+	mRunHistory.On("List", defaultHistoryLimit).Return(runs, nil).Once()
+	mRunHistory.On("List", 5).Return(runs, nil).Once()
+	mLogger.On("LogRunHistory", runs).Once()
+	mLogger.On("LogRunHistoryJSON", runs).Once()
+
+	cli := NewCLIYams(nil, nil, nil, nil, mLogger, time.Now(), NewStats(nil), "")
+	cli.SetRunHistory(mRunHistory)
+	assert.NoError(t, cli.GetHistory(0, false))
+	assert.NoError(t, cli.GetHistory(5, true))
+	mRunHistory.AssertExpectations(t)
+	mLogger.AssertExpectations(t)
+}
+
+func TestGetHistoryError(t *testing.T) {
+	mRunHistory := &mockRunHistory{}
+	mRunHistory.On("List", 5).Return([]SyncRun{}, fmt.Errorf("err"))
+
+	cli := NewCLIYams(nil, nil, nil, nil, nil, time.Now(), NewStats(nil), "")
+	cli.SetRunHistory(mRunHistory)
+	assert.Error(t, cli.GetHistory(5, false))
+	mRunHistory.AssertExpectations(t)
+}
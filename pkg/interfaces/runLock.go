@@ -0,0 +1,118 @@
+package interfaces
+
+import (
+	"fmt"
+	"time"
+)
+
+// LockHolder is the run holding the run lock of a profile
+type LockHolder struct {
+	Profile     string
+	Command     string
+	Host        string
+	PID         int
+	AcquiredAt  time.Time
+	HeartbeatAt time.Time
+	// Stale is true when the holder stopped refreshing the lock, the next
+	// run takes it over
+	Stale bool
+}
+
+// RunLockedError is returned when another run holds the run lock
+type RunLockedError struct {
+	Holder LockHolder
+}
+
+func (e *RunLockedError) Error() string {
+	return fmt.Sprintf("run lock of profile %s is held by %s (pid %d on %s) since %s",
+		e.Holder.Profile,
+		e.Holder.Command,
+		e.Holder.PID,
+		e.Holder.Host,
+		e.Holder.AcquiredAt.Format(time.RFC3339),
+	)
+}
+
+// defaultLockPollInterval is the time between attempts to take the run lock
+const defaultLockPollInterval = 5 * time.Second
+
+// SetRunLock enables the run lock, sync & deleteAll only run while holding
+// it waiting up to wait for the current holder to release it
+func (cli *CLIYams) SetRunLock(runLock RunLock, wait time.Duration) {
+	cli.runLock = runLock
+	cli.lockWait = wait
+	cli.lockPollInterval = defaultLockPollInterval
+}
+
+// acquireRunLock takes the run lock for the command, retrying until the wait
+// time is over or the run is stopped
+func (cli *CLIYams) acquireRunLock(command string) error {
+	if cli.runLock == nil {
+		return nil
+	}
+	deadline := time.Now().Add(cli.lockWait)
+	for {
+		acquired, err := cli.runLock.TryAcquire(command)
+		if err != nil {
+			return err
+		}
+		if acquired {
+			cli.lockHeld = true
+			return nil
+		}
+		holder, held, err := cli.runLock.Holder()
+		if err != nil {
+			return err
+		}
+		// the lock may be released between both calls
+		if held {
+			remaining := time.Until(deadline)
+			if remaining <= 0 {
+				return &RunLockedError{Holder: holder}
+			}
+			cli.logger.LogWaitingForRunLock(holder)
+			if remaining > cli.lockPollInterval {
+				remaining = cli.lockPollInterval
+			}
+			select {
+			case <-time.After(remaining):
+			case <-cli.stop:
+				return &RunLockedError{Holder: holder}
+			}
+		}
+	}
+}
+
+// releaseRunLock releases the run lock if this run holds it
+func (cli *CLIYams) releaseRunLock() error {
+	if !cli.lockHeld {
+		return nil
+	}
+	cli.lockHeld = false
+	err := cli.runLock.Release()
+	if err != nil {
+		cli.logger.LogErrorReleasingRunLock(err)
+	}
+	return err
+}
+
+// GetRunLock gets the run holding the run lock
+func (cli *CLIYams) GetRunLock() error {
+	holder, held, err := cli.runLock.Holder()
+	if err != nil {
+		return err
+	}
+	cli.logger.LogRunLock(holder, held)
+	return nil
+}
+
+// BreakRunLock releases the run lock whoever holds it, the holder keeps
+// running if it is still alive
+func (cli *CLIYams) BreakRunLock() error {
+	broken, err := cli.runLock.Break()
+	if err != nil {
+		return err
+	}
+	cli.logger.LogRunLockBroken(broken)
+	return nil
+}
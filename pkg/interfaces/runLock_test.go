@@ -0,0 +1,176 @@
+package interfaces
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.mpi-internal.com/Yapo/yams-dav-sync/pkg/usecases"
+)
+
+type mockRunLock struct {
+	mock.Mock
+}
+
+func (m *mockRunLock) TryAcquire(command string) (bool, error) {
+	args := m.Called(command)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *mockRunLock) Release() error {
+	args := m.Called()
+	return args.Error(0)
+}
+
+func (m *mockRunLock) Holder() (LockHolder, bool, error) {
+	args := m.Called()
+	return args.Get(0).(LockHolder), args.Bool(1), args.Error(2)
+}
+
+func (m *mockRunLock) Break() (bool, error) {
+	args := m.Called()
+	return args.Bool(0), args.Error(1)
+}
+
+func TestAcquireRunLockWithoutLock(t *testing.T) {
+	cli := NewCLIYams(nil, nil, nil, nil, nil, time.Now(), NewStats(nil), "")
+	assert.NoError(t, cli.acquireRunLock("sync"))
+	assert.NoError(t, cli.releaseRunLock())
+}
+
+func TestAcquireRunLockAndRelease(t *testing.T) {
+	mRunLock := &mockRunLock{}
+	mRunLock.On("TryAcquire", "sync").Return(true, nil)
+	mRunLock.On("Release").Return(nil).Once()
+
+	cli := NewCLIYams(nil, nil, nil, nil, nil, time.Now(), NewStats(nil), "")
+	cli.SetRunLock(mRunLock, 0)
+	assert.NoError(t, cli.acquireRunLock("sync"))
+	assert.NoError(t, cli.Close())
+	// the lock is released once
+	assert.NoError(t, cli.releaseRunLock())
+	mRunLock.AssertExpectations(t)
+}
+
+func TestAcquireRunLockHeld(t *testing.T) {
+	mRunLock := &mockRunLock{}
+	mLogger := &mockLogger{}
+	holder := LockHolder{Profile: "default", Command: "sync", Host: "host", PID: 10}
+	mRunLock.On("TryAcquire", "deleteAll").Return(false, nil)
+	mRunLock.On("Holder").Return(holder, true, nil)
+
+	cli := NewCLIYams(nil, nil, nil, nil, mLogger, time.Now(), NewStats(nil), "")
+	cli.SetRunLock(mRunLock, 0)
+	err := cli.DeleteAll(1, 0)
+	assert.Equal(t, &RunLockedError{Holder: holder}, err)
+	assert.Contains(t, err.Error(), "pid 10 on host")
+	assert.NoError(t, cli.releaseRunLock())
+	mRunLock.AssertExpectations(t)
+}
+
+func TestAcquireRunLockWaiting(t *testing.T) {
+	mRunLock := &mockRunLock{}
+	mLogger := &mockLogger{}
+	holder := LockHolder{Profile: "default", Command: "sync"}
+	mRunLock.On("TryAcquire", "sync").Return(false, nil).Once()
+	mRunLock.On("Holder").Return(holder, true, nil).Once()
+	// released between both calls
+	mRunLock.On("TryAcquire", "sync").Return(false, nil).Once()
+	mRunLock.On("Holder").Return(LockHolder{}, false, nil).Once()
+	mRunLock.On("TryAcquire", "sync").Return(true, nil).Once()
+	mLogger.On("LogWaitingForRunLock", holder).Once()
+
+	cli := NewCLIYams(nil, nil, nil, nil, mLogger, time.Now(), NewStats(nil), "")
+	cli.SetRunLock(mRunLock, time.Minute)
+	cli.lockPollInterval = time.Millisecond
+	assert.NoError(t, cli.acquireRunLock("sync"))
+	assert.True(t, cli.lockHeld)
+	mRunLock.AssertExpectations(t)
+	mLogger.AssertExpectations(t)
+}
+
+func TestAcquireRunLockError(t *testing.T) {
+	mRunLock := &mockRunLock{}
+	mRunLock.On("TryAcquire", "sync").Return(false, fmt.Errorf("err")).Once()
+	mRunLock.On("TryAcquire", "sync").Return(false, nil).Once()
+	mRunLock.On("Holder").Return(LockHolder{}, false, fmt.Errorf("err")).Once()
+
+	cli := NewCLIYams(nil, nil, nil, nil, nil, time.Now(), NewStats(nil), "")
+	cli.SetRunLock(mRunLock, 0)
+	assert.Error(t, cli.acquireRunLock("sync"))
+	assert.Error(t, cli.acquireRunLock("sync"))
+	assert.False(t, cli.lockHeld)
+	mRunLock.AssertExpectations(t)
+}
+
+func TestReleaseRunLockError(t *testing.T) {
+	mRunLock := &mockRunLock{}
+	mLogger := &mockLogger{}
+	mRunLock.On("TryAcquire", "sync").Return(true, nil)
+	mRunLock.On("Release").Return(fmt.Errorf("err"))
+	mLogger.On("LogErrorReleasingRunLock", fmt.Errorf("err"))
+
+	cli := NewCLIYams(nil, nil, nil, nil, mLogger, time.Now(), NewStats(nil), "")
+	cli.SetRunLock(mRunLock, 0)
+	assert.NoError(t, cli.acquireRunLock("sync"))
+	assert.Error(t, cli.releaseRunLock())
+	mRunLock.AssertExpectations(t)
+	mLogger.AssertExpectations(t)
+}
+
+func TestGetAndBreakRunLock(t *testing.T) {
+	mRunLock := &mockRunLock{}
+	mLogger := &mockLogger{}
+	holder := LockHolder{Profile: "default", Command: "sync", Stale: true}
+	mRunLock.On("Holder").Return(holder, true, nil).Once()
+	mRunLock.On("Holder").Return(LockHolder{}, false, fmt.Errorf("err")).Once()
+	mRunLock.On("Break").Return(true, nil).Once()
+	mRunLock.On("Break").Return(false, fmt.Errorf("err")).Once()
+	mLogger.On("LogRunLock", holder, true)
+	mLogger.On("LogRunLockBroken", true)
+
+	cli := NewCLIYams(nil, nil, nil, nil, mLogger, time.Now(), NewStats(nil), "")
+	cli.SetRunLock(mRunLock, 0)
+	assert.NoError(t, cli.GetRunLock())
+	assert.Error(t, cli.GetRunLock())
+	assert.NoError(t, cli.BreakRunLock())
+	assert.Error(t, cli.BreakRunLock())
+	mRunLock.AssertExpectations(t)
+	mLogger.AssertExpectations(t)
+}
+
+func TestRunLockMarkBeforeAfterWaiting(t *testing.T) {
+	mRunLock := &mockRunLock{}
+	mLogger := &mockLogger{}
+	mLastSync := &mockLastSync{}
+	mImageService := &mockImageService{}
+	mRunHistory := &mockRunHistory{}
+	before := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	// the holder moved the mark while this run waited
+	after := before.Add(time.Hour)
+	mRunLock.On("TryAcquire", "deleteAll").Return(false, nil).Once()
+	mRunLock.On("Holder").Return(LockHolder{}, true, nil).Once()
+	mRunLock.On("TryAcquire", "deleteAll").Return(true, nil).Once()
+	mRunLock.On("Release").Return(nil).Once()
+	mLastSync.On("GetLastSynchronizationMark").Return(before).Once()
+	mLastSync.On("GetLastSynchronizationMark").Return(after)
+	mImageService.On("List", "", 0).Return([]usecases.YamsObject{}, "", (*usecases.YamsRepositoryError)(nil))
+	mLogger.On("LogWaitingForRunLock", LockHolder{})
+	mLogger.On("LogStats", mock.Anything, mock.Anything)
+	mRunHistory.On("Add", mock.MatchedBy(func(run SyncRun) bool {
+		return run.MarkBefore.Equal(after) && run.ExitReason == RunCompleted
+	})).Return(nil).Once()
+
+	cli := NewCLIYams(mImageService, nil, mLastSync, nil, mLogger, time.Now(), NewStats(nil), "")
+	cli.SetRunHistory(mRunHistory)
+	cli.SetRunLock(mRunLock, time.Minute)
+	cli.lockPollInterval = time.Millisecond
+	cli.StartRun("deleteAll", nil)
+	cli.SetRunResult(cli.DeleteAll(1, 0))
+	assert.NoError(t, cli.Close())
+	mRunLock.AssertExpectations(t)
+	mRunHistory.AssertExpectations(t)
+}
@@ -4,14 +4,16 @@ import "io"
 
 // Stats holds sync process stats
 type Stats struct {
-	Sent       chan int
-	Errors     chan int
-	Duplicated chan int
-	Processed  chan int
-	Skipped    chan int
-	NotFound   chan int
-	Recovered  chan int
-	exposer    MetricsExposer
+	Sent        chan int
+	Errors      chan int
+	Duplicated  chan int
+	Processed   chan int
+	Skipped     chan int
+	NotFound    chan int
+	Recovered   chan int
+	Quarantined chan int
+	DBErrors    chan int
+	exposer     MetricsExposer
 }
 
 // NewStats returns a new instance of Stats
@@ -23,6 +25,8 @@ func NewStats(exposer MetricsExposer) Stats {
 	duplicated := make(chan int, 1)
 	errors := make(chan int, 1)
 	recovered := make(chan int, 1)
+	quarantined := make(chan int, 1)
+	dbErrors := make(chan int, 1)
 
 	processed <- 0
 	skipped <- 0
@@ -31,16 +35,20 @@ func NewStats(exposer MetricsExposer) Stats {
 	errors <- 0
 	duplicated <- 0
 	recovered <- 0
+	quarantined <- 0
+	dbErrors <- 0
 
 	return Stats{
-		Sent:       sent,
-		Processed:  processed,
-		Errors:     errors,
-		Duplicated: duplicated,
-		Skipped:    skipped,
-		NotFound:   notFound,
-		Recovered:  recovered,
-		exposer:    exposer,
+		Sent:        sent,
+		Processed:   processed,
+		Errors:      errors,
+		Duplicated:  duplicated,
+		Skipped:     skipped,
+		NotFound:    notFound,
+		Recovered:   recovered,
+		Quarantined: quarantined,
+		DBErrors:    dbErrors,
+		exposer:     exposer,
 	}
 }
 
@@ -56,12 +64,37 @@ func (s *Stats) Close() error {
 	close(s.Skipped)
 	close(s.NotFound)
 	close(s.Recovered)
+	close(s.Quarantined)
+	close(s.DBErrors)
 	return nil
 }
 
+// Snapshot returns the current value of each stat by name
+func (s *Stats) Snapshot() map[string]int {
+	snapshot := make(map[string]int)
+	for name, stat := range map[string]chan int{
+		"sent":        s.Sent,
+		"errors":      s.Errors,
+		"duplicated":  s.Duplicated,
+		"processed":   s.Processed,
+		"skipped":     s.Skipped,
+		"not_found":   s.NotFound,
+		"recovered":   s.Recovered,
+		"quarantined": s.Quarantined,
+		"db_errors":   s.DBErrors,
+	} {
+		value := <-stat
+		stat <- value
+		snapshot[name] = value
+	}
+	return snapshot
+}
+
 // MetricsExposer allows operations to expose stats
 type MetricsExposer interface {
 	IncrementCounter(metric int)
+	IncrementCounterWithLabel(metric int, label string)
 	SetGauge(metric int, value float64)
+	SetGaugeWithLabel(metric int, label string, value float64)
 	io.Closer
 }
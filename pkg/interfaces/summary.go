@@ -0,0 +1,73 @@
+package interfaces
+
+import "strings"
+
+// Exit codes of the process by run outcome
+const (
+	ExitSuccess = 0
+	ExitPartial = 1
+	ExitFatal   = 2
+)
+
+// Outcomes of a run
+const (
+	OutcomeSuccess = "success"
+	OutcomePartial = "partial"
+	OutcomeFatal   = "fatal"
+)
+
+// maxFailedSample is the maximum number of failed object names kept for the
+// summary
+const maxFailedSample = 100
+
+// RunSummary is the machine readable result of a run
+type RunSummary struct {
+	Command         string         `json:"command"`
+	Outcome         string         `json:"outcome"`
+	ExitCode        int            `json:"exit_code"`
+	ExitReason      string         `json:"exit_reason"`
+	Stats           map[string]int `json:"stats"`
+	FailedTotal     int            `json:"failed_total"`
+	FailedSample    []string       `json:"failed_sample"`
+	DurationSeconds float64        `json:"duration_seconds"`
+	ImagesPerSecond float64        `json:"images_per_second"`
+	MBPerSecond     float64        `json:"mb_per_second"`
+}
+
+// SummaryWriter allows operations to publish the summary of a run
+type SummaryWriter interface {
+	// Write publishes the summary
+	Write(summary RunSummary) error
+}
+
+// runOutcome returns the outcome & exit code of a run: fatal if the command
+// failed, partial if it was interrupted or some objects failed
+func runOutcome(exitReason string, failedTotal int) (string, int) {
+	switch {
+	case strings.HasPrefix(exitReason, RunFailed):
+		return OutcomeFatal, ExitFatal
+	case exitReason != RunCompleted || failedTotal > 0:
+		return OutcomePartial, ExitPartial
+	}
+	return OutcomeSuccess, ExitSuccess
+}
+
+// newRunSummary creates the summary of a finished run with its throughput
+func newRunSummary(run SyncRun, failedTotal int, failedSample []string, sentBytes int64) RunSummary {
+	outcome, exitCode := runOutcome(run.ExitReason, failedTotal)
+	summary := RunSummary{
+		Command:         run.Command,
+		Outcome:         outcome,
+		ExitCode:        exitCode,
+		ExitReason:      run.ExitReason,
+		Stats:           run.Stats,
+		FailedTotal:     failedTotal,
+		FailedSample:    failedSample,
+		DurationSeconds: run.Duration().Seconds(),
+	}
+	if summary.DurationSeconds > 0 {
+		summary.ImagesPerSecond = float64(run.Stats["processed"]) / summary.DurationSeconds
+		summary.MBPerSecond = float64(sentBytes) / bytesPerMB / summary.DurationSeconds
+	}
+	return summary
+}
@@ -0,0 +1,120 @@
+package interfaces
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type mockSummaryWriter struct {
+	mock.Mock
+}
+
+func (m *mockSummaryWriter) Write(summary RunSummary) error {
+	args := m.Called(summary)
+	return args.Error(0)
+}
+
+func TestRunOutcome(t *testing.T) {
+	cases := []struct {
+		exitReason  string
+		failedTotal int
+		outcome     string
+		exitCode    int
+	}{
+		{RunCompleted, 0, OutcomeSuccess, ExitSuccess},
+		{RunCompleted, 2, OutcomePartial, ExitPartial},
+		{RunInterrupted, 0, OutcomePartial, ExitPartial},
+		{"", 0, OutcomePartial, ExitPartial},
+		{"failed: missing params", 0, OutcomeFatal, ExitFatal},
+	}
+	for _, c := range cases {
+		outcome, exitCode := runOutcome(c.exitReason, c.failedTotal)
+		assert.Equal(t, c.outcome, outcome, c.exitReason)
+		assert.Equal(t, c.exitCode, exitCode, c.exitReason)
+	}
+}
+
+func TestNewRunSummary(t *testing.T) {
+	start := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	run := SyncRun{
+		Command:    "sync",
+		StartedAt:  start,
+		FinishedAt: start.Add(10 * time.Second),
+		Stats:      map[string]int{"processed": 20, "errors": 1},
+		ExitReason: RunCompleted,
+	}
+	summary := newRunSummary(run, 1, []string{"foo.jpg"}, 5*bytesPerMB)
+	assert.Equal(t, RunSummary{
+		Command:         "sync",
+		Outcome:         OutcomePartial,
+		ExitCode:        ExitPartial,
+		ExitReason:      RunCompleted,
+		Stats:           run.Stats,
+		FailedTotal:     1,
+		FailedSample:    []string{"foo.jpg"},
+		DurationSeconds: 10,
+		ImagesPerSecond: 2,
+		MBPerSecond:     0.5,
+	}, summary)
+}
+
+func TestAddFailedSample(t *testing.T) {
+	cli := NewCLIYams(nil, nil, nil, nil, nil, time.Now(), NewStats(nil), "")
+	for i := 0; i < maxFailedSample+5; i++ {
+		cli.addFailed(fmt.Sprintf("%d.jpg", i))
+	}
+	names := <-cli.failedNames
+	assert.Len(t, names, maxFailedSample)
+	assert.Equal(t, "0.jpg", names[0])
+	assert.Equal(t, maxFailedSample+5, <-cli.failedTotal)
+}
+
+func TestExitCode(t *testing.T) {
+	cli := NewCLIYams(nil, nil, nil, nil, nil, time.Now(), NewStats(nil), "")
+	assert.Equal(t, ExitSuccess, cli.ExitCode())
+	cli.StartRun("sync", nil)
+	cli.SetRunResult(nil)
+	assert.Equal(t, ExitSuccess, cli.ExitCode())
+	cli.addFailed("foo.jpg")
+	assert.Equal(t, ExitPartial, cli.ExitCode())
+	cli.SetRunResult(fmt.Errorf("err"))
+	assert.Equal(t, ExitFatal, cli.ExitCode())
+}
+
+func TestCloseWritesSummary(t *testing.T) {
+	mSummaryWriter := &mockSummaryWriter{}
+	mSummaryWriter.On("Write", mock.MatchedBy(func(summary RunSummary) bool {
+		return summary.Command == "sync" &&
+			summary.Outcome == OutcomePartial &&
+			summary.ExitReason == RunCompleted &&
+			summary.FailedTotal == 1 &&
+			len(summary.FailedSample) == 1
+	})).Return(nil)
+
+	cli := NewCLIYams(nil, nil, nil, nil, nil, time.Now(), NewStats(nil), "")
+	cli.SetSummaryWriter(mSummaryWriter)
+	cli.StartRun("sync", nil)
+	cli.addFailed("foo.jpg")
+	cli.SetRunResult(nil)
+	assert.NoError(t, cli.Close())
+	assert.Equal(t, ExitPartial, cli.ExitCode())
+	mSummaryWriter.AssertExpectations(t)
+}
+
+func TestCloseSummaryError(t *testing.T) {
+	mSummaryWriter := &mockSummaryWriter{}
+	mLogger := &mockLogger{}
+	mSummaryWriter.On("Write", mock.AnythingOfType("interfaces.RunSummary")).Return(fmt.Errorf("err"))
+	mLogger.On("LogErrorWritingSummary", fmt.Errorf("err"))
+
+	cli := NewCLIYams(nil, nil, nil, nil, mLogger, time.Now(), NewStats(nil), "")
+	cli.SetSummaryWriter(mSummaryWriter)
+	cli.StartRun("list", nil)
+	assert.Error(t, cli.Close())
+	mSummaryWriter.AssertExpectations(t)
+	mLogger.AssertExpectations(t)
+}
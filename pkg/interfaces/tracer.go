@@ -0,0 +1,36 @@
+package interfaces
+
+// Tracer creates spans to measure the time spent in each stage of the
+// synchronization, e.g. reading the local image, signing or sending it
+type Tracer interface {
+	// StartSpan starts a new trace with its root span
+	StartSpan(name string, attributes map[string]string) Span
+}
+
+// Span is a traced stage
+type Span interface {
+	// StartChild starts a span nested in this one
+	StartChild(name string, attributes map[string]string) Span
+	// SetAttribute adds an attribute to the span
+	SetAttribute(key, value string)
+	// End finishes the span recording the error, if any
+	End(err error)
+}
+
+// NoopTracer is a tracer that does nothing, used when tracing is disabled
+var NoopTracer Tracer = noopTracer{}
+
+// noopTracer implements Tracer doing nothing
+type noopTracer struct{}
+
+// StartSpan returns a span that does nothing
+func (noopTracer) StartSpan(name string, attributes map[string]string) Span {
+	return noopSpan{}
+}
+
+// noopSpan implements Span doing nothing
+type noopSpan struct{}
+
+func (noopSpan) StartChild(name string, attributes map[string]string) Span { return noopSpan{} }
+func (noopSpan) SetAttribute(key, value string)                            {}
+func (noopSpan) End(err error)                                             {}
@@ -0,0 +1,91 @@
+package interfaces
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.mpi-internal.com/Yapo/yams-dav-sync/pkg/domain"
+	"github.mpi-internal.com/Yapo/yams-dav-sync/pkg/usecases"
+)
+
+type mockTracer struct {
+	mock.Mock
+}
+
+func (m *mockTracer) StartSpan(name string, attributes map[string]string) Span {
+	args := m.Called(name, attributes)
+	return args.Get(0).(Span)
+}
+
+type mockSpan struct {
+	mock.Mock
+}
+
+func (m *mockSpan) StartChild(name string, attributes map[string]string) Span {
+	args := m.Called(name, attributes)
+	return args.Get(0).(Span)
+}
+
+func (m *mockSpan) SetAttribute(key, value string) {
+	m.Called(key, value)
+}
+
+func (m *mockSpan) End(err error) {
+	m.Called(err)
+}
+
+type mockTracedImageService struct {
+	mockImageService
+}
+
+func (m *mockTracedImageService) SendTraced(parent Span, image domain.Image) (string, *usecases.YamsRepositoryError) {
+	args := m.Called(parent, image)
+	return args.String(0), args.Get(1).(*usecases.YamsRepositoryError)
+}
+
+func (m *mockTracedImageService) RemoteDeleteTraced(parent Span, imageName string, force bool) *usecases.YamsRepositoryError {
+	args := m.Called(parent, imageName, force)
+	return args.Get(0).(*usecases.YamsRepositoryError)
+}
+
+func TestGetLocalImageTraced(t *testing.T) {
+	mParent := &mockSpan{}
+	mSpan := &mockSpan{}
+	mLocalImage := &mockLocalImage{}
+	cli := CLIYams{localImage: mLocalImage}
+	err := fmt.Errorf("no such file")
+	mParent.On("StartChild", "local.get_image", map[string]string{"image.path": "foo.jpg"}).Return(mSpan)
+	mLocalImage.On("GetLocalImage", "foo.jpg").Return(domain.Image{}, err)
+	mSpan.On("End", err)
+
+	_, e := cli.getLocalImage(mParent, "foo.jpg")
+	assert.Equal(t, err, e)
+	mParent.AssertExpectations(t)
+	mSpan.AssertExpectations(t)
+	mLocalImage.AssertExpectations(t)
+}
+
+func TestTracedWithoutTracer(t *testing.T) {
+	cli := CLIYams{}
+	err := cli.traced(cli.startSpan("image.sync", nil), "db.clean_error_marks", "foo.jpg",
+		func() error { return nil })
+	assert.NoError(t, err)
+}
+
+func TestSendTracedAsChild(t *testing.T) {
+	mImageService := &mockTracedImageService{}
+	mSpan := &mockSpan{}
+	image := domain.Image{Metadata: domain.ImageMetadata{ImageName: "foo.jpg"}}
+	mImageService.On("SendTraced", mSpan, image).Return("checksum", (*usecases.YamsRepositoryError)(nil)).Once()
+	mImageService.On("RemoteDeleteTraced", mSpan, "foo.jpg", domain.YAMSForceRemoval).
+		Return((*usecases.YamsRepositoryError)(nil)).Once()
+
+	cli := CLIYams{imageService: mImageService}
+	checksum, err := cli.send(mSpan, image)
+	assert.Equal(t, "checksum", checksum)
+	assert.Nil(t, err)
+	assert.Nil(t, cli.remoteDelete(mSpan, "foo.jpg"))
+	mImageService.AssertExpectations(t)
+}
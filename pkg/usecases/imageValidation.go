@@ -0,0 +1,27 @@
+package usecases
+
+// ImageValidationError errors that could happen validating a local image
+type ImageValidationError struct {
+	ErrorString string
+}
+
+// Error parse the image validation error into string
+func (ie *ImageValidationError) Error() string { return ie.ErrorString }
+
+var (
+	// ErrImageEmpty is returned by image validators to indicate that the
+	// image file has no content.
+	ErrImageEmpty = &ImageValidationError{"empty image"}
+
+	// ErrImageUnknownFormat is returned by image validators to indicate that
+	// the image header could not be decoded as a supported format.
+	ErrImageUnknownFormat = &ImageValidationError{"unknown image format"}
+
+	// ErrImageDimensions is returned by image validators to indicate that the
+	// image dimensions are below the allowed minimum.
+	ErrImageDimensions = &ImageValidationError{"invalid image dimensions"}
+
+	// ErrImageTruncated is returned by image validators to indicate that the
+	// image file ends before its end marker.
+	ErrImageTruncated = &ImageValidationError{"truncated image"}
+)
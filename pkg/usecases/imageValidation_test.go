@@ -0,0 +1,13 @@
+package usecases
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestImageValidationError(t *testing.T) {
+	err := ImageValidationError{ErrorString: "err"}
+	result := err.Error()
+	assert.Equal(t, result, "err")
+}
@@ -3,6 +3,11 @@ package usecases
 // YamsRepositoryError erros that could happen in yams repo
 type YamsRepositoryError struct {
 	ErrorString string
+	// StatusCode is the http status answered by yams, zero if yams did not
+	// answer
+	StatusCode int
+	// kind is the sentinel error this error was created from
+	kind *YamsRepositoryError
 }
 
 // YamsGetResponse represents yams response for a list of objects
@@ -22,33 +27,52 @@ type YamsObject struct {
 // Error parse the yams error response into string
 func (pe *YamsRepositoryError) Error() string { return pe.ErrorString }
 
+// WithStatus returns a copy of the sentinel error answered by yams with the
+// http status code
+func (pe *YamsRepositoryError) WithStatus(code int) *YamsRepositoryError {
+	return &YamsRepositoryError{ErrorString: pe.ErrorString, StatusCode: code, kind: pe.Kind()}
+}
+
+// Kind returns the sentinel error of pe, to be compared with the ErrYams
+// errors. A sentinel error & nil are their own kind
+func (pe *YamsRepositoryError) Kind() *YamsRepositoryError {
+	if pe == nil || pe.kind == nil {
+		return pe
+	}
+	return pe.kind
+}
+
 var (
 	// ErrYamsDuplicate is returned by the Put method of YamsRepository
 	// implementations to indicate that an object with the same name already
 	// exists in Yams.
-	ErrYamsDuplicate = &YamsRepositoryError{"object with the same name already exists"}
+	ErrYamsDuplicate = &YamsRepositoryError{ErrorString: "object with the same name already exists"}
 
 	// ErrYamsInternal is returned by any method of YamsRepository
 	// implementations to indicate that an internal error has occured.
-	ErrYamsInternal = &YamsRepositoryError{"internal error"}
+	ErrYamsInternal = &YamsRepositoryError{ErrorString: "internal error"}
 
 	// ErrYamsImage is returned by the Put method of YamsRepository
 	// implementations to indicate that it failed to read the image.
-	ErrYamsImage = &YamsRepositoryError{"image error"}
+	ErrYamsImage = &YamsRepositoryError{ErrorString: "image error"}
 
 	// ErrYamsConnection is returned by any method of YamsRepository
 	// implementations to indicate that it failed to connect with Yams.
-	ErrYamsConnection = &YamsRepositoryError{"connection error"}
+	ErrYamsConnection = &YamsRepositoryError{ErrorString: "connection error"}
+
+	// ErrYamsTimeout is returned by any method of YamsRepository
+	// implementations to indicate that the request to Yams timed out.
+	ErrYamsTimeout = &YamsRepositoryError{ErrorString: "timeout error"}
 
 	// ErrYamsUnauthorized is returned by any method of YamsRepository
 	// implementations to indicate that it failed to authenticate with Yams.
-	ErrYamsUnauthorized = &YamsRepositoryError{"unauthorized error"}
+	ErrYamsUnauthorized = &YamsRepositoryError{ErrorString: "unauthorized error"}
 
 	// ErrYamsBucketNotFound is returned by any method of YamsRepository
 	// implementations to indicate that it failed to locate the bucket.
-	ErrYamsBucketNotFound = &YamsRepositoryError{"bucket not found"}
+	ErrYamsBucketNotFound = &YamsRepositoryError{ErrorString: "bucket not found"}
 
 	// ErrYamsObjectNotFound is returned by any method of YamsRepository
 	// implementations to indicate that it failed to locate the object.
-	ErrYamsObjectNotFound = &YamsRepositoryError{"object not found"}
+	ErrYamsObjectNotFound = &YamsRepositoryError{ErrorString: "object not found"}
 )
@@ -11,3 +11,13 @@ func TestError(t *testing.T) {
 	result := repo.Error()
 	assert.Equal(t, result, "err")
 }
+
+func TestWithStatus(t *testing.T) {
+	err := ErrYamsInternal.WithStatus(503)
+	assert.Equal(t, 503, err.StatusCode)
+	assert.Equal(t, ErrYamsInternal.Error(), err.Error())
+	assert.True(t, err.Kind() == ErrYamsInternal)
+	assert.True(t, err.WithStatus(500).Kind() == ErrYamsInternal)
+	assert.True(t, ErrYamsTimeout.Kind() == ErrYamsTimeout)
+	assert.Nil(t, (*YamsRepositoryError)(nil).Kind())
+}
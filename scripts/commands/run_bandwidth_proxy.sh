@@ -5,6 +5,12 @@ if [[ ! -d "$DIR" ]]; then DIR="$PWD"; fi
 
 set -e
 
+if [ "${BANDWIDTH_PROXY_ENABLED}" = "false" ]
+then
+    echoHeader "Bandwidth limiter proxy disabled"
+    exit 0
+fi
+
 echoTitle "Running bandwidth limiter proxy"
 
 if [ $(uname -s) = "Linux" ]
@@ -26,6 +26,11 @@ export LOGGER_STDLOG_ENABLED=true
 export LOGGER_LOG_LEVEL=3
 
 #DATABASE variables
+# postgres or sqlite3, sqlite3 keeps the state in DATABASE_FILE
+export DATABASE_DRIVER=postgres
+export DATABASE_FILE=${PWD}/yams-dav-sync.db
+export DATABASE_BUSY_TIMEOUT=5000
+export DATABASE_SQLITE_MIGRATIONS_FOLDER=migrations/sqlite
 export DATABASE_NAME=
 export DATABASE_HOST=localhost
 export DATABASE_PORT=5432
@@ -37,6 +42,12 @@ export DATABASE_MAX_OPEN=100
 export DATABASE_MIGRATIONS_FOLDER=migrations
 export DATABASE_CONN_RETRIES=3
 
+# Run lock variables
+# advisory or table, sqlite3 always uses the table
+export RUN_LOCK_MODE=advisory
+export RUN_LOCK_STALE_AFTER=300# seconds without heartbeat to take over a table lock
+export RUN_LOCK_HEARTBEAT=30# seconds
+
 # YAMS variables
 
 # BUCKET LIST FOR DEV:
@@ -51,6 +62,8 @@ export YAMS_DOMAIN_ID=d2b88e84-d868-43b2-af96-456464ba9f5f
 export YAMS_BUCKET_ID=8c2ab775-a9a5-48fb-966f-b1a1b154af13
 export YAMS_ACCESS_KEY_ID=b73145eec0bd48a2
 export YAMS_PRIVATE_KEY=${PWD}/private-key.rsa# Your RSA key filepath
+export YAMS_SECONDARY_ACCESS_KEY_ID=# Optional access key used when the primary is rejected
+export YAMS_SECONDARY_PRIVATE_KEY=# Optional private key filepath of the secondary access key
 export YAMS_IMAGES_LIST_FILE:=dump_images_list.yams# Temp file used to list images to upload
 export YAMS_UPLOAD_LIMIT=0
 export YAMS_MAX_CONCURRENT_CONN=100# Threads qty used to upload images
@@ -64,6 +77,7 @@ export CIRCUIT_BREAKER_CONSECUTIVE_FAILURE=10
 export CIRCUIT_BREAKER_FAILURE_RATIO=0.6
 export CIRCUIT_BREAKER_TIMEOUT=10
 export CIRCUIT_BREAKER_INTERVAL=5
+export CIRCUIT_BREAKER_FAILURE_CODES=5xx,408,429
 
 # Bandwidth proxy limiter variables
 export BANDWIDTH_PROXY_LIMIT=25000# kbps
@@ -71,13 +85,69 @@ export BANDWIDTH_PROXY_HOST=localhost:9999
 export BANDWIDTH_PROXY_CONN_TYPE=tcp
 export BANDWIDTH_PROXY_LATENCY=0
 export BANDWIDTH_PROXY_PROCESS_NAME=floodgate
+export BANDWIDTH_PROXY_ENABLED=true
+
+# Built-in rate limit variables, 0 means no limit
+# Schedules format: HH:MM-HH:MM=bytesPerSec/requestsPerSec,...
+export RATE_LIMIT_SYNC_BYTES_PER_SEC=0
+export RATE_LIMIT_SYNC_REQUESTS_PER_SEC=0
+export RATE_LIMIT_SYNC_SCHEDULE=
+export RATE_LIMIT_LIST_REQUESTS_PER_SEC=0
+export RATE_LIMIT_DELETEALL_REQUESTS_PER_SEC=0
+
+# Image validation variables
+# Quarantine corrupt or truncated images instead of uploading them
+export IMAGE_VALIDATION_ENABLED=false
+export IMAGE_VALIDATION_MIN_WIDTH=1
+export IMAGE_VALIDATION_MIN_HEIGHT=1
+
+# Adaptive concurrency variables
+# Raise uploads concurrency while yams is healthy and back off on errors
+export ADAPTIVE_CONCURRENCY_ENABLED=false
+export ADAPTIVE_CONCURRENCY_MIN=1
+export ADAPTIVE_CONCURRENCY_LATENCY_THRESHOLD=5000
+export ADAPTIVE_CONCURRENCY_BACKOFF_RATIO=0.5
+
+# Schedule variables
+# Sync only runs inside these windows (HH:MM-HH:MM,...), empty means always
+export SCHEDULE_SYNC_WINDOWS=
+
+# Daemon variables
+# Syncs run each DAEMON_SYNC_INTERVAL minutes, 0 means only on demand
+export DAEMON_API_PORT=8878
+export DAEMON_API_TOKEN=
+export DAEMON_SYNC_INTERVAL=60
+export DAEMON_SORT_COMMAND=scripts/commands/sort.sh
 
 # Metrics exporter variables
 export METRICS_PORT=8877
+# Final metrics of each run are pushed to the gateway if the url is set
+export METRICS_PUSHGATEWAY_URL=
+export METRICS_PUSHGATEWAY_JOB=yams-dav-sync
+export METRICS_PROFILE=default
+
+# Progress variables
+# Seconds between progress logs, 0 disables them
+export PROGRESS_LOG_INTERVAL=30
+
+# Tracing variables
+# Exporter is otlp (http endpoint) or stdout
+export TRACING_ENABLED=false
+export TRACING_EXPORTER=otlp
+export TRACING_ENDPOINT=localhost:4318
+export TRACING_INSECURE=true
+export TRACING_SERVICE_NAME=yams-dav-sync
+export TRACING_SAMPLE_RATIO=1
 
 export LAST_SYNC_DEFAULT_DATE=30-12-2015# First execution: skip older images than this date
 
 export ERRORS_MAX_RETRIES_PER_ERROR=3# Skip if the error counter is bigger than this number
 export ERRORS_MAX_RESULTS_PER_PAGE=10000# Pagination for error list stored in DB
+# Seconds to wait before retrying a failed image, doubled on each failure up to the max
+export ERRORS_RETRY_BACKOFF_BASE=60
+export ERRORS_RETRY_BACKOFF_MAX=86400
+# Error marks writes are applied together every BATCH_SIZE writes or BATCH_INTERVAL milliseconds
+export ERRORS_BATCH_SIZE=100
+export ERRORS_BATCH_INTERVAL=1000
 
 export IMAGES_PATH=/opt/images/images